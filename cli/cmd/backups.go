@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/cloudwalk/machine-setup/internal/backups"
+	"github.com/cloudwalk/machine-setup/internal/fsutil"
+	"github.com/cloudwalk/machine-setup/internal/paths"
+	"github.com/spf13/cobra"
+)
+
+// Backups drives the `machine-setup backups` subcommands over the snapshots
+// under Root.
+type Backups struct {
+	Root    string
+	Paths   paths.Paths
+	Confirm Confirmer
+	Home    string
+	Run     fsutil.RunInfo // recorded in the snapshot a restore backs up into
+
+	Stdout io.Writer
+}
+
+// List prints every snapshot, optionally restricted to one group.
+func (b *Backups) List(group string) error {
+	var snaps []backups.Snapshot
+	var err error
+	if group == "" {
+		snaps, err = backups.List(b.Root)
+	} else {
+		snaps, err = backups.ListGroup(b.Root, group)
+	}
+	if err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		fmt.Fprintln(b.Stdout, "No backups found.")
+		return nil
+	}
+	for _, s := range snaps {
+		entries, err := s.Entries()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b.Stdout, "  %-14s %-5s %s  %v", s.Group, s.Name(), s.Time.Format("2006-01-02 15:04"), entries)
+		if s.Meta != nil && s.Meta.Command != "" {
+			fmt.Fprintf(b.Stdout, "  (%s)", s.Meta.Command)
+		}
+		fmt.Fprintln(b.Stdout)
+	}
+	return nil
+}
+
+// Show prints the files in one snapshot and where a restore would put them.
+func (b *Backups) Show(group string, version int) error {
+	s, err := backups.Find(b.Root, group, version)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b.Stdout, "%s %s (%s)\n", s.Group, s.Name(), s.Time.Format("2006-01-02 15:04:05"))
+	if s.Meta != nil {
+		if s.Meta.Command != "" {
+			fmt.Fprintf(b.Stdout, "  command: %s\n", s.Meta.Command)
+		}
+		if s.Meta.Commit != "" {
+			fmt.Fprintf(b.Stdout, "  commit:  %s\n", s.Meta.Commit)
+		}
+	}
+	originals, err := s.Originals(b.Paths)
+	if err != nil {
+		return err
+	}
+	for _, o := range originals {
+		fmt.Fprintf(b.Stdout, "  %s → %s\n", o.Entry, displayPath(b.Home, "~", o.Original))
+	}
+	files, err := s.Files()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b.Stdout, "%d file(s)\n", len(files))
+	return nil
+}
+
+// Restore copies a snapshot back over the live files after confirmation
+// (skipped when yes is set).
+func (b *Backups) Restore(group string, version int, yes bool) error {
+	s, err := backups.Find(b.Root, group, version)
+	if err != nil {
+		return err
+	}
+	if !yes {
+		ok, err := b.Confirm.Confirm(fmt.Sprintf("Overwrite current %s files with %s?", s.Group, s.Name()))
+		if err != nil && err.Error() != "user aborted" {
+			return fmt.Errorf("confirm: %w", err)
+		}
+		if !ok {
+			fmt.Fprintln(b.Stdout, "Cancelled.")
+			return nil
+		}
+	}
+	restored, err := backups.Restore(s, b.Paths, b.Root, b.Run)
+	for _, dst := range restored {
+		fmt.Fprintf(b.Stdout, "  restored %s\n", displayPath(b.Home, "~", dst))
+	}
+	return err
+}
+
+// Prune removes all but the newest keep snapshots per group.
+func (b *Backups) Prune(keep int, dryRun bool) error {
+	removed, err := backups.Prune(b.Root, keep, dryRun)
+	verb := "removed"
+	if dryRun {
+		verb = "would remove"
+	}
+	for _, s := range removed {
+		fmt.Fprintf(b.Stdout, "  %s %s/%s\n", verb, s.Group, s.Name())
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b.Stdout, "%d backup(s) %s, keeping the newest %d per group.\n", len(removed), verb, keep)
+	return nil
+}
+
+// retentionFromEnv honours BACKUP_RETENTION (as declared by
+// scripts/lib/config.sh), falling back to backups.DefaultRetention.
+func retentionFromEnv() int {
+	if v := os.Getenv("BACKUP_RETENTION"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return backups.DefaultRetention
+}
+
+func newBackups(cmd *cobra.Command) (*Backups, error) {
+	opts, err := newComponentOptions(cmd.OutOrStdout(), cmd.ErrOrStderr())
+	if err != nil {
+		return nil, err
+	}
+	return &Backups{
+		Root:    opts.BackupRoot,
+		Paths:   paths.For(opts.RepoRoot, opts.Home),
+		Confirm: FormsConfirmer{},
+		Home:    opts.Home,
+		Run:     opts.Run,
+		Stdout:  cmd.OutOrStdout(),
+	}, nil
+}
+
+// groupAndVersion parses the shared `<group> <version>` positional args.
+func groupAndVersion(args []string) (string, int, error) {
+	v, err := backups.ParseVersion(args[1])
+	return args[0], v, err
+}
+
+var (
+	restoreYes  bool
+	pruneKeep   int
+	pruneDryRun bool
+)
+
+var backupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "List, inspect, restore and prune config backups",
+	Long: `Manage the versioned backups that pull and push write under
+<repo>/backups/<group>/v<N> before overwriting files.`,
+}
+
+var backupsListCmd = &cobra.Command{
+	Use:          "list [group]",
+	Short:        "List backup versions, optionally for one group (e.g. zsh, zsh-repo)",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		b, err := newBackups(cmd)
+		if err != nil {
+			return err
+		}
+		group := ""
+		if len(args) == 1 {
+			group = args[0]
+		}
+		return b.List(group)
+	},
+}
+
+var backupsShowCmd = &cobra.Command{
+	Use:          "show <group> <version|latest>",
+	Short:        "Show the files in a backup and where they would be restored",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		group, version, err := groupAndVersion(args)
+		if err != nil {
+			return err
+		}
+		b, err := newBackups(cmd)
+		if err != nil {
+			return err
+		}
+		return b.Show(group, version)
+	},
+}
+
+var backupsRestoreCmd = &cobra.Command{
+	Use:          "restore <group> <version|latest>",
+	Short:        "Copy a backup back to where its files came from",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		group, version, err := groupAndVersion(args)
+		if err != nil {
+			return err
+		}
+		b, err := newBackups(cmd)
+		if err != nil {
+			return err
+		}
+		return b.Restore(group, version, restoreYes)
+	},
+}
+
+var backupsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old backups, keeping the newest N per group",
+	Long: `Delete all but the newest --keep backups of every group. The default
+comes from BACKUP_RETENTION (5 unless set).`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		b, err := newBackups(cmd)
+		if err != nil {
+			return err
+		}
+		return b.Prune(pruneKeep, pruneDryRun)
+	},
+}
+
+func init() {
+	backupsRestoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "restore without asking for confirmation")
+	backupsPruneCmd.Flags().IntVar(&pruneKeep, "keep", retentionFromEnv(), "number of versions to keep per group")
+	backupsPruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "only print what would be removed")
+	backupsCmd.AddCommand(backupsListCmd, backupsShowCmd, backupsRestoreCmd, backupsPruneCmd)
+}
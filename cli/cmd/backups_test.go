@@ -0,0 +1,76 @@
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/cmd"
+	"github.com/cloudwalk/machine-setup/internal/paths"
+)
+
+var _ = Describe("Backups", func() {
+	var (
+		root    string
+		p       paths.Paths
+		confirm *fixedConfirmer
+		stdout  *bytes.Buffer
+		b       *cmd.Backups
+	)
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		tmp := GinkgoT().TempDir()
+		root = filepath.Join(tmp, "backups")
+		home := filepath.Join(tmp, "home")
+		p = paths.ForOS(filepath.Join(tmp, "repo"), home, "linux")
+		confirm = &fixedConfirmer{answer: true}
+		stdout = &bytes.Buffer{}
+		b = &cmd.Backups{Root: root, Paths: p, Confirm: confirm, Home: home, Stdout: stdout}
+
+		write(filepath.Join(root, "vim", "v1", ".vimrc"), "OLD")
+		write(p.Vim.VimrcLocal, "CURRENT")
+	})
+
+	It("lists snapshots with their entries", func() {
+		Expect(b.List("")).To(Succeed())
+		Expect(stdout.String()).To(ContainSubstring("vim"))
+		Expect(stdout.String()).To(ContainSubstring("v1"))
+		Expect(stdout.String()).To(ContainSubstring(".vimrc"))
+	})
+
+	It("shows where each entry of a snapshot would be restored", func() {
+		Expect(b.Show("vim", 1)).To(Succeed())
+		Expect(stdout.String()).To(ContainSubstring(".vimrc → ~/.vimrc"))
+	})
+
+	It("restores once confirmed", func() {
+		Expect(b.Restore("vim", 1, false)).To(Succeed())
+
+		got, err := os.ReadFile(p.Vim.VimrcLocal)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(got)).To(Equal("OLD"))
+	})
+
+	It("leaves files untouched when the user declines", func() {
+		confirm.answer = false
+
+		Expect(b.Restore("vim", 1, false)).To(Succeed())
+
+		got, err := os.ReadFile(p.Vim.VimrcLocal)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(got)).To(Equal("CURRENT"))
+	})
+
+	It("skips the prompt with yes", func() {
+		Expect(b.Restore("vim", 1, true)).To(Succeed())
+		Expect(confirm.asked).To(Equal(0))
+	})
+})
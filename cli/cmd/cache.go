@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/cache"
+	"github.com/spf13/cobra"
+)
+
+// Cache drives the `machine-setup cache` subcommands over the download
+// cache.
+type Cache struct {
+	Store  cache.Cache
+	Stdout io.Writer
+}
+
+// List prints one row per cached URL, then the total.
+func (c *Cache) List() error {
+	entries, err := c.Store.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(c.Stdout, "Nothing cached in %s.\n", c.Store.Dir)
+		return nil
+	}
+	w := tabwriter.NewWriter(c.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tKIND\tSIZE\tFETCHED")
+	var total int64
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.URL, e.Kind, size(e.Size), e.Fetched.Format("2006-01-02 15:04"))
+		total += e.Size
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "%d download(s), %s, in %s\n", len(entries), size(total), c.Store.Dir)
+	return nil
+}
+
+// Clean empties the cache.
+func (c *Cache) Clean() error {
+	freed, err := c.Store.Clean()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Removed %s (%s).\n", c.Store.Dir, size(freed))
+	return nil
+}
+
+// size formats n bytes for people, e.g. "12.3 MB".
+func size(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGT"[exp])
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "List or clean the download cache",
+	Long: `Manage the cache of what setup downloads: release archives and their
+checksum files, install scripts, signing keys, and git clones such as
+Powerlevel10k. It lives in $XDG_CACHE_HOME/machine-setup
+(~/.cache/machine-setup by default) and is what setup --offline installs
+from.`,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List what is in the download cache",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		c := &Cache{Store: downloadCache(false), Stdout: cmd.OutOrStdout()}
+		return c.List()
+	},
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:          "clean",
+	Short:        "Remove everything in the download cache",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		c := &Cache{Store: downloadCache(false), Stdout: cmd.OutOrStdout()}
+		return c.Clean()
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheListCmd, cacheCleanCmd)
+}
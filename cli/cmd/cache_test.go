@@ -0,0 +1,50 @@
+package cmd_test
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/cmd"
+	"github.com/cloudwalk/machine-setup/internal/pkg/cache"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		stdout *bytes.Buffer
+		c      *cmd.Cache
+	)
+
+	BeforeEach(func() {
+		stdout = &bytes.Buffer{}
+		c = &cmd.Cache{Store: cache.Cache{Dir: filepath.Join(GinkgoT().TempDir(), "machine-setup")}, Stdout: stdout}
+	})
+
+	It("says when nothing is cached", func() {
+		Expect(c.List()).To(Succeed())
+		Expect(stdout.String()).To(Equal("Nothing cached in " + c.Store.Dir + ".\n"))
+	})
+
+	It("lists each download with its size, then removes them all", func() {
+		get := func(string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(strings.Repeat("x", 2500))), nil
+		}
+		body, err := c.Store.Getter(get)("https://example.com/v1/tool.tar.gz")
+		Expect(err).NotTo(HaveOccurred())
+		body.Close()
+
+		Expect(c.List()).To(Succeed())
+		Expect(stdout.String()).To(MatchRegexp(`URL\s+KIND\s+SIZE\s+FETCHED\n`))
+		Expect(stdout.String()).To(MatchRegexp(`https://example.com/v1/tool.tar.gz\s+file\s+2.5 kB\s+\d{4}-\d\d-\d\d`))
+		Expect(stdout.String()).To(HaveSuffix("1 download(s), 2.5 kB, in " + c.Store.Dir + "\n"))
+
+		stdout.Reset()
+		Expect(c.Clean()).To(Succeed())
+		Expect(stdout.String()).To(HavePrefix("Removed " + c.Store.Dir))
+		Expect(c.Store.List()).To(BeEmpty())
+	})
+})
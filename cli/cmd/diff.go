@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudwalk/machine-setup/internal/components"
+	"github.com/cloudwalk/machine-setup/internal/drift"
+	"github.com/cloudwalk/machine-setup/internal/paths"
+	"github.com/cloudwalk/machine-setup/internal/textdiff"
+	"github.com/spf13/cobra"
+)
+
+// Diff orchestrates `machine-setup diff`: for every managed file it shows how
+// a pull would change the local copy (local → repo).
+type Diff struct {
+	Entries  []drift.Entry
+	RepoRoot string
+	Home     string
+	Stat     bool
+
+	Stdout io.Writer
+}
+
+// Run prints a unified diff per differing file, or one summary line per file
+// in stat mode.
+func (d *Diff) Run() error {
+	var changed int
+	var total textdiff.Stat
+	for _, e := range d.Entries {
+		local, err := readOptional(e.Local)
+		if err != nil {
+			return err
+		}
+		repoSide, err := readOptional(e.Repo)
+		if err != nil {
+			return err
+		}
+		if string(local) == string(repoSide) {
+			continue
+		}
+		changed++
+
+		localName, repoName := displayPath(d.Home, "~", e.Local), displayPath(d.RepoRoot, "", e.Repo)
+		if d.Stat {
+			s := textdiff.Count(local, repoSide)
+			total.Added += s.Added
+			total.Removed += s.Removed
+			fmt.Fprintf(d.Stdout, " %-6s %s | +%d -%d\n", e.Component, localName, s.Added, s.Removed)
+			continue
+		}
+		if local == nil {
+			localName = "/dev/null"
+		}
+		if repoSide == nil {
+			repoName = "/dev/null"
+		}
+		fmt.Fprint(d.Stdout, textdiff.Unified(localName, repoName, local, repoSide))
+	}
+
+	switch {
+	case changed == 0:
+		fmt.Fprintln(d.Stdout, "Local configs match the repo.")
+	case d.Stat:
+		fmt.Fprintf(d.Stdout, "%d file(s) differ, +%d -%d\n", changed, total.Added, total.Removed)
+	}
+	return nil
+}
+
+// readOptional reads path, treating a missing file as nil content.
+func readOptional(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// displayPath shortens path relative to root, prefixing the result with
+// prefix ("~" for HOME, "" for the repo). Paths outside root are returned as is.
+func displayPath(root, prefix, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	if prefix == "" {
+		return rel
+	}
+	return filepath.Join(prefix, rel)
+}
+
+// managedEntries expands the path pairs of the components named in only
+// (all when empty) into per-file entries, in pull order.
+func managedEntries(opts components.Options, only []string) ([]drift.Entry, error) {
+	selected, err := components.Select(components.AllPullable(opts), only, nil)
+	if err != nil {
+		return nil, err
+	}
+	p := paths.For(opts.RepoRoot, opts.Home)
+	var out []drift.Entry
+	for _, c := range selected {
+		entries, err := drift.Expand(c.Name(), p.PairsFor(c.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entries...)
+	}
+	return out, nil
+}
+
+var (
+	diffComponents []string
+	diffStat       bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show how a pull would change the local configs",
+	Long: `Compare every managed file with its repo copy and print unified diffs
+from the local file (---) to the repo file (+++), i.e. exactly what a pull
+would overwrite. Use --component to narrow the set and --stat for a summary.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		opts, err := newComponentOptions(cmd.OutOrStdout(), cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+		entries, err := managedEntries(opts, diffComponents)
+		if err != nil {
+			return err
+		}
+		d := &Diff{
+			Entries:  entries,
+			RepoRoot: opts.RepoRoot,
+			Home:     opts.Home,
+			Stat:     diffStat,
+			Stdout:   cmd.OutOrStdout(),
+		}
+		return d.Run()
+	},
+}
+
+func init() {
+	diffCmd.Flags().StringSliceVarP(&diffComponents, "component", "c", nil, "only diff these components (e.g. -c zsh,nvim)")
+	diffCmd.Flags().BoolVar(&diffStat, "stat", false, "print a per-file summary of changed lines instead of full diffs")
+}
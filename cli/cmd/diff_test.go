@@ -0,0 +1,75 @@
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/cmd"
+	"github.com/cloudwalk/machine-setup/internal/drift"
+)
+
+var _ = Describe("Diff.Run", func() {
+	var (
+		repoRoot string
+		home     string
+		stdout   *bytes.Buffer
+		entries  []drift.Entry
+	)
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		tmp := GinkgoT().TempDir()
+		repoRoot = filepath.Join(tmp, "repo")
+		home = filepath.Join(tmp, "home")
+		stdout = &bytes.Buffer{}
+
+		write(filepath.Join(repoRoot, "zsh", "zshrc"), "export A=1\nexport B=2\n")
+		write(filepath.Join(home, ".zshrc"), "export A=1\nexport B=local\n")
+		write(filepath.Join(repoRoot, "vim", "vimrc"), "set nu\n")
+		write(filepath.Join(home, ".vimrc"), "set nu\n")
+		entries = []drift.Entry{
+			{Component: "vim", Repo: filepath.Join(repoRoot, "vim", "vimrc"), Local: filepath.Join(home, ".vimrc")},
+			{Component: "zsh", Repo: filepath.Join(repoRoot, "zsh", "zshrc"), Local: filepath.Join(home, ".zshrc")},
+			{Component: "zsh", Repo: filepath.Join(repoRoot, "zsh", "profile"), Local: filepath.Join(home, ".profile")},
+		}
+	})
+
+	run := func(stat bool) string {
+		d := &cmd.Diff{Entries: entries, RepoRoot: repoRoot, Home: home, Stat: stat, Stdout: stdout}
+		Expect(d.Run()).To(Succeed())
+		return stdout.String()
+	}
+
+	It("prints a unified diff from the local file to the repo file for each differing pair", func() {
+		out := run(false)
+
+		Expect(out).To(ContainSubstring("--- ~/.zshrc\n+++ zsh/zshrc\n"))
+		Expect(out).To(ContainSubstring("-export B=local\n+export B=2\n"))
+		Expect(out).NotTo(ContainSubstring("vimrc"))
+	})
+
+	It("skips pairs that are missing on both sides", func() {
+		Expect(run(false)).NotTo(ContainSubstring("profile"))
+	})
+
+	It("prints one summary line per differing file in stat mode", func() {
+		out := run(true)
+
+		Expect(out).To(ContainSubstring("~/.zshrc | +1 -1"))
+		Expect(out).To(ContainSubstring("1 file(s) differ"))
+		Expect(out).NotTo(ContainSubstring("export"))
+	})
+
+	It("says so when everything matches", func() {
+		entries = entries[:1]
+		Expect(run(false)).To(ContainSubstring("Local configs match the repo."))
+	})
+})
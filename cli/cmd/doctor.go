@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/cloudwalk/machine-setup/internal/components"
+	"github.com/cloudwalk/machine-setup/internal/config"
+	"github.com/cloudwalk/machine-setup/internal/doctor"
+	"github.com/cloudwalk/machine-setup/internal/paths"
+	"github.com/cloudwalk/machine-setup/internal/pkg/script"
+	"github.com/spf13/cobra"
+)
+
+// Doctor orchestrates `machine-setup doctor`: report the result of every
+// environment check.
+type Doctor struct {
+	Results []doctor.Result
+	JSON    bool
+
+	Stdout io.Writer
+}
+
+// Run prints the report and returns an error when any check failed. Warnings
+// alone do not fail the command.
+func (d *Doctor) Run() error {
+	summary := doctor.Summary(d.Results)
+	if d.JSON {
+		if err := d.writeJSON(summary); err != nil {
+			return err
+		}
+	} else {
+		d.writeText(summary)
+	}
+	if n := summary[doctor.Fail]; n > 0 {
+		return fmt.Errorf("%d check(s) failed", n)
+	}
+	return nil
+}
+
+func (d *Doctor) writeJSON(summary map[doctor.Status]int) error {
+	results := d.Results
+	if results == nil {
+		results = []doctor.Result{}
+	}
+	enc := json.NewEncoder(d.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Summary map[doctor.Status]int `json:"summary"`
+		Checks  []doctor.Result       `json:"checks"`
+	}{Summary: summary, Checks: results})
+}
+
+func (d *Doctor) writeText(summary map[doctor.Status]int) {
+	for _, r := range d.Results {
+		fmt.Fprintf(d.Stdout, "  %-4s  %-22s %s\n", r.Status, r.Check, r.Detail)
+		if r.Fix != "" && r.Status != doctor.Pass {
+			fmt.Fprintf(d.Stdout, "        fix: %s\n", r.Fix)
+		}
+	}
+	fmt.Fprintf(d.Stdout, "\n%d passed, %d warning(s), %d failed.\n",
+		summary[doctor.Pass], summary[doctor.Warn], summary[doctor.Fail])
+}
+
+// newChecker gathers what the checks compare against: the registry for this
+// OS, the tools selected in the config, and the repo's fonts.
+func newChecker(opts components.Options) (doctor.Checker, error) {
+	cfg, err := config.Read(configPath())
+	if err != nil {
+		return doctor.Checker{}, fmt.Errorf("reading config: %w", err)
+	}
+	wanted := make([]string, len(cfg.Packages))
+	for i, p := range cfg.Packages {
+		wanted[i] = p.Name
+	}
+	fonts := paths.For(opts.RepoRoot, opts.Home).Fonts
+	entries, err := os.ReadDir(fonts.Repo)
+	if err != nil && !os.IsNotExist(err) {
+		return doctor.Checker{}, err
+	}
+	var fontFiles []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			fontFiles = append(fontFiles, e.Name())
+		}
+	}
+	registry, _, err := newRegistry(opts, nil, script.Trust{}, downloadCache(false))
+	if err != nil {
+		return doctor.Checker{}, err
+	}
+	return doctor.Checker{
+		Probe:   doctor.Host{},
+		GOOS:    runtime.GOOS,
+		Home:    opts.Home,
+		Tools:   registry.Names(),
+		Wanted:  wanted,
+		Fonts:   fontFiles,
+		FontDir: fonts.Local,
+	}, nil
+}
+
+var doctorOutput string
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that this machine is set up the way the repo expects",
+	Long: `Check that registry tools are on PATH, nvim is recent enough, zsh is the
+login shell, oh-my-zsh and powerlevel10k are installed, the repo's fonts are
+registered, and ~/.local/bin is on PATH. Each check passes, warns or fails
+with a hint on how to fix it. Exits non-zero when any check fails.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if doctorOutput != "text" && doctorOutput != "json" {
+			return fmt.Errorf("unknown --output %q (want text or json)", doctorOutput)
+		}
+		opts, err := newComponentOptions(cmd.OutOrStdout(), cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+		checker, err := newChecker(opts)
+		if err != nil {
+			return err
+		}
+		d := &Doctor{
+			Results: checker.Run(),
+			JSON:    doctorOutput == "json",
+			Stdout:  cmd.OutOrStdout(),
+		}
+		return d.Run()
+	},
+}
+
+func init() {
+	doctorCmd.Flags().StringVarP(&doctorOutput, "output", "o", "text", "output format: text or json")
+}
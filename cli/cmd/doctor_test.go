@@ -0,0 +1,48 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/cmd"
+	"github.com/cloudwalk/machine-setup/internal/doctor"
+)
+
+var _ = Describe("Doctor.Run", func() {
+	var stdout *bytes.Buffer
+
+	BeforeEach(func() {
+		stdout = &bytes.Buffer{}
+	})
+
+	It("succeeds with only warnings, printing the fix for each", func() {
+		d := &cmd.Doctor{Stdout: stdout, Results: []doctor.Result{
+			{Check: "tool jq", Status: doctor.Pass, Detail: "/usr/bin/jq"},
+			{Check: "tool gh", Status: doctor.Warn, Detail: "not on PATH", Fix: "install gh"},
+		}}
+
+		Expect(d.Run()).To(Succeed())
+		Expect(stdout.String()).To(ContainSubstring("fix: install gh"))
+		Expect(stdout.String()).To(ContainSubstring("1 passed, 1 warning(s), 0 failed."))
+	})
+
+	It("fails when any check failed and reports JSON with a summary", func() {
+		results := []doctor.Result{
+			{Check: "login shell", Status: doctor.Fail, Detail: "/bin/bash", Fix: "chsh -s zsh"},
+		}
+		d := &cmd.Doctor{Stdout: stdout, Results: results, JSON: true}
+
+		Expect(d.Run()).To(MatchError("1 check(s) failed"))
+
+		var report struct {
+			Summary map[doctor.Status]int `json:"summary"`
+			Checks  []doctor.Result       `json:"checks"`
+		}
+		Expect(json.Unmarshal(stdout.Bytes(), &report)).To(Succeed())
+		Expect(report.Summary[doctor.Fail]).To(Equal(1))
+		Expect(report.Checks).To(Equal(results))
+	})
+})
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/cloudwalk/machine-setup/internal/config"
+	"github.com/cloudwalk/machine-setup/internal/pkg"
+	"github.com/cloudwalk/machine-setup/internal/pkg/release"
+	"github.com/cloudwalk/machine-setup/internal/pkg/script"
+	"github.com/spf13/cobra"
+)
+
+// List orchestrates `machine-setup list`: every registry tool with what is
+// installed next to what the config wants.
+type List struct {
+	Detected []pkg.Detected
+	// Wanted is the set of tools selected in the config.
+	Wanted map[string]bool
+	// Specs maps tools whose config version is "latest" or a range that no
+	// install has resolved yet to that version, shown as desired.
+	Specs map[string]string
+
+	Stdout io.Writer
+}
+
+// Run prints one row per tool.
+func (l *List) Run() error {
+	w := tabwriter.NewWriter(l.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOOL\tINSTALLED\tDESIRED\tSTATUS")
+	for _, d := range l.Detected {
+		installed := "-"
+		if d.State.Installed {
+			installed = d.State.Version
+			if installed == "" {
+				installed = "yes"
+			}
+		}
+		desired := "-"
+		if l.Wanted[d.Name()] {
+			desired = d.Version()
+			if desired == "" {
+				desired = l.Specs[d.Name()]
+			}
+			if desired == "" {
+				desired = "any"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Name(), installed, desired, l.status(d))
+	}
+	return w.Flush()
+}
+
+func (l *List) status(d pkg.Detected) string {
+	switch {
+	case d.Err != nil:
+		return "error: " + d.Err.Error()
+	case d.Current():
+		return "ok"
+	case d.State.Installed:
+		return "version mismatch"
+	case l.Wanted[d.Name()]:
+		return "missing"
+	}
+	return "not selected"
+}
+
+// unresolved returns packages with each "latest" or range version replaced
+// by the release it last resolved to, so listing never resolves one over the
+// network, and the specs that have resolved to none yet.
+func unresolved(packages []config.Package) ([]config.Package, map[string]string) {
+	out := make([]config.Package, len(packages))
+	specs := map[string]string{}
+	for i, p := range packages {
+		if release.IsSpec(p.Version) {
+			if p.Resolved == "" {
+				specs[p.Name] = p.Version
+			}
+			p.Version = p.Resolved
+		}
+		out[i] = p
+	}
+	return out, specs
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show each tool's installed version against the config",
+	Long: `List every tool in this OS's registry with the version installed on the
+machine and the one the config asks for. A "latest" or range version shows
+the release setup last resolved it to, or the version as written if it has
+not resolved one yet; list never looks up releases. Tools not selected in the
+config show "-" as desired.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		opts, err := newComponentOptions(cmd.OutOrStdout(), cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+		cfg, err := config.Read(configPath())
+		if err != nil {
+			return fmt.Errorf("reading config: %w", err)
+		}
+		wanted := map[string]bool{}
+		for _, p := range cfg.Packages {
+			wanted[p.Name] = true
+		}
+		packages, specs := unresolved(cfg.Packages)
+		registry, _, err := newRegistry(opts, packages, script.Trust{}, downloadCache(false))
+		if err != nil {
+			return err
+		}
+		l := &List{
+			Detected: pkg.DetectAll(registry.Installables()),
+			Wanted:   wanted,
+			Specs:    specs,
+			Stdout:   cmd.OutOrStdout(),
+		}
+		return l.Run()
+	},
+}
@@ -0,0 +1,55 @@
+package cmd_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/cmd"
+	"github.com/cloudwalk/machine-setup/internal/pkg"
+)
+
+var _ = Describe("List.Run", func() {
+	It("prints installed against desired for every tool", func() {
+		stdout := &bytes.Buffer{}
+		l := &cmd.List{
+			Stdout: stdout,
+			Wanted: map[string]bool{"jq": true, "neovim": true, "gh": true},
+			Detected: []pkg.Detected{
+				{Installable: &spyInstallable{name: "jq"}, State: pkg.State{Installed: true, Version: "1.7.1"}},
+				{Installable: &spyInstallable{name: "neovim", want: "0.11.6"}, State: pkg.State{Installed: true, Version: "0.10.0"}},
+				{Installable: &spyInstallable{name: "gh"}},
+				{Installable: &spyInstallable{name: "fzf"}},
+				{Installable: &spyInstallable{name: "bat"}, Err: errors.New("dpkg-query: boom")},
+			},
+		}
+
+		Expect(l.Run()).To(Succeed())
+
+		rows := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		Expect(strings.Fields(rows[0])).To(Equal([]string{"TOOL", "INSTALLED", "DESIRED", "STATUS"}))
+		Expect(strings.Fields(rows[1])).To(Equal([]string{"jq", "1.7.1", "any", "ok"}))
+		Expect(strings.Fields(rows[2])).To(Equal([]string{"neovim", "0.10.0", "0.11.6", "version", "mismatch"}))
+		Expect(strings.Fields(rows[3])).To(Equal([]string{"gh", "-", "any", "missing"}))
+		Expect(strings.Fields(rows[4])).To(Equal([]string{"fzf", "-", "-", "not", "selected"}))
+		Expect(rows[5]).To(ContainSubstring("error: dpkg-query: boom"))
+	})
+
+	It("shows a version spec no install has resolved yet as desired", func() {
+		stdout := &bytes.Buffer{}
+		l := &cmd.List{
+			Stdout:   stdout,
+			Wanted:   map[string]bool{"lazygit": true},
+			Specs:    map[string]string{"lazygit": "latest"},
+			Detected: []pkg.Detected{{Installable: &spyInstallable{name: "lazygit"}}},
+		}
+
+		Expect(l.Run()).To(Succeed())
+
+		rows := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		Expect(strings.Fields(rows[1])).To(Equal([]string{"lazygit", "-", "latest", "missing"}))
+	})
+})
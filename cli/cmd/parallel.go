@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg"
+)
+
+// DefaultJobs is how many installs setup runs at once unless --jobs says
+// otherwise.
+const DefaultJobs = 4
+
+// ParallelInstaller is the PackageInstaller for --jobs > 1. It runs up to
+// Workers installs at once, starting each as soon as its dependencies are
+// done, but never two that take the same lock (pkg.LockOf): brew installs run
+// one at a time, as do apt installs, while downloads and installer scripts
+// run alongside them. Each install's output is buffered and printed whole
+// when it finishes, so logs do not interleave.
+type ParallelInstaller struct {
+	Workers int
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+type installResult struct {
+	inst   pkg.Installable
+	lock   string
+	err    error
+	took   time.Duration
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+func (p ParallelInstaller) InstallAll(ordered []pkg.Installable, deps map[string][]string) {
+	workers := max(p.Workers, 1)
+	inSet := make(map[string]bool, len(ordered))
+	for _, inst := range ordered {
+		for _, name := range pkg.Members(inst) {
+			inSet[name] = true
+		}
+	}
+
+	pending := append([]pkg.Installable(nil), ordered...)
+	done := map[string]bool{}
+	failed := map[string]string{}
+	held := map[string]bool{}
+	results := make(chan *installResult)
+	running := 0
+
+	// ready reports whether every dependency of inst in this run has finished.
+	ready := func(inst pkg.Installable) bool {
+		for _, d := range deps[inst.Name()] {
+			if inSet[d] && !done[d] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for len(pending) > 0 || running > 0 {
+		for i := 0; i < len(pending) && running < workers; {
+			inst := pending[i]
+			lock := pkg.LockOf(inst)
+			if !ready(inst) || (lock != "" && held[lock]) {
+				i++
+				continue
+			}
+			pending = append(pending[:i], pending[i+1:]...)
+			name := inst.Name()
+			if cause := pkg.FailedDependency(deps[name], failed); cause != "" {
+				failed[name], done[name] = cause, true
+				fmt.Fprintf(p.Stderr, "  %s: skipped because dependency %s failed\n", name, cause)
+				i = 0 // a skip may unblock tools earlier in the list
+				continue
+			}
+			if lock != "" {
+				held[lock] = true
+			}
+			running++
+			go func() {
+				r := &installResult{inst: inst, lock: lock}
+				start := time.Now()
+				r.err = inst.Install(&r.stdout, &r.stderr)
+				r.took = time.Since(start)
+				results <- r
+			}()
+		}
+		if running == 0 {
+			break // only reachable if deps name a tool that never becomes ready
+		}
+
+		r := <-results
+		running--
+		delete(held, r.lock)
+		p.report(r)
+		settle(r.inst, r.err, done, failed, p.Stderr)
+	}
+	reasons := make([]string, len(pending))
+	for i, inst := range pending {
+		reasons[i] = p.neverStarted(inst, deps[inst.Name()], inSet, done, failed, held)
+	}
+	for i, inst := range pending {
+		for _, name := range pkg.Members(inst) {
+			failed[name] = name
+			fmt.Fprintf(p.Stderr, "  %s: not installed: %s\n", name, reasons[i])
+		}
+	}
+	summarize(failed, p.Stderr)
+}
+
+// neverStarted says why inst was still pending once nothing was running.
+func (ParallelInstaller) neverStarted(inst pkg.Installable, deps []string, inSet, done map[string]bool, failed map[string]string, held map[string]bool) string {
+	if cause := pkg.FailedDependency(deps, failed); cause != "" {
+		return "dependency " + cause + " failed"
+	}
+	for _, d := range deps {
+		if inSet[d] && !done[d] {
+			return "dependency " + d + " never finished"
+		}
+	}
+	if lock := pkg.LockOf(inst); lock != "" && held[lock] {
+		return "the " + lock + " lock never became available"
+	}
+	return "it was never scheduled"
+}
+
+// report prints one finished install's buffered output; settle then prints
+// its errors.
+func (p ParallelInstaller) report(r *installResult) {
+	fmt.Fprintf(p.Stdout, "Installing %s... (%s)\n", r.inst.Name(), r.took.Round(time.Second))
+	_, _ = r.stdout.WriteTo(p.Stdout)
+	_, _ = r.stderr.WriteTo(p.Stderr)
+}
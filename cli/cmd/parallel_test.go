@@ -0,0 +1,160 @@
+package cmd_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/cmd"
+	"github.com/cloudwalk/machine-setup/internal/pkg"
+)
+
+// funcInstallable installs by calling install and takes lock, if any.
+type funcInstallable struct {
+	name    string
+	lock    string
+	install func(stdout io.Writer) error
+}
+
+func (f funcInstallable) Name() string                      { return f.name }
+func (f funcInstallable) Install(stdout, _ io.Writer) error { return f.install(stdout) }
+func (f funcInstallable) Plan() []string                    { return nil }
+func (f funcInstallable) Detect() (bool, string, error)     { return false, "", nil }
+func (f funcInstallable) Version() string                   { return "" }
+func (f funcInstallable) Lock() string                      { return f.lock }
+
+var _ = Describe("ParallelInstaller", func() {
+	var stdout, stderr *bytes.Buffer
+
+	BeforeEach(func() {
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+	})
+
+	install := func(ordered []pkg.Installable, deps map[string][]string) {
+		cmd.ParallelInstaller{Workers: 4, Stdout: stdout, Stderr: stderr}.InstallAll(ordered, deps)
+	}
+
+	It("runs a download alongside a brew install", func() {
+		brewStarted, dlStarted := make(chan struct{}), make(chan struct{})
+		meet := func(mine, theirs chan struct{}) func(io.Writer) error {
+			return func(io.Writer) error {
+				close(mine)
+				select {
+				case <-theirs:
+					return nil
+				case <-time.After(2 * time.Second):
+					return errors.New("ran alone")
+				}
+			}
+		}
+
+		install([]pkg.Installable{
+			funcInstallable{name: "jq", lock: "brew", install: meet(brewStarted, dlStarted)},
+			funcInstallable{name: "neovim", install: meet(dlStarted, brewStarted)},
+		}, nil)
+
+		Expect(stderr.String()).To(BeEmpty())
+	})
+
+	It("never runs two installs that share a lock at once", func() {
+		var mu sync.Mutex
+		active, most := 0, 0
+		locked := func(name string) pkg.Installable {
+			return funcInstallable{name: name, lock: "brew", install: func(io.Writer) error {
+				mu.Lock()
+				active++
+				most = max(most, active)
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				active--
+				mu.Unlock()
+				return nil
+			}}
+		}
+
+		install([]pkg.Installable{locked("a"), locked("b"), locked("c")}, nil)
+
+		Expect(most).To(Equal(1))
+	})
+
+	It("starts a tool only after its dependencies, skipping it when one failed", func() {
+		var mu sync.Mutex
+		var log []string
+		record := func(name string, err error) pkg.Installable {
+			return funcInstallable{name: name, install: func(io.Writer) error {
+				mu.Lock()
+				defer mu.Unlock()
+				log = append(log, name)
+				return err
+			}}
+		}
+
+		install([]pkg.Installable{
+			record("node", errors.New("download failed")),
+			record("go", nil),
+			record("yarn", nil),
+			record("golangci-lint", nil),
+		}, map[string][]string{"yarn": {"node"}, "golangci-lint": {"go"}})
+
+		Expect(log).To(ConsistOf("node", "go", "golangci-lint"))
+		Expect(indexOf(log, "go")).To(BeNumerically("<", indexOf(log, "golangci-lint")))
+		Expect(stderr.String()).To(ContainSubstring("node: download failed"))
+		Expect(stderr.String()).To(ContainSubstring("yarn: skipped because dependency node failed"))
+	})
+
+	It("reports each tool that never became ready as not installed, and why", func() {
+		ran := false
+		never := func(name string) pkg.Installable {
+			return funcInstallable{name: name, install: func(io.Writer) error { ran = true; return nil }}
+		}
+
+		install([]pkg.Installable{never("a"), never("b")}, map[string][]string{"a": {"b"}, "b": {"a"}})
+
+		Expect(ran).To(BeFalse())
+		Expect(stderr.String()).To(Equal("  a: not installed: dependency b never finished\n" +
+			"  b: not installed: dependency a never finished\n" +
+			"\n2 not installed: a, b\n"))
+	})
+
+	It("waits for a batch before starting a dependent of one of its members", func() {
+		var log []string
+		deps := map[string][]string{"rvm": {"gpg"}}
+		ordered := pkg.Batch([]pkg.Installable{
+			&spyInstallable{name: "curl", log: &log, batch: "apt"},
+			&spyInstallable{name: "gpg", log: &log, batch: "apt"},
+			funcInstallable{name: "rvm", install: func(io.Writer) error {
+				log = append(log, "rvm")
+				return nil
+			}},
+		}, deps)
+
+		install(ordered, deps)
+
+		Expect(log).To(Equal([]string{"batch curl gpg", "rvm"}))
+		Expect(stderr.String()).To(BeEmpty())
+	})
+
+	It("prints each tool's output in one piece", func() {
+		chatty := func(name string) pkg.Installable {
+			return funcInstallable{name: name, install: func(w io.Writer) error {
+				fmt.Fprintf(w, "%s: fetching\n", name)
+				time.Sleep(5 * time.Millisecond)
+				fmt.Fprintf(w, "%s: done\n", name)
+				return nil
+			}}
+		}
+
+		install([]pkg.Installable{chatty("a"), chatty("b"), chatty("c")}, nil)
+
+		for _, n := range []string{"a", "b", "c"} {
+			Expect(stdout.String()).To(MatchRegexp(`Installing %s\.\.\. \(\w+\)\n%s: fetching\n%s: done\n`, n, n, n))
+		}
+	})
+})
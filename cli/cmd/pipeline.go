@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cloudwalk/machine-setup/internal/components"
+	"github.com/cloudwalk/machine-setup/internal/config"
+	"github.com/cloudwalk/machine-setup/internal/forms"
+	"github.com/cloudwalk/machine-setup/internal/pkg"
+	"github.com/cloudwalk/machine-setup/internal/pkg/apt"
+	"github.com/cloudwalk/machine-setup/internal/pkg/brew"
+	"github.com/cloudwalk/machine-setup/internal/pkg/cache"
+	"github.com/cloudwalk/machine-setup/internal/pkg/distro"
+	"github.com/cloudwalk/machine-setup/internal/pkg/dnf"
+	"github.com/cloudwalk/machine-setup/internal/pkg/download"
+	"github.com/cloudwalk/machine-setup/internal/pkg/pacman"
+	"github.com/cloudwalk/machine-setup/internal/pkg/release"
+	"github.com/cloudwalk/machine-setup/internal/pkg/rvm"
+	"github.com/cloudwalk/machine-setup/internal/pkg/script"
+	"github.com/cloudwalk/machine-setup/internal/pkg/verify"
+)
+
+// downloadCache is the download cache under the XDG cache directory, shared
+// by every download and clone setup makes.
+func downloadCache(offline bool) cache.Cache {
+	return cache.Cache{Dir: config.DefaultCacheDir(), Offline: offline}
+}
+
+// scriptTrust is how setup decides that a downloaded install script that is
+// not pinned may run: the copies approved so far are kept beside the config,
+// and a new or changed script is shown and asked about. Without a terminal
+// nobody is asked: with --yes a new script runs, and otherwise none does.
+func scriptTrust(opts SetupOptions, stdout io.Writer) script.Trust {
+	trust := script.Trust{
+		Approved:   filepath.Join(filepath.Dir(opts.ConfigPath), "approved-scripts"),
+		SkipVerify: opts.InsecureSkipVerify,
+	}
+	switch {
+	case opts.Interactive:
+		trust.Approve = (&ScriptApprover{Out: stdout, Confirm: forms.Confirm}).Approve
+	case opts.Yes:
+		trust.Approve = (&ScriptApprover{Out: stdout, Unattended: true}).Approve
+	}
+	return trust
+}
+
+// ScriptApprover shows an install script that is not pinned, or what changed
+// in it, on Out and asks Confirm, one script at a time. Unattended, it
+// approves only a first use, with a warning.
+type ScriptApprover struct {
+	Out        io.Writer
+	Confirm    func(prompt string) (bool, error)
+	Unattended bool
+	mu         sync.Mutex
+}
+
+// Approve shows r and asks whether to run the script.
+func (a *ScriptApprover) Approve(r script.Review) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.Unattended {
+		if r.First {
+			fmt.Fprintf(a.Out, "WARNING: running the %s install script, which is not pinned, unreviewed (--yes). %s (SHA-256 %s)\n", r.Name, r.URL, r.SHA256)
+		}
+		return r.First, nil
+	}
+	if r.First {
+		fmt.Fprintf(a.Out, "\nThe %s install script is not pinned. %s (SHA-256 %s):\n%s\n", r.Name, r.URL, r.SHA256, r.Diff)
+		return a.Confirm(fmt.Sprintf("Run the %s install script?", r.Name))
+	}
+	fmt.Fprintf(a.Out, "\nThe %s install script changed since it was approved. %s (SHA-256 %s):\n%s\n", r.Name, r.URL, r.SHA256, r.Diff)
+	return a.Confirm(fmt.Sprintf("Run the changed %s install script?", r.Name))
+}
+
+// newSources returns the manager of the config's apt sources on Debian and
+// its derivatives, and nil elsewhere. Offline it is nil too, with a warning:
+// adding a source means fetching its key and indexes.
+func newSources(want []config.Source, offline bool, stdout, stderr io.Writer) Installer {
+	if runtime.GOOS != "linux" || hostDistro().Family() != distro.Debian {
+		return nil
+	}
+	if offline && len(want) > 0 {
+		fmt.Fprintln(stderr, "warning: offline; not updating apt sources")
+		return nil
+	}
+	return apt.Sources{
+		Want:     want,
+		Dir:      apt.SourcesDir,
+		Keyrings: apt.KeyringsDir,
+		Files:    apt.SudoFiles{},
+		Fetch:    apt.DefaultFetcher(),
+		Run:      apt.DefaultRunner(),
+		Stdout:   stdout,
+		Stderr:   stderr,
+	}
+}
+
+// newInstaller returns the installer for jobs: sequential, with live output,
+// for one job; parallel with buffered per-tool output for more.
+func newInstaller(jobs int, stdout, stderr io.Writer) PackageInstaller {
+	if jobs <= 1 {
+		return IterativeInstaller{Stdout: stdout, Stderr: stderr}
+	}
+	return ParallelInstaller{Workers: jobs, Stdout: stdout, Stderr: stderr}
+}
+
+// newRegistry returns the production tool registry for this OS and the
+// releases its "latest" or range pins resolved to.
+func newRegistry(opts components.Options, packages []config.Package, trust script.Trust, downloads cache.Cache) (*pkg.DevToolRegistry, map[string]string, error) {
+	catalog, err := pkg.LoadCatalog(filepath.Join(opts.RepoRoot, pkg.CatalogFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	fetch := downloads.Fetcher(verify.DefaultFetcher())
+	extras := []pkg.Installable{rvm.NewInstaller(filepath.Join(opts.Home, ".rvm"), rvm.DefaultRunner(trust, fetch))}
+	for _, s := range script.BuiltIn(opts.Home, script.Script{Trust: trust, Fetch: fetch}) {
+		extras = append(extras, s)
+	}
+	offline := downloads.Offline
+	factory, err := pkg.NewRegistryFactory(
+		brew.Runner(offlineRunner(offline, "brew", brew.DefaultRunner(), "install", "tap", "update", "upgrade")),
+		apt.Runner(offlineRunner(offline, "apt-get", apt.Updating(apt.DefaultRunner(), apt.ListsDir, apt.MaxIndexAge), "install", "update")),
+		apt.DefaultQueryRunner(),
+		extras...,
+	).WithCatalog(catalog)
+	if err != nil {
+		return nil, nil, err
+	}
+	factory = factory.
+		WithDnf(dnf.Runner(offlineRunner(offline, "dnf", dnf.DefaultRunner(), "install")), dnf.DefaultQueryRunner()).
+		WithPacman(pacman.Runner(offlineRunner(offline, "pacman", pacman.DefaultRunner(), "-S")), pacman.DefaultQueryRunner()).
+		WithInsecureSkipVerify(trust.SkipVerify).
+		WithDownloads(downloads.Getter(download.DefaultGetter()), downloads.Evict, fetch)
+	var linux distro.Distro
+	if runtime.GOOS == "linux" {
+		linux = hostDistro()
+		factory = factory.WithDistro(linux)
+	}
+	resolver := release.NewResolver(filepath.Join(downloads.Dir, "releases"))
+	resolver.Offline = downloads.Offline
+	pins, resolved := resolvePins(packages, factory.ReleaseRepos(runtime.GOOS), resolver.Resolve, opts.Stdout, opts.Stderr)
+	r := factory.WithPins(pins).For(runtime.GOOS)
+	for _, name := range pkg.UnappliedPins(r, pins) {
+		fmt.Fprintf(opts.Stderr, "warning: %s cannot be pinned; ignoring version %s from the config\n", name, pins[name])
+	}
+	if unmapped := factory.Unmapped(runtime.GOOS); len(unmapped) > 0 {
+		if manager := factory.SystemManager(); manager != "" {
+			fmt.Fprintf(opts.Stderr, "warning: no %s package known for %s; not offering them on %s\n", manager, strings.Join(unmapped, ", "), linux.Name)
+		} else {
+			fmt.Fprintf(opts.Stderr, "warning: %s has no supported package manager (apt, dnf or pacman); not offering %s\n", linux.Name, strings.Join(unmapped, ", "))
+		}
+	}
+	return r, resolved, nil
+}
+
+// runFunc is the shape every package manager's Runner shares.
+type runFunc = func(args []string, stdout, stderr io.Writer) error
+
+// offlineRunner wraps a package manager's run so that, offline, a command
+// whose first argument starts with one of network fails at once with
+// cache.ErrOffline instead of reaching for the manager's mirrors (apt's
+// index refresh among them); queries of what is installed still run. Online
+// it returns run as it is.
+func offlineRunner(offline bool, manager string, run runFunc, network ...string) runFunc {
+	if !offline {
+		return run
+	}
+	return func(args []string, stdout, stderr io.Writer) error {
+		if len(args) > 0 && slices.ContainsFunc(network, func(verb string) bool { return strings.HasPrefix(args[0], verb) }) {
+			return fmt.Errorf("%w: not running %s %s, which needs the network", cache.ErrOffline, manager, strings.Join(args, " "))
+		}
+		return run(args, stdout, stderr)
+	}
+}
+
+// resolvePins turns the packages' versions into registry pins. A "latest"
+// or range version only applies to a tool installed from a release download
+// (repos maps those to their repository): it pins the release it resolved
+// to before, while that still satisfies it, and otherwise the newest
+// matching release, which is also returned so the config can record it.
+// A version that cannot be resolved is warned about and left unpinned.
+func resolvePins(packages []config.Package, repos map[string]string, resolve func(repo, spec string) (string, error), stdout, stderr io.Writer) (pins, resolved map[string]string) {
+	pins, resolved = map[string]string{}, map[string]string{}
+	for _, p := range packages {
+		if p.Version == "" {
+			continue
+		}
+		if !release.IsSpec(p.Version) {
+			pins[p.Name] = p.Version
+			continue
+		}
+		repo, ok := repos[p.Name]
+		if !ok {
+			fmt.Fprintf(stderr, "warning: %s: version %q only applies to tools installed from a release download; ignoring it\n", p.Name, p.Version)
+			continue
+		}
+		spec, err := release.ParseSpec(p.Version)
+		if err != nil {
+			fmt.Fprintf(stderr, "warning: %s: %v; ignoring it\n", p.Name, err)
+			continue
+		}
+		v := p.Resolved
+		if v == "" || !spec.Allows(v) {
+			if v, err = resolve(repo, p.Version); err != nil {
+				fmt.Fprintf(stderr, "warning: %s: cannot resolve version %q: %v\n", p.Name, p.Version, err)
+				continue
+			}
+			fmt.Fprintf(stdout, "Resolved %s %s to %s\n", p.Name, p.Version, v)
+		}
+		pins[p.Name], resolved[p.Name] = v, v
+	}
+	return pins, resolved
+}
+
+// hostDistro reads this machine's os-release. Without one the distribution
+// is of no known family, so only downloads and scripts are offered.
+func hostDistro() distro.Distro {
+	d, err := distro.Read(distro.OSRelease)
+	if err != nil {
+		return distro.Distro{Name: "this Linux distribution"}
+	}
+	return d
+}
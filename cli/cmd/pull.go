@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/cloudwalk/machine-setup/internal/components"
+	"github.com/spf13/cobra"
+)
+
+// NewPuller wires a SequentialPuller over the components selected by only and
+// skip (see components.Select). It shares the Options wiring used by NewSetup.
+func NewPuller(stdout, stderr io.Writer, only, skip []string) (SequentialPuller, error) {
+	opts, err := newComponentOptions(stdout, stderr)
+	if err != nil {
+		return SequentialPuller{}, err
+	}
+	selected, err := components.Select(components.AllPullable(opts), only, skip)
+	if err != nil {
+		return SequentialPuller{}, err
+	}
+	return SequentialPuller{Components: selected, Stdout: stdout, Stderr: stderr}, nil
+}
+
+var (
+	pullOnly []string
+	pullSkip []string
+)
+
+var pullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Copy dotfile configs from the repo onto this machine (with backup)",
+	Long: `Copy each component's configs (vim, zsh, byobu, nvim, fonts) from the
+repo into HOME, backing up any file that is about to be overwritten.
+Use --only or --skip to restrict the run to a subset of components.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		p, err := NewPuller(cmd.OutOrStdout(), cmd.ErrOrStderr(), pullOnly, pullSkip)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Pulling configuration files...")
+		if err := p.PullAll(); err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "\nAll components pulled successfully.")
+		return nil
+	},
+}
+
+func init() {
+	pullCmd.Flags().StringSliceVar(&pullOnly, "only", nil, "pull only these components (e.g. --only vim,zsh)")
+	pullCmd.Flags().StringSliceVar(&pullSkip, "skip", nil, "skip these components (e.g. --skip fonts)")
+}
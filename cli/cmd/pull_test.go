@@ -0,0 +1,50 @@
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/cmd"
+	"github.com/cloudwalk/machine-setup/internal/components"
+)
+
+var _ = Describe("SequentialPuller.PullAll", func() {
+	var (
+		log    []string
+		stdout *bytes.Buffer
+		stderr *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		log = []string{}
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+	})
+
+	puller := func(errs map[string]error, names ...string) cmd.SequentialPuller {
+		comps := make([]components.Component, len(names))
+		for i, n := range names {
+			comps[i] = &spyComponent{name: n, log: &log, err: errs[n]}
+		}
+		return cmd.SequentialPuller{Components: comps, Stdout: stdout, Stderr: stderr}
+	}
+
+	It("pulls every component in order and succeeds", func() {
+		Expect(puller(nil, "vim", "zsh").PullAll()).To(Succeed())
+		Expect(log).To(Equal([]string{"vim", "zsh"}))
+		Expect(stdout.String()).To(ContainSubstring("→ vim"))
+	})
+
+	It("keeps going after a failure and returns an error naming the failed components", func() {
+		errs := map[string]error{"zsh": fmt.Errorf("disk full")}
+
+		err := puller(errs, "vim", "zsh", "byobu").PullAll()
+
+		Expect(err).To(MatchError(ContainSubstring("zsh")))
+		Expect(log).To(Equal([]string{"vim", "zsh", "byobu"}))
+		Expect(stderr.String()).To(ContainSubstring("disk full"))
+	})
+})
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cloudwalk/machine-setup/internal/components"
+	"github.com/cloudwalk/machine-setup/internal/forms"
+	"github.com/cloudwalk/machine-setup/internal/paths"
+	"github.com/cloudwalk/machine-setup/internal/repo"
+	"github.com/spf13/cobra"
+)
+
+// defaultPushMessage matches the commit message scripts/push.sh uses.
+const defaultPushMessage = "Updating remote config files"
+
+// VCS is the slice of git the push flow needs.
+type VCS interface {
+	HasChanges(paths ...string) (bool, error)
+	Status(w io.Writer, paths ...string) error
+	Commit(message string, paths []string, stdout, stderr io.Writer) error
+}
+
+// Confirmer asks the user a yes/no question.
+type Confirmer interface {
+	Confirm(prompt string) (bool, error)
+}
+
+// Push orchestrates `machine-setup push`: copy local configs into the repo,
+// show what changed, and commit once the user agrees. Only Paths, the repo
+// files the components own, are committed; backups and other work in the
+// repo are left alone.
+type Push struct {
+	Components []components.Pushable
+	Paths      []string
+	Git        VCS
+	Confirm    Confirmer
+	Message    string
+
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Run pushes every component. Any component failure aborts before the commit
+// so a half-captured state never lands in git.
+func (p *Push) Run() error {
+	var failed []string
+	for _, c := range p.Components {
+		fmt.Fprintf(p.Stdout, "  → %s\n", c.Name())
+		if err := c.Push(); err != nil {
+			fmt.Fprintf(p.Stderr, "  %s: %v\n", c.Name(), err)
+			failed = append(failed, c.Name())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed components: %s", strings.Join(failed, ", "))
+	}
+
+	if len(p.Paths) == 0 {
+		fmt.Fprintln(p.Stdout, "\nNo repo files to commit for these components.")
+		return nil
+	}
+	dirty, err := p.Git.HasChanges(p.Paths...)
+	if err != nil {
+		return err
+	}
+	if !dirty {
+		fmt.Fprintln(p.Stdout, "\nRepo already matches local configs; nothing to commit.")
+		return nil
+	}
+
+	fmt.Fprintln(p.Stdout, "\nChanges to be committed:")
+	if err := p.Git.Status(p.Stdout, p.Paths...); err != nil {
+		return err
+	}
+
+	ok, err := p.Confirm.Confirm("Commit these changes?")
+	if err != nil && err.Error() != "user aborted" {
+		return fmt.Errorf("confirm: %w", err)
+	}
+	if !ok {
+		fmt.Fprintln(p.Stdout, "Cancelled; changes left uncommitted in the repo.")
+		return nil
+	}
+
+	if err := p.Git.Commit(p.Message, p.Paths, p.Stdout, p.Stderr); err != nil {
+		return err
+	}
+	fmt.Fprintln(p.Stdout, "\nCommitted. Run `git push` to share the changes.")
+	return nil
+}
+
+// FormsConfirmer wraps forms.Confirm.
+type FormsConfirmer struct{}
+
+func (FormsConfirmer) Confirm(prompt string) (bool, error) { return forms.Confirm(prompt) }
+
+// NewPush wires Push for the components selected by only and skip.
+func NewPush(stdout, stderr io.Writer, only, skip []string, message string) (*Push, error) {
+	opts, err := newComponentOptions(stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := components.Select(components.AllPushable(opts), only, skip)
+	if err != nil {
+		return nil, err
+	}
+	table := paths.For(opts.RepoRoot, opts.Home)
+	var owned []string
+	for _, c := range selected {
+		for _, pair := range table.PairsFor(c.Name()) {
+			owned = append(owned, pair.Repo)
+		}
+	}
+	return &Push{
+		Components: selected,
+		Paths:      owned,
+		Git:        repo.NewGit(opts.RepoRoot),
+		Confirm:    FormsConfirmer{},
+		Message:    message,
+		Stdout:     stdout,
+		Stderr:     stderr,
+	}, nil
+}
+
+var (
+	pushOnly    []string
+	pushSkip    []string
+	pushMessage string
+)
+
+var pushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Copy local dotfile configs back into the repo and commit them",
+	Long: `Copy each component's local configs (vim, zsh, byobu, nvim) into the
+repo, backing up the repo copies first, then show git status and commit after
+confirmation. Only those components' files are committed; anything else in the
+repo, such as backups/, is left out. Fonts are read-only and never pushed.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		p, err := NewPush(cmd.OutOrStdout(), cmd.ErrOrStderr(), pushOnly, pushSkip, pushMessage)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), "Pushing configuration files...")
+		return p.Run()
+	},
+}
+
+func init() {
+	pushCmd.Flags().StringSliceVar(&pushOnly, "only", nil, "push only these components (e.g. --only zsh,nvim)")
+	pushCmd.Flags().StringSliceVar(&pushSkip, "skip", nil, "skip these components")
+	pushCmd.Flags().StringVarP(&pushMessage, "message", "m", defaultPushMessage, "commit message")
+}
@@ -0,0 +1,121 @@
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/cmd"
+	"github.com/cloudwalk/machine-setup/internal/components"
+)
+
+type spyPushable struct {
+	name string
+	log  *[]string
+	err  error
+}
+
+func (c *spyPushable) Name() string { return c.name }
+func (c *spyPushable) Pull() error  { return nil }
+func (c *spyPushable) Push() error {
+	*c.log = append(*c.log, c.name)
+	return c.err
+}
+
+type spyVCS struct {
+	dirty     bool
+	committed []string
+	paths     []string
+}
+
+func (g *spyVCS) HasChanges(...string) (bool, error) { return g.dirty, nil }
+func (g *spyVCS) Status(w io.Writer, _ ...string) error {
+	fmt.Fprintln(w, "modified: zsh/zshrc")
+	return nil
+}
+func (g *spyVCS) Commit(message string, paths []string, _, _ io.Writer) error {
+	g.committed = append(g.committed, message)
+	g.paths = paths
+	return nil
+}
+
+type fixedConfirmer struct {
+	answer bool
+	asked  int
+}
+
+func (c *fixedConfirmer) Confirm(string) (bool, error) { c.asked++; return c.answer, nil }
+
+var _ = Describe("Push.Run", func() {
+	var (
+		log     []string
+		git     *spyVCS
+		confirm *fixedConfirmer
+		stdout  *bytes.Buffer
+		stderr  *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		log = []string{}
+		git = &spyVCS{dirty: true}
+		confirm = &fixedConfirmer{answer: true}
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+	})
+
+	newPush := func(errs map[string]error, names ...string) *cmd.Push {
+		comps := make([]components.Pushable, len(names))
+		for i, n := range names {
+			comps[i] = &spyPushable{name: n, log: &log, err: errs[n]}
+		}
+		return &cmd.Push{
+			Components: comps,
+			Paths:      names,
+			Git:        git,
+			Confirm:    confirm,
+			Message:    "sync",
+			Stdout:     stdout,
+			Stderr:     stderr,
+		}
+	}
+
+	It("pushes every component, shows git status, and commits once confirmed", func() {
+		Expect(newPush(nil, "vim", "zsh").Run()).To(Succeed())
+
+		Expect(log).To(Equal([]string{"vim", "zsh"}))
+		Expect(stdout.String()).To(ContainSubstring("modified: zsh/zshrc"))
+		Expect(git.committed).To(Equal([]string{"sync"}))
+		Expect(git.paths).To(Equal([]string{"vim", "zsh"}))
+	})
+
+	It("does not commit when the user declines", func() {
+		confirm.answer = false
+
+		Expect(newPush(nil, "vim").Run()).To(Succeed())
+
+		Expect(git.committed).To(BeEmpty())
+		Expect(stdout.String()).To(ContainSubstring("Cancelled"))
+	})
+
+	It("skips the prompt when the repo has no changes", func() {
+		git.dirty = false
+
+		Expect(newPush(nil, "vim").Run()).To(Succeed())
+
+		Expect(confirm.asked).To(Equal(0))
+		Expect(git.committed).To(BeEmpty())
+	})
+
+	It("aborts before committing when a component fails", func() {
+		err := newPush(map[string]error{"zsh": fmt.Errorf("zshrc missing")}, "vim", "zsh", "nvim").Run()
+
+		Expect(err).To(MatchError(ContainSubstring("zsh")))
+		Expect(log).To(Equal([]string{"vim", "zsh", "nvim"}))
+		Expect(stderr.String()).To(ContainSubstring("zshrc missing"))
+		Expect(confirm.asked).To(Equal(0))
+		Expect(git.committed).To(BeEmpty())
+	})
+})
@@ -1,11 +1,20 @@
 package cmd
 
 import (
+	"github.com/cloudwalk/machine-setup/internal/config"
 	"github.com/spf13/cobra"
 )
 
 var cfgFile string
 
+// configPath is --config when given, else the default config location.
+func configPath() string {
+	if cfgFile != "" {
+		return cfgFile
+	}
+	return config.DefaultConfigPath()
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "machine-setup",
 	Short: "CloudWalk machine setup CLI",
@@ -23,4 +32,12 @@ func init() {
 		"config file (default: ~/.config/.machine-setup/config.yaml)",
 	)
 	rootCmd.AddCommand(setupCmd)
+	rootCmd.AddCommand(pullCmd)
+	rootCmd.AddCommand(pushCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(backupsCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(cacheCmd)
 }
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudwalk/machine-setup/internal/config"
+)
+
+// SetupOptions are the `setup` flags that decide how tools are selected.
+type SetupOptions struct {
+	ConfigPath   string
+	DryRun       bool
+	Yes          bool     // select every tool, unless Tools or FromConfig does, without prompting
+	Tools        []string // select exactly these tools
+	ExcludeTools []string // never select these, whatever else is chosen
+	FromConfig   bool     // select the packages saved in ConfigPath
+	Interactive  bool     // stdin is a terminal (forms.Interactive)
+	Jobs         int      // installs to run at once; 1 streams each install live
+	Batch        bool     // one brew install and one apt install for everything
+	// InsecureSkipVerify installs downloads and scripts whose signatures
+	// are not checked.
+	InsecureSkipVerify bool
+	Offline            bool // install only from the download cache
+}
+
+// Selection returns the Welcomer and ToolPicker for o. Any of --yes, --tools
+// or --from-config makes setup non-interactive. So does a missing terminal,
+// in which case one of them is required: headless runs must say what to
+// install rather than get every tool by default. --tools and --from-config
+// win over --yes, which then only skips setup's other prompts.
+func (o SetupOptions) Selection() (Welcomer, ToolPicker, error) {
+	switch {
+	case len(o.Tools) > 0:
+		return NoWelcome{}, FixedPicker{Tools: o.Tools, Exclude: o.ExcludeTools}, nil
+	case o.FromConfig:
+		cfg, err := config.Read(o.ConfigPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading config: %w", err)
+		}
+		if len(cfg.Packages) == 0 {
+			return nil, nil, fmt.Errorf("--from-config: no packages saved in %s", o.ConfigPath)
+		}
+		saved := make([]string, len(cfg.Packages))
+		for i, p := range cfg.Packages {
+			saved[i] = p.Name
+		}
+		return NoWelcome{}, FixedPicker{Tools: saved, Exclude: o.ExcludeTools, AllowUnknown: true}, nil
+	case o.Yes:
+		return NoWelcome{}, FixedPicker{Exclude: o.ExcludeTools}, nil
+	case !o.Interactive:
+		return nil, nil, fmt.Errorf("stdin is not a terminal: pass --yes, --tools or --from-config to choose tools non-interactively")
+	}
+	return FormsWelcomer{}, FormsPicker{Exclude: o.ExcludeTools}, nil
+}
+
+// NoWelcome is the Welcomer for non-interactive runs.
+type NoWelcome struct{}
+
+func (NoWelcome) Show() error { return nil }
+
+// FixedPicker is the non-interactive ToolPicker: it selects Tools (every
+// offered tool when empty) minus Exclude, in offered order. Names that are
+// not offered are an error unless AllowUnknown is set, which --from-config
+// uses so a config saved on another OS still applies.
+type FixedPicker struct {
+	Tools        []string
+	Exclude      []string
+	AllowUnknown bool
+}
+
+func (p FixedPicker) Pick(offered []string, _ map[string]string) ([]string, error) {
+	if err := checkOffered(p.Exclude, offered); err != nil {
+		return nil, err
+	}
+	if !p.AllowUnknown {
+		if err := checkOffered(p.Tools, offered); err != nil {
+			return nil, err
+		}
+	}
+	want := stringSet(p.Tools)
+	drop := stringSet(p.Exclude)
+	var out []string
+	for _, n := range offered {
+		if (len(want) == 0 || want[n]) && !drop[n] {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+// checkOffered rejects names the registry does not offer, so a typo in a
+// flag cannot silently select (or exclude) nothing.
+func checkOffered(names, offered []string) error {
+	known := stringSet(offered)
+	for _, n := range names {
+		if !known[n] {
+			return fmt.Errorf("unknown tool %q (available: %s)", n, strings.Join(offered, ", "))
+		}
+	}
+	return nil
+}
+
+// without returns names minus drop, preserving order.
+func without(names, drop []string) []string {
+	skip := stringSet(drop)
+	var out []string
+	for _, n := range names {
+		if !skip[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/cmd"
+)
+
+var _ = Describe("SetupOptions.Selection", func() {
+	offered := []string{"neovim", "jq", "gh", "go"}
+
+	pick := func(o cmd.SetupOptions) ([]string, error) {
+		welcome, picker, err := o.Selection()
+		if err != nil {
+			return nil, err
+		}
+		Expect(welcome).To(Equal(cmd.NoWelcome{}))
+		return picker.Pick(offered, nil)
+	}
+
+	It("prompts with the forms on a terminal, minus excluded tools", func() {
+		welcome, picker, err := cmd.SetupOptions{Interactive: true, ExcludeTools: []string{"gh"}}.Selection()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(welcome).To(Equal(cmd.FormsWelcomer{}))
+		Expect(picker).To(Equal(cmd.FormsPicker{Exclude: []string{"gh"}}))
+	})
+
+	It("requires a selection flag without a terminal instead of installing everything", func() {
+		_, _, err := cmd.SetupOptions{}.Selection()
+		Expect(err).To(MatchError(ContainSubstring("pass --yes, --tools or --from-config")))
+	})
+
+	It("selects every tool but the excluded ones with --yes", func() {
+		Expect(pick(cmd.SetupOptions{Yes: true, ExcludeTools: []string{"go"}})).To(Equal([]string{"neovim", "jq", "gh"}))
+	})
+
+	It("selects exactly --tools, in registry order, even on a terminal", func() {
+		Expect(pick(cmd.SetupOptions{Interactive: true, Tools: []string{"gh", "jq"}})).To(Equal([]string{"jq", "gh"}))
+	})
+
+	It("selects exactly --tools when --yes is given too", func() {
+		Expect(pick(cmd.SetupOptions{Yes: true, Tools: []string{"gh"}})).To(Equal([]string{"gh"}))
+	})
+
+	It("rejects unknown tool names", func() {
+		_, err := pick(cmd.SetupOptions{Tools: []string{"jqq"}})
+		Expect(err).To(MatchError(ContainSubstring(`unknown tool "jqq"`)))
+		_, err = pick(cmd.SetupOptions{Yes: true, ExcludeTools: []string{"nope"}})
+		Expect(err).To(MatchError(ContainSubstring(`unknown tool "nope"`)))
+	})
+
+	Describe("--from-config", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		})
+
+		It("reuses the saved packages, ignoring ones this OS does not offer", func() {
+			Expect(os.WriteFile(path, []byte("packages:\n  - name: jq\n  - name: terraform\n  - name: neovim\n"), 0o644)).To(Succeed())
+			Expect(pick(cmd.SetupOptions{FromConfig: true, ConfigPath: path})).To(Equal([]string{"neovim", "jq"}))
+		})
+
+		It("fails when nothing was saved", func() {
+			_, _, err := cmd.SetupOptions{FromConfig: true, ConfigPath: path}.Selection()
+			Expect(err).To(MatchError(ContainSubstring("no packages saved")))
+		})
+	})
+})
@@ -9,15 +9,17 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"runtime"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/cloudwalk/machine-setup/internal/components"
 	"github.com/cloudwalk/machine-setup/internal/config"
 	"github.com/cloudwalk/machine-setup/internal/forms"
+	"github.com/cloudwalk/machine-setup/internal/fsutil"
 	"github.com/cloudwalk/machine-setup/internal/pkg"
-	"github.com/cloudwalk/machine-setup/internal/pkg/apt"
-	"github.com/cloudwalk/machine-setup/internal/pkg/brew"
-	"github.com/cloudwalk/machine-setup/internal/pkg/rvm"
+	"github.com/cloudwalk/machine-setup/internal/pkg/release"
+	"github.com/cloudwalk/machine-setup/internal/pkg/verify"
 	"github.com/cloudwalk/machine-setup/internal/repo"
 	"github.com/cloudwalk/machine-setup/internal/shell"
 	"github.com/spf13/cobra"
@@ -32,9 +34,10 @@ type Welcomer interface {
 }
 
 // ToolPicker presents the multi-select install form and returns the chosen
-// names (a subset of the offered names).
+// names (a subset of the offered names). notes annotates offered names with
+// what is already on the machine, e.g. "installed 1.7.1".
 type ToolPicker interface {
-	Pick(offered []string) ([]string, error)
+	Pick(offered []string, notes map[string]string) ([]string, error)
 }
 
 // ConfigStore loads, saves, and reports the path of the persistent config.
@@ -45,26 +48,34 @@ type ConfigStore interface {
 }
 
 // Registry exposes both the curated install list (for the installer) and the
-// list's names (for the picker form).
+// list's names (for the picker form), plus what each tool depends on.
 type Registry interface {
 	Installables() []pkg.Installable
 	Names() []string
+	Dependencies() map[string][]string
+	// Strategies maps tool names to how they install, e.g. "apt, else
+	// script".
+	Strategies() map[string]string
 }
 
-// PackageInstaller installs the named subset of available installables.
-// Failures are reported inline; the loop continues.
+// PackageInstaller installs ordered, which lists dependencies before their
+// dependents. Failures are reported inline; the loop continues, skipping
+// the tools whose dependencies (per deps) failed.
 type PackageInstaller interface {
-	InstallAll(available []pkg.Installable, selected []string)
+	InstallAll(ordered []pkg.Installable, deps map[string][]string)
 }
 
-// Installer is the single-op contract for things like oh-my-zsh and powerlevel10k.
+// Installer is the single-op contract for things like oh-my-zsh and
+// powerlevel10k. Plan describes what Install would do, for --dry-run.
 type Installer interface {
 	Install() error
+	Plan() []string
 }
 
-// Puller pulls every dotfile component, reporting failures inline.
+// Puller pulls every dotfile component, reporting failures inline. The
+// returned error summarizes which components failed (nil when all succeeded).
 type Puller interface {
-	PullAll()
+	PullAll() error
 }
 
 // ── Setup ────────────────────────────────────────────────────────────────
@@ -81,6 +92,19 @@ type Setup struct {
 	OhMyZsh   Installer
 	P10k      Installer
 	Pull      Puller
+	// Sources adds the config's third-party apt repositories before any
+	// package installs. Nil where there is no apt to manage them.
+	Sources Installer
+	// DryRun prints the plan instead of installing, saving the config, or
+	// running installers. Pull must then be built from dry-run components
+	// (components.Options.DryRun), which describe their copies themselves.
+	DryRun bool
+	// Batch merges the brew formulas, and the apt packages, into one
+	// package-manager invocation each (pkg.Batch).
+	Batch bool
+	// Resolved maps tools whose configured version is "latest" or a range
+	// to the release it resolved to, recorded in the saved config.
+	Resolved map[string]string
 
 	Stdout io.Writer
 	Stderr io.Writer
@@ -94,26 +118,56 @@ func (s *Setup) Run() error {
 		return err
 	}
 
+	// Detection only queries package managers, so dry runs do it too.
+	available := s.Registry.Installables()
+	detected := pkg.DetectAll(available)
+
+	if s.DryRun {
+		selected, err := s.pickTools(detected)
+		if err != nil {
+			return err
+		}
+		return s.plan(detected, selected)
+	}
+
 	cfg, err := s.Config.Load()
 	if err != nil {
 		return fmt.Errorf("initializing config: %w", err)
 	}
 
-	available := s.Registry.Installables()
-	selected, err := s.pickTools()
+	selected, err := s.pickTools(detected)
 	if err != nil {
 		return err
 	}
 
-	cfg.Packages = packagesFromNames(selected)
+	cfg.Packages = packagesFromNames(selected, cfg.Packages)
+	for i, p := range cfg.Packages {
+		if v, ok := s.Resolved[p.Name]; ok {
+			cfg.Packages[i].Resolved = v
+		}
+	}
 	if err := s.Config.Save(cfg); err != nil {
 		return fmt.Errorf("saving config: %w", err)
 	}
 
 	s.announceConfig(cfg.Architecture)
-	s.Installer.InstallAll(available, selected)
-	s.runShellInstaller("oh-my-zsh", s.OhMyZsh)
-	s.runShellInstaller("powerlevel10k", s.P10k)
+	deps := s.Registry.Dependencies()
+	s.syncSources()
+	wanted := s.withDependencies(selected)
+	ordered, err := pkg.Order(available, deps, s.skipCurrent(detected, wanted))
+	if err != nil {
+		return err
+	}
+	if s.Batch {
+		ordered = pkg.Batch(ordered, deps)
+	}
+	s.Installer.InstallAll(ordered, deps)
+	s.reportMismatches(available, wanted)
+	if s.runShellInstaller("oh-my-zsh", s.OhMyZsh) {
+		s.runShellInstaller("powerlevel10k", s.P10k)
+	} else {
+		fmt.Fprintln(s.Stderr, "  powerlevel10k: skipped because dependency oh-my-zsh failed")
+	}
 	s.runPull()
 
 	fmt.Fprintln(s.Stdout, "\nSetup complete.")
@@ -130,30 +184,220 @@ func (s *Setup) greet() error {
 	return fmt.Errorf("welcome: %w", err)
 }
 
-// pickTools offers the registry's names to the picker; user-aborted is non-fatal.
-func (s *Setup) pickTools() ([]string, error) {
-	selected, err := s.Picker.Pick(s.Registry.Names())
+// pickTools offers the registry's names to the picker, annotated with how
+// each installs and what is already installed, e.g. "via apt; installed
+// 1.7.1"; user-aborted is non-fatal.
+func (s *Setup) pickTools(detected []pkg.Detected) ([]string, error) {
+	strategies := s.Registry.Strategies()
+	notes := make(map[string]string, len(detected))
+	for _, d := range detected {
+		var parts []string
+		if via := strategies[d.Name()]; via != "" {
+			parts = append(parts, "via "+via)
+		}
+		if note := stateNote(d); note != "" {
+			parts = append(parts, note)
+		}
+		if len(parts) > 0 {
+			notes[d.Name()] = strings.Join(parts, "; ")
+		}
+	}
+	selected, err := s.Picker.Pick(s.Registry.Names(), notes)
 	if err != nil && err.Error() != "user aborted" {
 		return nil, fmt.Errorf("tool picker: %w", err)
 	}
 	return selected, nil
 }
 
+// stateNote describes what is on the machine for d: "installed 1.7.1",
+// "installed 0.10.0, want 0.11.6", or "" when it is not installed.
+func stateNote(d pkg.Detected) string {
+	switch {
+	case d.Err != nil:
+		return "cannot detect: " + d.Err.Error()
+	case !d.State.Installed:
+		return ""
+	}
+	note := "installed"
+	if d.State.Version != "" {
+		note += " " + d.State.Version
+	}
+	if !d.Current() {
+		note += ", want " + d.Version()
+	}
+	return note
+}
+
+// skipCurrent drops the selected tools that are already installed at the
+// wanted version, saying so for each.
+func (s *Setup) skipCurrent(detected []pkg.Detected, selected []string) []string {
+	picked := stringSet(selected)
+	current := map[string]bool{}
+	for _, d := range detected {
+		if picked[d.Name()] && d.Current() {
+			current[d.Name()] = true
+			fmt.Fprintf(s.Stdout, "%s already %s, skipping\n", d.Name(), stateNote(d))
+		}
+	}
+	var out []string
+	for _, n := range selected {
+		if !current[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// reportMismatches re-detects the selected tools that have a pinned version
+// and warns about each that is still not at it after installing.
+func (s *Setup) reportMismatches(available []pkg.Installable, selected []string) {
+	picked := stringSet(selected)
+	var pinned []pkg.Installable
+	for _, inst := range available {
+		if picked[inst.Name()] && inst.Version() != "" {
+			pinned = append(pinned, inst)
+		}
+	}
+	for _, d := range pkg.DetectAll(pinned) {
+		switch {
+		case d.Err != nil:
+			fmt.Fprintf(s.Stderr, "warning: %s: cannot check pinned version %s: %v\n", d.Name(), d.Version(), d.Err)
+		case !d.State.Installed:
+			fmt.Fprintf(s.Stderr, "warning: %s: pinned version %s is not installed\n", d.Name(), d.Version())
+		case !d.Current():
+			fmt.Fprintf(s.Stderr, "warning: %s: installed %s differs from pinned version %s\n", d.Name(), d.State.Version, d.Version())
+		}
+	}
+}
+
 func (s *Setup) announceConfig(arch string) {
 	fmt.Fprintf(s.Stdout, "Config written to %s\n", s.Config.Path())
 	fmt.Fprintf(s.Stdout, "Detected architecture: %s\n", arch)
 }
 
-func (s *Setup) runShellInstaller(name string, i Installer) {
+// withDependencies adds the tools selected ones depend on, saying which.
+func (s *Setup) withDependencies(selected []string) []string {
+	wanted, addedFor := pkg.WithDependencies(s.Registry.Dependencies(), selected)
+	for _, n := range wanted[len(selected):] {
+		fmt.Fprintf(s.Stdout, "Adding %s, which %s depends on\n", n, addedFor[n])
+	}
+	return wanted
+}
+
+// syncSources brings the managed apt sources in line with the config. A
+// failure is reported but not fatal: packages from the distribution's own
+// repositories still install.
+func (s *Setup) syncSources() {
+	if s.Sources == nil {
+		return
+	}
+	if err := s.Sources.Install(); err != nil {
+		fmt.Fprintf(s.Stderr, "  apt sources: %v\n", err)
+	}
+}
+
+// runShellInstaller installs i and reports whether it succeeded.
+func (s *Setup) runShellInstaller(name string, i Installer) bool {
 	fmt.Fprintf(s.Stdout, "\nInstalling %s...\n", name)
 	if err := i.Install(); err != nil {
 		fmt.Fprintf(s.Stderr, "  %s: %v\n", name, err)
+		return false
 	}
+	return true
 }
 
+// runPull pulls every component. Failures were already reported inline by
+// the Puller, so the summary error is dropped — a partial pull is recoverable.
 func (s *Setup) runPull() {
 	fmt.Fprintln(s.Stdout, "\nPulling configuration files...")
-	s.Pull.PullAll()
+	_ = s.Pull.PullAll()
+}
+
+// plan prints what Run would do for selected without changing anything.
+func (s *Setup) plan(detected []pkg.Detected, selected []string) error {
+	fmt.Fprintln(s.Stdout, "Dry run: nothing will be installed, written or backed up.")
+	fmt.Fprintf(s.Stdout, "\nWould save %d package(s) to %s\n", len(selected), s.Config.Path())
+
+	if s.Sources != nil {
+		if steps := s.Sources.Plan(); len(steps) > 0 {
+			fmt.Fprintln(s.Stdout, "\nApt sources:")
+			for _, step := range steps {
+				fmt.Fprintf(s.Stdout, "  %s\n", step)
+			}
+		}
+	}
+
+	fmt.Fprintln(s.Stdout, "\nPackages:")
+	deps := s.Registry.Dependencies()
+	ordered, err := pkg.Order(s.Registry.Installables(), deps, s.withDependencies(selected))
+	if err != nil {
+		return err
+	}
+	state := make(map[string]pkg.Detected, len(detected))
+	for _, d := range detected {
+		state[d.Name()] = d
+	}
+	batches, batched := s.planBatches(ordered, state, deps)
+	for _, inst := range ordered {
+		if b, ok := batches[inst.Name()]; ok {
+			s.printPlan(b.Name(), b.Plan())
+			continue
+		} else if batched[inst.Name()] {
+			continue
+		}
+		var steps []string
+		if len(deps[inst.Name()]) > 0 {
+			steps = append(steps, "after "+strings.Join(deps[inst.Name()], ", "))
+		}
+		if d := state[inst.Name()]; d.Current() {
+			steps = append(steps, "skip: already "+stateNote(d))
+		} else {
+			steps = append(steps, inst.Plan()...)
+		}
+		s.printPlan(inst.Name(), steps)
+	}
+
+	fmt.Fprintln(s.Stdout, "\nShell installers:")
+	s.printPlan("oh-my-zsh", s.OhMyZsh.Plan())
+	s.printPlan("powerlevel10k", s.P10k.Plan())
+
+	fmt.Fprintln(s.Stdout, "\nConfiguration files:")
+	_ = s.Pull.PullAll()
+	return nil
+}
+
+// planBatches returns, with --batch, the batches Run would install: keyed by
+// their first member, which is where the plan shows them, along with the set
+// of every batched tool.
+func (s *Setup) planBatches(ordered []pkg.Installable, state map[string]pkg.Detected, deps map[string][]string) (map[string]pkg.Installable, map[string]bool) {
+	batches, batched := map[string]pkg.Installable{}, map[string]bool{}
+	if !s.Batch {
+		return batches, batched
+	}
+	var pending []pkg.Installable
+	for _, inst := range ordered {
+		if !state[inst.Name()].Current() {
+			pending = append(pending, inst)
+		}
+	}
+	for _, inst := range pkg.Batch(pending, deps) {
+		members := pkg.Members(inst)
+		if len(members) < 2 {
+			continue
+		}
+		batches[members[0]] = inst
+		for _, m := range members {
+			batched[m] = true
+		}
+	}
+	return batches, batched
+}
+
+func (s *Setup) printPlan(name string, steps []string) {
+	fmt.Fprintf(s.Stdout, "  %s\n", name)
+	for _, step := range steps {
+		fmt.Fprintf(s.Stdout, "    %s\n", step)
+	}
 }
 
 func (s *Setup) printNextSteps() {
@@ -163,11 +407,20 @@ func (s *Setup) printNextSteps() {
 	fmt.Fprintln(s.Stdout, "  • Run `ghcup tui` to pick GHC / Cabal / HLS versions")
 }
 
-// packagesFromNames builds the persistable config slice from selected names.
-func packagesFromNames(names []string) []config.Package {
+// packagesFromNames builds the persistable config slice from selected names,
+// keeping the version pins of packages that were already saved, and what a
+// pin that is still "latest" or a range last resolved to.
+func packagesFromNames(names []string, saved []config.Package) []config.Package {
+	prev := make(map[string]config.Package, len(saved))
+	for _, p := range saved {
+		prev[p.Name] = p
+	}
 	out := make([]config.Package, len(names))
 	for i, n := range names {
-		out[i] = config.Package{Name: n}
+		out[i] = config.Package{Name: n, Version: prev[n].Version}
+		if release.IsSpec(prev[n].Version) {
+			out[i].Resolved = prev[n].Resolved
+		}
 	}
 	return out
 }
@@ -179,11 +432,16 @@ type FormsWelcomer struct{}
 
 func (FormsWelcomer) Show() error { return forms.ShowWelcome() }
 
-// FormsPicker wraps forms.ShowInstallForm.
-type FormsPicker struct{}
+// FormsPicker wraps forms.ShowInstallForm, leaving Exclude out of the form.
+type FormsPicker struct {
+	Exclude []string
+}
 
-func (FormsPicker) Pick(offered []string) ([]string, error) {
-	return forms.ShowInstallForm(offered)
+func (p FormsPicker) Pick(offered []string, notes map[string]string) ([]string, error) {
+	if err := checkOffered(p.Exclude, offered); err != nil {
+		return nil, err
+	}
+	return forms.ShowInstallForm(without(offered, p.Exclude), notes)
 }
 
 // FileConfigStore reads/writes the YAML config at a fixed path.
@@ -194,26 +452,61 @@ func (s FileConfigStore) Load() (*config.Config, error) { return config.Init(s.p
 func (s FileConfigStore) Save(cfg *config.Config) error { return config.Save(s.path, cfg) }
 func (s FileConfigStore) Path() string                  { return s.path }
 
-// IterativeInstaller is the production PackageInstaller. It filters the
-// available list to selected names, then calls Install on each.
+// IterativeInstaller is the production PackageInstaller. It calls Install on
+// each installable in order, skipping those behind a failed dependency.
 type IterativeInstaller struct {
 	Stdout io.Writer
 	Stderr io.Writer
 }
 
-func (p IterativeInstaller) InstallAll(available []pkg.Installable, selected []string) {
-	picked := stringSet(selected)
-	for _, inst := range available {
-		if !picked[inst.Name()] {
+func (p IterativeInstaller) InstallAll(ordered []pkg.Installable, deps map[string][]string) {
+	failed := map[string]string{}
+	for _, inst := range ordered {
+		name := inst.Name()
+		if cause := pkg.FailedDependency(deps[name], failed); cause != "" {
+			failed[name] = cause
+			fmt.Fprintf(p.Stderr, "  %s: skipped because dependency %s failed\n", name, cause)
 			continue
 		}
-		fmt.Fprintf(p.Stdout, "Installing %s...\n", inst.Name())
-		if err := inst.Install(p.Stdout, p.Stderr); err != nil {
-			fmt.Fprintf(p.Stderr, "  %s: %v\n", inst.Name(), err)
+		fmt.Fprintf(p.Stdout, "Installing %s...\n", name)
+		settle(inst, inst.Install(p.Stdout, p.Stderr), nil, failed, p.Stderr)
+	}
+	summarize(failed, p.Stderr)
+}
+
+// settle records how installing inst went — per member for a batch — marking
+// each tool done and each failure in failed (mapped to its root cause), and
+// prints the failures and skips. done may be nil.
+func settle(inst pkg.Installable, err error, done map[string]bool, failed map[string]string, stderr io.Writer) {
+	for _, o := range pkg.Outcomes(inst, err) {
+		if done != nil {
+			done[o.Name] = true
+		}
+		switch {
+		case o.Cause != "":
+			failed[o.Name] = o.Cause
+			fmt.Fprintf(stderr, "  %s: skipped because dependency %s failed\n", o.Name, o.Cause)
+		case o.Err != nil:
+			failed[o.Name] = o.Name
+			fmt.Fprintf(stderr, "  %s: %v\n", o.Name, o.Err)
 		}
 	}
 }
 
+// summarize prints which tools were not installed, failed or skipped, once
+// every install is done.
+func summarize(failed map[string]string, stderr io.Writer) {
+	if len(failed) == 0 {
+		return
+	}
+	names := make([]string, 0, len(failed))
+	for name := range failed {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	fmt.Fprintf(stderr, "\n%d not installed: %s\n", len(names), strings.Join(names, ", "))
+}
+
 func stringSet(s []string) map[string]bool {
 	set := make(map[string]bool, len(s))
 	for _, v := range s {
@@ -230,13 +523,19 @@ type SequentialPuller struct {
 	Stderr     io.Writer
 }
 
-func (p SequentialPuller) PullAll() {
+func (p SequentialPuller) PullAll() error {
+	var failed []string
 	for _, c := range p.Components {
 		fmt.Fprintf(p.Stdout, "  → %s\n", c.Name())
 		if err := c.Pull(); err != nil {
 			fmt.Fprintf(p.Stderr, "  %s: %v\n", c.Name(), err)
+			failed = append(failed, c.Name())
 		}
 	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed components: %s", strings.Join(failed, ", "))
+	}
+	return nil
 }
 
 // ── Composition root ─────────────────────────────────────────────────────
@@ -244,44 +543,50 @@ func (p SequentialPuller) PullAll() {
 // NewSetup wires Setup with its collaborators — this is the only place in
 // the cli that assembles the dependency graph. The cobra RunE calls it; tests
 // either call it too or construct Setup directly with their own collaborators.
-func NewSetup(stdout, stderr io.Writer, cfgPath string) (*Setup, error) {
-	home, err := os.UserHomeDir()
+func NewSetup(stdout, stderr io.Writer, opts SetupOptions) (*Setup, error) {
+	welcome, picker, err := opts.Selection()
 	if err != nil {
-		return nil, fmt.Errorf("locating home dir: %w", err)
+		return nil, err
 	}
-	root, err := repo.Find()
+	compOpts, err := newComponentOptions(stdout, stderr)
 	if err != nil {
-		return nil, fmt.Errorf("locating repo root: %w", err)
+		return nil, err
 	}
-
-	compOpts := components.Options{
-		RepoRoot:   root,
-		Home:       home,
-		BackupRoot: filepath.Join(root, "backups"),
-		Stdout:     stdout,
-		Stderr:     stderr,
+	compOpts.DryRun = opts.DryRun
+	home := compOpts.Home
+	cfg, err := config.Read(opts.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	if opts.InsecureSkipVerify {
+		fmt.Fprintln(stderr, "WARNING: --insecure-skip-verify: signatures will NOT be checked, and install scripts will run unreviewed")
+	}
+	downloads := downloadCache(opts.Offline)
+	if opts.Offline {
+		fmt.Fprintf(stdout, "Offline: downloads, scripts and clones come from %s only; brew, apt, dnf and pacman installs are not run\n", downloads.Dir)
+	}
+	trust := scriptTrust(opts, stdout)
+	registry, resolved, err := newRegistry(compOpts, cfg.Packages, trust, downloads)
+	if err != nil {
+		return nil, err
 	}
 	p10kDir := filepath.Join(home, ".oh-my-zsh", "custom", "themes", "powerlevel10k")
 
 	return &Setup{
-		Welcome:   FormsWelcomer{},
-		Picker:    FormsPicker{},
-		Config:    NewFileConfigStore(cfgPath),
-		Registry: pkg.NewRegistryFactory(
-			brew.DefaultRunner(),
-			apt.DefaultRunner(),
-			rvm.NewInstaller(filepath.Join(home, ".rvm"), rvm.DefaultRunner()),
-		).For(runtime.GOOS),
-		Installer: IterativeInstaller{Stdout: stdout, Stderr: stderr},
+		Welcome:   welcome,
+		Picker:    picker,
+		Config:    NewFileConfigStore(opts.ConfigPath),
+		Registry:  registry,
+		Installer: newInstaller(opts.Jobs, stdout, stderr),
 		OhMyZsh: shell.OhMyZshInstaller{
 			Dir:    filepath.Join(home, ".oh-my-zsh"),
-			Runner: shell.DefaultRunner(),
+			Runner: shell.DefaultRunner(trust, downloads.Fetcher(verify.DefaultFetcher())),
 			Stdout: stdout,
 			Stderr: stderr,
 		},
 		P10k: shell.Powerlevel10kInstaller{
 			Dir:    p10kDir,
-			Runner: shell.DefaultP10kRunner(p10kDir),
+			Runner: shell.DefaultP10kRunner(p10kDir, downloads),
 			Stdout: stdout,
 			Stderr: stderr,
 		},
@@ -290,27 +595,114 @@ func NewSetup(stdout, stderr io.Writer, cfgPath string) (*Setup, error) {
 			Stdout:     stdout,
 			Stderr:     stderr,
 		},
-		Stdout: stdout,
-		Stderr: stderr,
+		Sources:  newSources(cfg.Sources, opts.Offline, stdout, stderr),
+		DryRun:   opts.DryRun,
+		Batch:    opts.Batch,
+		Resolved: resolved,
+		Stdout:   stdout,
+		Stderr:   stderr,
 	}, nil
 }
 
+// newComponentOptions resolves HOME and the repo root into the Options every
+// dotfile component is built from. Shared by all commands that touch them.
+func newComponentOptions(stdout, stderr io.Writer) (components.Options, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return components.Options{}, fmt.Errorf("locating home dir: %w", err)
+	}
+	root, err := repo.Find()
+	if err != nil {
+		return components.Options{}, fmt.Errorf("locating repo root: %w", err)
+	}
+	return components.Options{
+		RepoRoot:   root,
+		Home:       home,
+		BackupRoot: filepath.Join(root, "backups"),
+		Run:        runInfo(root),
+		Stdout:     stdout,
+		Stderr:     stderr,
+	}, nil
+}
+
+// runInfo describes the current invocation for backup snapshot metadata. The
+// commit is best-effort: a repo without git history just records none.
+func runInfo(root string) fsutil.RunInfo {
+	commit, _ := repo.NewGit(root).Head()
+	return fsutil.RunInfo{
+		Time:    time.Now(),
+		Command: strings.TrimSpace("machine-setup " + strings.Join(os.Args[1:], " ")),
+		Commit:  commit,
+	}
+}
+
 // ── Cobra command ────────────────────────────────────────────────────────
 
+var setupOpts SetupOptions
+
 var setupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "Initialize this machine with CloudWalk defaults",
 	Long: `Display a welcome greeting, select dev tools to install, initialize
-the machine-setup config, and install selected packages.`,
+the machine-setup config, and install selected packages.
+
+With --dry-run, print the plan instead: the exact brew/apt commands, which
+shell installers would be skipped, and which files would be backed up and
+overwritten.
+
+--yes, --tools or --from-config choose the tools without prompting, for VMs
+and CI runners. Without a terminal on stdin one of them is required. With
+--tools or --from-config, those choose the tools and --yes only answers the
+other prompts, such as approving an install script that is not pinned.
+
+Installs run --jobs at a time once their dependencies are done. brew installs
+still run one at a time, as do apt installs; downloads and installer scripts
+run alongside them. Each tool's output is printed when it finishes; use
+--jobs 1 to watch installs live.
+
+--batch installs all brew formulas in one brew install, and all apt packages
+in one apt-get install. If a batch fails, its packages are retried one by one so
+the failure is pinned on the package at fault.
+
+Signed downloads (terraform's checksums, the RVM bootstrap) are checked
+against signing keys pinned by fingerprint in the repo, and a bad signature
+fails that tool's install. Install scripts that are not signed, such as oh-my-zsh's,
+are pinned at a commit and checked against a SHA-256. One that is not pinned
+yet is shown, in full the first time and as a diff from the copy approved
+before after that, and runs only if you approve it in a terminal. Without a
+terminal, --yes runs it the first time with a warning, but not once it has
+changed.
+--insecure-skip-verify skips all of these checks, with a warning for each.
+
+Downloads, install scripts and the Powerlevel10k clone are cached under
+$XDG_CACHE_HOME/machine-setup (~/.cache/machine-setup by default). With
+--offline, setup uses only what is cached there and fails at once for
+anything that is not. It does not run brew, apt-get, dnf or pacman installs
+or refresh their indexes either: those fail at once, and a tool with a
+cached download to fall back on installs from that. See machine-setup cache.`,
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		cfgPath := config.DefaultConfigPath()
-		if cfgFile != "" {
-			cfgPath = cfgFile
-		}
-		s, err := NewSetup(cmd.OutOrStdout(), cmd.ErrOrStderr(), cfgPath)
+		opts := setupOpts
+		opts.ConfigPath = configPath()
+		opts.Interactive = forms.Interactive()
+		s, err := NewSetup(cmd.OutOrStdout(), cmd.ErrOrStderr(), opts)
 		if err != nil {
 			return err
 		}
 		return s.Run()
 	},
 }
+
+func init() {
+	f := setupCmd.Flags()
+	f.BoolVar(&setupOpts.DryRun, "dry-run", false, "print what setup would do without changing anything")
+	f.BoolVarP(&setupOpts.Yes, "yes", "y", false, "install every available tool without prompting (with --tools or --from-config, only skip the prompts)")
+	f.StringSliceVar(&setupOpts.Tools, "tools", nil, "install exactly these tools, without prompting")
+	f.StringSliceVar(&setupOpts.ExcludeTools, "exclude-tools", nil, "never install these tools")
+	f.BoolVar(&setupOpts.FromConfig, "from-config", false, "install the packages saved in the config, without prompting")
+	f.IntVarP(&setupOpts.Jobs, "jobs", "j", DefaultJobs, "number of installs to run at once")
+	f.BoolVar(&setupOpts.Batch, "batch", false, "install brew formulas and apt packages in one invocation each")
+	f.BoolVar(&setupOpts.InsecureSkipVerify, "insecure-skip-verify", false, "install downloads and scripts without checking their signatures")
+	f.BoolVar(&setupOpts.Offline, "offline", false, "install only from the download cache, without the network")
+	setupCmd.MarkFlagsMutuallyExclusive("tools", "from-config")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -14,6 +15,7 @@ import (
 	"github.com/cloudwalk/machine-setup/internal/components"
 	"github.com/cloudwalk/machine-setup/internal/config"
 	"github.com/cloudwalk/machine-setup/internal/pkg"
+	"github.com/cloudwalk/machine-setup/internal/pkg/script"
 )
 
 // ── Test doubles ─────────────────────────────────────────────────────────
@@ -24,11 +26,12 @@ func (s *spyWelcome) Show() error { s.calls++; return nil }
 
 type spyPicker struct {
 	offered []string
+	notes   map[string]string
 	pick    []string
 }
 
-func (s *spyPicker) Pick(offered []string) ([]string, error) {
-	s.offered = offered
+func (s *spyPicker) Pick(offered []string, notes map[string]string) ([]string, error) {
+	s.offered, s.notes = offered, notes
 	if s.pick != nil {
 		return s.pick, nil
 	}
@@ -52,9 +55,15 @@ func (s *memConfigStore) Load() (*config.Config, error) {
 func (s *memConfigStore) Save(c *config.Config) error { s.cfg = c; return nil }
 func (s *memConfigStore) Path() string                { return s.path }
 
-type fixedRegistry struct{ tools []pkg.Installable }
+type fixedRegistry struct {
+	tools      []pkg.Installable
+	deps       map[string][]string
+	strategies map[string]string
+}
 
-func (r *fixedRegistry) Installables() []pkg.Installable { return r.tools }
+func (r *fixedRegistry) Installables() []pkg.Installable   { return r.tools }
+func (r *fixedRegistry) Dependencies() map[string][]string { return r.deps }
+func (r *fixedRegistry) Strategies() map[string]string     { return r.strategies }
 func (r *fixedRegistry) Names() []string {
 	names := make([]string, len(r.tools))
 	for i, t := range r.tools {
@@ -64,9 +73,12 @@ func (r *fixedRegistry) Names() []string {
 }
 
 type spyInstallable struct {
-	name string
-	log  *[]string
-	err  error
+	name      string
+	log       *[]string
+	err       error
+	installed string // installed version; "" means not installed
+	want      string
+	batch     string // batch key; "" opts out of batching
 }
 
 func (s *spyInstallable) Name() string { return s.name }
@@ -74,25 +86,31 @@ func (s *spyInstallable) Install(_, _ io.Writer) error {
 	*s.log = append(*s.log, s.name)
 	return s.err
 }
+func (s *spyInstallable) Plan() []string  { return []string{"spy install " + s.name} }
+func (s *spyInstallable) Version() string { return s.want }
+func (s *spyInstallable) Detect() (bool, string, error) {
+	return s.installed != "", s.installed, nil
+}
+func (s *spyInstallable) BatchKey() string { return s.batch }
+func (s *spyInstallable) BatchArg() string { return s.name }
+func (s *spyInstallable) InstallBatch(args []string, _, _ io.Writer) error {
+	*s.log = append(*s.log, "batch "+strings.Join(args, " "))
+	return s.err
+}
+func (s *spyInstallable) BatchPlan(args []string) []string {
+	return []string{"spy batch " + strings.Join(args, " ")}
+}
 
 type recordingInstaller struct {
-	available []pkg.Installable
-	selected  []string
-	log       *[]string
-	errs      map[string]error
-	stderr    io.Writer
+	ordered []pkg.Installable
+	log     *[]string
+	errs    map[string]error
+	stderr  io.Writer
 }
 
-func (r *recordingInstaller) InstallAll(available []pkg.Installable, selected []string) {
-	r.available, r.selected = available, selected
-	picked := map[string]bool{}
-	for _, n := range selected {
-		picked[n] = true
-	}
-	for _, inst := range available {
-		if !picked[inst.Name()] {
-			continue
-		}
+func (r *recordingInstaller) InstallAll(ordered []pkg.Installable, _ map[string][]string) {
+	r.ordered = ordered
+	for _, inst := range ordered {
 		*r.log = append(*r.log, inst.Name())
 		if err := r.errs[inst.Name()]; err != nil {
 			fmt.Fprintf(r.stderr, "  %s: %v\n", inst.Name(), err)
@@ -106,6 +124,7 @@ type spyInstaller struct {
 }
 
 func (s *spyInstaller) Install() error { s.calls++; return s.err }
+func (s *spyInstaller) Plan() []string { return []string{"skip: already installed"} }
 
 type spyComponent struct {
 	name string
@@ -124,12 +143,13 @@ type recordingPuller struct {
 	stderr     io.Writer
 }
 
-func (p *recordingPuller) PullAll() {
+func (p *recordingPuller) PullAll() error {
 	for _, c := range p.components {
 		if err := c.Pull(); err != nil {
 			fmt.Fprintf(p.stderr, "  %s: %v\n", c.Name(), err)
 		}
 	}
+	return nil
 }
 
 // ── Fixture ──────────────────────────────────────────────────────────────
@@ -148,6 +168,16 @@ type fixture struct {
 	InstallableNames []string
 	InstallLog       []string
 	InstallErrs      map[string]error
+	// Installed maps tool names to the version already on the machine.
+	Installed map[string]string
+	// Want maps tool names to the version the registry pins.
+	Want map[string]string
+	// Deps maps tool names to the tools they depend on.
+	Deps map[string][]string
+	// BatchKeys maps tool names to the key they batch under.
+	BatchKeys map[string]string
+	// Strategies maps tool names to how the registry installs them.
+	Strategies map[string]string
 
 	ComponentNames []string
 	PullLog        []string
@@ -169,6 +199,8 @@ func newFixture() *fixture {
 		},
 		ComponentNames: []string{"vim", "zsh", "byobu", "nvim", "fonts"},
 		InstallErrs:    map[string]error{},
+		Installed:      map[string]string{},
+		Want:           map[string]string{},
 		ComponentErrs:  map[string]error{},
 		Stdout:         &bytes.Buffer{},
 		Stderr:         &bytes.Buffer{},
@@ -189,7 +221,10 @@ func (f *fixture) assemble() {
 	f.InstallLog = []string{}
 	tools := make([]pkg.Installable, len(f.InstallableNames))
 	for i, n := range f.InstallableNames {
-		tools[i] = &spyInstallable{name: n, log: &f.InstallLog, err: f.InstallErrs[n]}
+		tools[i] = &spyInstallable{
+			name: n, log: &f.InstallLog, err: f.InstallErrs[n],
+			installed: f.Installed[n], want: f.Want[n], batch: f.BatchKeys[n],
+		}
 	}
 	f.Installer = &recordingInstaller{log: &f.InstallLog, errs: f.InstallErrs, stderr: f.Stderr}
 
@@ -204,7 +239,7 @@ func (f *fixture) assemble() {
 		Welcome:   f.Welcome,
 		Picker:    f.Picker,
 		Config:    f.Config,
-		Registry:  &fixedRegistry{tools: tools},
+		Registry:  &fixedRegistry{tools: tools, deps: f.Deps, strategies: f.Strategies},
 		Installer: f.Installer,
 		OhMyZsh:   f.OhMyZsh,
 		P10k:      f.P10k,
@@ -235,6 +270,145 @@ var _ = Describe("Setup.Run", func() {
 		})
 	})
 
+	Describe("installed tools", func() {
+		BeforeEach(func() {
+			f.Installed = map[string]string{"jq": "1.7.1", "neovim": "0.10.0"}
+			f.Want = map[string]string{"neovim": "0.11.6"}
+			f.assemble()
+		})
+
+		It("annotates the picker with what is already installed", func() {
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.Picker.notes).To(Equal(map[string]string{
+				"jq":     "installed 1.7.1",
+				"neovim": "installed 0.10.0, want 0.11.6",
+			}))
+		})
+
+		It("says how each tool installs alongside its state", func() {
+			f.Strategies = map[string]string{"jq": "apt", "rustup": "apt, else script"}
+			f.assemble()
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.Picker.notes).To(Equal(map[string]string{
+				"jq":     "via apt; installed 1.7.1",
+				"neovim": "installed 0.10.0, want 0.11.6",
+				"rustup": "via apt, else script",
+			}))
+		})
+
+		It("skips tools already at the wanted version but still saves them", func() {
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.InstallLog).NotTo(ContainElement("jq"))
+			Expect(f.InstallLog).To(ContainElement("neovim"))
+			Expect(f.Stdout.String()).To(ContainSubstring("jq already installed 1.7.1, skipping"))
+			Expect(f.Config.cfg.Packages).To(ContainElement(config.Package{Name: "jq"}))
+		})
+
+		It("warns when a pinned tool is still at another version after installing", func() {
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.Stderr.String()).To(ContainSubstring("warning: neovim: installed 0.10.0 differs from pinned version 0.11.6"))
+			Expect(f.Stderr.String()).NotTo(ContainSubstring("jq"))
+		})
+
+		It("plans a skip for them on a dry run", func() {
+			f.Setup.DryRun = true
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.Stdout.String()).To(ContainSubstring("jq\n    skip: already installed 1.7.1"))
+			Expect(f.Stdout.String()).To(ContainSubstring("spy install neovim"))
+		})
+	})
+
+	Describe("dependencies", func() {
+		BeforeEach(func() {
+			f.Deps = map[string][]string{"yarn": {"node"}, "n": {"node"}}
+			f.assemble()
+		})
+
+		It("installs a dependency before its dependents", func() {
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.InstallLog).To(HaveLen(len(f.InstallableNames)))
+			Expect(indexOf(f.InstallLog, "node")).To(BeNumerically("<", indexOf(f.InstallLog, "yarn")))
+		})
+
+		It("pulls in unselected dependencies, saying so", func() {
+			f.Picker.pick = []string{"yarn"}
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.InstallLog).To(Equal([]string{"node", "yarn"}))
+			Expect(f.Stdout.String()).To(ContainSubstring("Adding node, which yarn depends on"))
+			Expect(f.Config.cfg.Packages).To(Equal([]config.Package{{Name: "yarn"}}))
+		})
+
+		It("does not reinstall a dependency that is already installed", func() {
+			f.Installed = map[string]string{"node": "22.1.0"}
+			f.assemble()
+			f.Picker.pick = []string{"yarn"}
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.InstallLog).To(Equal([]string{"yarn"}))
+		})
+
+		It("orders the dry-run plan and names each tool's dependencies", func() {
+			f.Picker.pick = []string{"yarn"}
+			f.Setup.DryRun = true
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.Stdout.String()).To(ContainSubstring("  node\n    spy install node\n  yarn\n    after node\n    spy install yarn\n"))
+		})
+
+		It("skips powerlevel10k when oh-my-zsh fails", func() {
+			f.OhMyZsh.err = fmt.Errorf("git missing")
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.P10k.calls).To(Equal(0))
+			Expect(f.Stderr.String()).To(ContainSubstring("powerlevel10k: skipped because dependency oh-my-zsh failed"))
+		})
+	})
+
+	Describe("apt sources", func() {
+		It("syncs them once, reporting a failure without aborting", func() {
+			sources := &spyInstaller{err: fmt.Errorf("source gh: fetching key: timeout")}
+			f.Setup.Sources = sources
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(sources.calls).To(Equal(1))
+			Expect(f.Stderr.String()).To(ContainSubstring("apt sources: source gh: fetching key: timeout"))
+			Expect(f.InstallLog).To(HaveLen(len(f.InstallableNames)))
+		})
+
+		It("plans them on a dry run without syncing", func() {
+			sources := &spyInstaller{}
+			f.Setup.Sources, f.Setup.DryRun = sources, true
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(sources.calls).To(BeZero())
+			Expect(f.Stdout.String()).To(ContainSubstring("\nApt sources:\n  skip: already installed\n"))
+		})
+	})
+
+	Describe("batching", func() {
+		BeforeEach(func() {
+			f.BatchKeys = map[string]string{"jq": "brew", "gh": "brew", "go": "brew"}
+			f.assemble()
+			f.Picker.pick = []string{"jq", "python", "gh"}
+			f.Setup.Batch = true
+		})
+
+		It("hands the installer one batch for the tools sharing a key", func() {
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.InstallLog).To(Equal([]string{"jq, gh", "python"}))
+		})
+
+		It("plans the batched command in place of its members", func() {
+			f.Setup.DryRun = true
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.Stdout.String()).To(ContainSubstring("  jq, gh\n    spy batch jq gh\n  python\n    spy install python\n"))
+		})
+
+		It("leaves an already installed tool out of the batch", func() {
+			f.Installed = map[string]string{"gh": "2.40.0"}
+			f.assemble()
+			f.Picker.pick = []string{"jq", "gh", "go"}
+			f.Setup.Batch = true
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.InstallLog).To(Equal([]string{"jq, go"}))
+		})
+	})
+
 	Describe("package installation", func() {
 		It("installs every selected installable", func() {
 			Expect(f.Setup.Run()).To(Succeed())
@@ -271,6 +445,26 @@ var _ = Describe("Setup.Run", func() {
 			Expect(names).To(Equal(f.InstallableNames))
 		})
 
+		It("keeps the version pins of packages that stay selected", func() {
+			f.Config.cfg = &config.Config{Packages: []config.Package{{Name: "go", Version: "1.22"}, {Name: "gone", Version: "1"}}}
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.Config.cfg.Packages).To(ContainElement(config.Package{Name: "go", Version: "1.22"}))
+			Expect(f.Config.cfg.Packages).To(ContainElement(config.Package{Name: "jq"}))
+		})
+
+		It("records what a latest or range pin resolved to", func() {
+			f.Config.cfg = &config.Config{Packages: []config.Package{
+				{Name: "neovim", Version: ">=0.10 <0.12", Resolved: "0.10.4"},
+				{Name: "go", Version: "1.22", Resolved: "stale"},
+			}}
+			f.Setup.Resolved = map[string]string{"neovim": "0.11.6"}
+
+			Expect(f.Setup.Run()).To(Succeed())
+
+			Expect(f.Config.cfg.Packages).To(ContainElement(config.Package{Name: "neovim", Version: ">=0.10 <0.12", Resolved: "0.11.6"}))
+			Expect(f.Config.cfg.Packages).To(ContainElement(config.Package{Name: "go", Version: "1.22"}))
+		})
+
 		It("prints the config path", func() {
 			Expect(f.Setup.Run()).To(Succeed())
 			Expect(f.Stdout.String()).To(ContainSubstring("Config written to"))
@@ -343,6 +537,35 @@ var _ = Describe("Setup.Run", func() {
 		})
 	})
 
+	Describe("dry run", func() {
+		BeforeEach(func() {
+			f.Picker.pick = []string{"jq", "gh"}
+			f.Setup.DryRun = true
+		})
+
+		It("prints each selected installable's plan and the shell installers' plans", func() {
+			Expect(f.Setup.Run()).To(Succeed())
+			out := f.Stdout.String()
+			Expect(out).To(ContainSubstring("spy install jq"))
+			Expect(out).To(ContainSubstring("spy install gh"))
+			Expect(out).NotTo(ContainSubstring("spy install neovim"))
+			Expect(out).To(ContainSubstring("oh-my-zsh\n    skip: already installed"))
+		})
+
+		It("installs nothing and leaves the config unsaved", func() {
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.InstallLog).To(BeEmpty())
+			Expect(f.OhMyZsh.calls).To(Equal(0))
+			Expect(f.P10k.calls).To(Equal(0))
+			Expect(f.Config.cfg).To(BeNil())
+		})
+
+		It("still walks the components, which describe their own copies", func() {
+			Expect(f.Setup.Run()).To(Succeed())
+			Expect(f.PullLog).To(Equal(f.ComponentNames))
+		})
+	})
+
 	Describe("post-setup next steps", func() {
 		It("prints the rustup, ghcup, and powerlevel10k hints", func() {
 			Expect(f.Setup.Run()).To(Succeed())
@@ -353,3 +576,106 @@ var _ = Describe("Setup.Run", func() {
 		})
 	})
 })
+
+var _ = Describe("IterativeInstaller", func() {
+	It("skips everything behind a failed install, naming the root failure", func() {
+		var log []string
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		ordered := []pkg.Installable{
+			&spyInstallable{name: "node", log: &log, err: fmt.Errorf("brew exploded")},
+			&spyInstallable{name: "yarn", log: &log},
+			&spyInstallable{name: "yarn-plugin", log: &log},
+			&spyInstallable{name: "jq", log: &log},
+		}
+		deps := map[string][]string{"yarn": {"node"}, "yarn-plugin": {"yarn"}}
+
+		cmd.IterativeInstaller{Stdout: stdout, Stderr: stderr}.InstallAll(ordered, deps)
+
+		Expect(log).To(Equal([]string{"node", "jq"}))
+		Expect(stderr.String()).To(Equal("  node: brew exploded\n" +
+			"  yarn: skipped because dependency node failed\n" +
+			"  yarn-plugin: skipped because dependency node failed\n" +
+			"\n3 not installed: node, yarn, yarn-plugin\n"))
+	})
+})
+
+var _ = Describe("IterativeInstaller with a batch", func() {
+	It("attributes a failed batch to the member at fault and skips its dependents", func() {
+		var log []string
+		stderr := &bytes.Buffer{}
+		deps := map[string][]string{"yarn": {"node"}, "plugin": {"yarn"}}
+		ordered := pkg.Batch([]pkg.Installable{
+			&spyInstallable{name: "node", log: &log, batch: "brew", err: fmt.Errorf("no bottle")},
+			&spyInstallable{name: "jq", log: &log, batch: "brew"},
+			&spyInstallable{name: "yarn", log: &log, batch: "brew"},
+			&spyInstallable{name: "plugin", log: &log},
+		}, deps)
+
+		cmd.IterativeInstaller{Stdout: io.Discard, Stderr: stderr}.InstallAll(ordered, deps)
+
+		Expect(log).To(Equal([]string{"batch node jq yarn", "node", "jq"}))
+		Expect(stderr.String()).To(HaveSuffix("  node: no bottle\n" +
+			"  yarn: skipped because dependency node failed\n" +
+			"  plugin: skipped because dependency node failed\n" +
+			"\n3 not installed: node, plugin, yarn\n"))
+	})
+})
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+var _ = Describe("ScriptApprover", func() {
+	It("shows what changed before asking whether to run it", func() {
+		var out bytes.Buffer
+		var asked string
+		approver := &cmd.ScriptApprover{
+			Out: &out,
+			Confirm: func(prompt string) (bool, error) {
+				asked = prompt
+				Expect(out.String()).To(ContainSubstring("+ echo v2"))
+				return false, nil
+			},
+		}
+
+		ok, err := approver.Approve(script.Review{Name: "ohmyzsh", URL: "https://example.com/install.sh", SHA256: "abc", Diff: "- echo v1\n+ echo v2\n"})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+		Expect(out.String()).To(ContainSubstring("The ohmyzsh install script changed since it was approved. https://example.com/install.sh (SHA-256 abc):\n- echo v1\n"))
+		Expect(asked).To(Equal("Run the changed ohmyzsh install script?"))
+	})
+
+	It("shows a script that is not pinned in full the first time", func() {
+		var out bytes.Buffer
+		approver := &cmd.ScriptApprover{
+			Out:     &out,
+			Confirm: func(prompt string) (bool, error) { return prompt == "Run the ohmyzsh install script?", nil },
+		}
+
+		ok, err := approver.Approve(script.Review{Name: "ohmyzsh", URL: "https://example.com/install.sh", SHA256: "abc", Diff: "+ echo v1\n", First: true})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(out.String()).To(ContainSubstring("The ohmyzsh install script is not pinned. https://example.com/install.sh (SHA-256 abc):\n+ echo v1\n"))
+	})
+
+	It("runs a new script unattended with a warning, but never a changed one", func() {
+		var out bytes.Buffer
+		approver := &cmd.ScriptApprover{Out: &out, Unattended: true}
+
+		first, err := approver.Approve(script.Review{Name: "ohmyzsh", URL: "https://example.com/install.sh", SHA256: "abc", First: true})
+		Expect(err).NotTo(HaveOccurred())
+		changed, err := approver.Approve(script.Review{Name: "ohmyzsh", URL: "https://example.com/install.sh", SHA256: "def"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(first).To(BeTrue())
+		Expect(changed).To(BeFalse())
+		Expect(out.String()).To(Equal("WARNING: running the ohmyzsh install script, which is not pinned, unreviewed (--yes). https://example.com/install.sh (SHA-256 abc)\n"))
+	})
+})
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/cloudwalk/machine-setup/internal/drift"
+	"github.com/spf13/cobra"
+)
+
+// FileStatus is one row of the status report.
+type FileStatus struct {
+	Component string      `json:"component"`
+	State     drift.State `json:"state"`
+	Local     string      `json:"local"`
+	Repo      string      `json:"repo"`
+}
+
+// Status orchestrates `machine-setup status`: classify every managed file and
+// report drift between the repo and this machine.
+type Status struct {
+	Entries  []drift.Entry
+	RepoRoot string
+	Home     string
+	JSON     bool
+
+	Stdout io.Writer
+}
+
+// Run prints the report and returns an error when any file has drifted, so
+// the command's exit code can drive prompt hooks and cron checks.
+func (s *Status) Run() error {
+	var rows []FileStatus
+	drifted := 0
+	for _, e := range s.Entries {
+		state, err := drift.Classify(e)
+		if err != nil {
+			return err
+		}
+		if state == drift.Absent {
+			continue
+		}
+		if state.Drifted() {
+			drifted++
+		}
+		rows = append(rows, FileStatus{Component: e.Component, State: state, Local: e.Local, Repo: e.Repo})
+	}
+
+	if s.JSON {
+		if err := s.writeJSON(rows, drifted); err != nil {
+			return err
+		}
+	} else {
+		s.writeText(rows, drifted)
+	}
+	if drifted > 0 {
+		return fmt.Errorf("%d managed file(s) drifted from the repo", drifted)
+	}
+	return nil
+}
+
+func (s *Status) writeJSON(rows []FileStatus, drifted int) error {
+	if rows == nil {
+		rows = []FileStatus{}
+	}
+	enc := json.NewEncoder(s.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Drifted int          `json:"drifted"`
+		Files   []FileStatus `json:"files"`
+	}{Drifted: drifted, Files: rows})
+}
+
+func (s *Status) writeText(rows []FileStatus, drifted int) {
+	for _, r := range rows {
+		fmt.Fprintf(s.Stdout, "  %-6s %-13s %s\n", r.Component, r.State, displayPath(s.Home, "~", r.Local))
+	}
+	if drifted == 0 {
+		fmt.Fprintf(s.Stdout, "\nAll %d managed file(s) in sync.\n", len(rows))
+		return
+	}
+	fmt.Fprintf(s.Stdout, "\n%d of %d managed file(s) drifted. Run `machine-setup diff` for details.\n", drifted, len(rows))
+}
+
+var (
+	statusComponents []string
+	statusOutput     string
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report drift between the repo's configs and this machine",
+	Long: `Classify every managed file as in-sync, modified, missing-local,
+missing-repo, or mode-differs (same content, different permissions).
+Exits non-zero when anything has drifted, for use in prompt hooks or cron.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if statusOutput != "text" && statusOutput != "json" {
+			return fmt.Errorf("unknown --output %q (want text or json)", statusOutput)
+		}
+		opts, err := newComponentOptions(cmd.OutOrStdout(), cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+		entries, err := managedEntries(opts, statusComponents)
+		if err != nil {
+			return err
+		}
+		s := &Status{
+			Entries:  entries,
+			RepoRoot: opts.RepoRoot,
+			Home:     opts.Home,
+			JSON:     statusOutput == "json",
+			Stdout:   cmd.OutOrStdout(),
+		}
+		return s.Run()
+	},
+}
+
+func init() {
+	statusCmd.Flags().StringSliceVarP(&statusComponents, "component", "c", nil, "only report these components")
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", "text", "output format: text or json")
+}
@@ -0,0 +1,84 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/cmd"
+	"github.com/cloudwalk/machine-setup/internal/drift"
+)
+
+var _ = Describe("Status.Run", func() {
+	var (
+		repoRoot string
+		home     string
+		stdout   *bytes.Buffer
+		entries  []drift.Entry
+	)
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		tmp := GinkgoT().TempDir()
+		repoRoot = filepath.Join(tmp, "repo")
+		home = filepath.Join(tmp, "home")
+		stdout = &bytes.Buffer{}
+
+		write(filepath.Join(repoRoot, "vim", "vimrc"), "set nu\n")
+		write(filepath.Join(home, ".vimrc"), "set nu\n")
+		entries = []drift.Entry{
+			{Component: "vim", Repo: filepath.Join(repoRoot, "vim", "vimrc"), Local: filepath.Join(home, ".vimrc")},
+			{Component: "zsh", Repo: filepath.Join(repoRoot, "zsh", "zshrc_funcs"), Local: filepath.Join(home, ".zshrc_funcs")},
+		}
+	})
+
+	newStatus := func(asJSON bool) *cmd.Status {
+		return &cmd.Status{Entries: entries, RepoRoot: repoRoot, Home: home, JSON: asJSON, Stdout: stdout}
+	}
+
+	It("succeeds and lists in-sync files when nothing drifted, hiding files absent on both sides", func() {
+		Expect(newStatus(false).Run()).To(Succeed())
+
+		Expect(stdout.String()).To(ContainSubstring("in-sync"))
+		Expect(stdout.String()).To(ContainSubstring("~/.vimrc"))
+		Expect(stdout.String()).NotTo(ContainSubstring("zshrc_funcs"))
+	})
+
+	It("fails when a file drifted and names its state", func() {
+		write(filepath.Join(home, ".vimrc"), "set nonu\n")
+		write(filepath.Join(repoRoot, "zsh", "zshrc_funcs"), "f() {}\n")
+
+		err := newStatus(false).Run()
+
+		Expect(err).To(MatchError(ContainSubstring("2 managed file(s) drifted")))
+		Expect(stdout.String()).To(ContainSubstring("modified"))
+		Expect(stdout.String()).To(ContainSubstring("missing-local"))
+	})
+
+	It("emits machine-readable JSON with --output json", func() {
+		write(filepath.Join(home, ".vimrc"), "set nonu\n")
+
+		Expect(newStatus(true).Run()).NotTo(Succeed())
+
+		var report struct {
+			Drifted int              `json:"drifted"`
+			Files   []cmd.FileStatus `json:"files"`
+		}
+		Expect(json.Unmarshal(stdout.Bytes(), &report)).To(Succeed())
+		Expect(report.Drifted).To(Equal(1))
+		Expect(report.Files).To(ConsistOf(cmd.FileStatus{
+			Component: "vim",
+			State:     drift.Modified,
+			Local:     filepath.Join(home, ".vimrc"),
+			Repo:      filepath.Join(repoRoot, "vim", "vimrc"),
+		}))
+	})
+})
@@ -0,0 +1,297 @@
+// Package backups manages the versioned snapshots fsutil writes under
+// <repo>/backups/<group>/v<N>: listing, inspecting, restoring and pruning.
+// A group is a component name ("zsh"), a push-side group ("zsh-repo"), or a
+// manual group from `make backup` ("zsh-manual").
+package backups
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwalk/machine-setup/internal/fsutil"
+	"github.com/cloudwalk/machine-setup/internal/paths"
+)
+
+// DefaultRetention mirrors BACKUP_RETENTION in scripts/lib/config.sh: the
+// number of versions prune keeps per group.
+const DefaultRetention = 5
+
+// Snapshot is one v<N> backup directory.
+type Snapshot struct {
+	Group   string
+	Version int
+	Dir     string
+	Time    time.Time // run time from Meta, else modification time of Dir
+	// Meta is nil for snapshots written before run metadata existed; those
+	// are restored by resolving entry names against the path tables.
+	Meta *fsutil.Meta
+}
+
+// Name returns the snapshot's directory name, e.g. "v3".
+func (s Snapshot) Name() string { return fmt.Sprintf("v%d", s.Version) }
+
+// Entries returns the top-level names inside the snapshot — the basenames
+// of the paths that were backed up — excluding the metadata file.
+func (s Snapshot) Entries() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		if e.Name() != fsutil.MetaFile {
+			out = append(out, e.Name())
+		}
+	}
+	return out, nil
+}
+
+// Files returns every regular file in the snapshot as a Dir-relative path.
+func (s Snapshot) Files() ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(s.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path == filepath.Join(s.Dir, fsutil.MetaFile) {
+			return err
+		}
+		rel, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+		out = append(out, rel)
+		return nil
+	})
+	return out, err
+}
+
+// List returns every snapshot under root, ordered by group then version.
+// A missing root has no snapshots.
+func List(root string) ([]Snapshot, error) {
+	groups, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []Snapshot
+	for _, g := range groups {
+		// Skips fsutil.ObjectsDir, the content store snapshots link into.
+		if !g.IsDir() || strings.HasPrefix(g.Name(), ".") {
+			continue
+		}
+		snaps, err := ListGroup(root, g.Name())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, snaps...)
+	}
+	return out, nil
+}
+
+// ListGroup returns the snapshots of one group in ascending version order.
+func ListGroup(root, group string) ([]Snapshot, error) {
+	groupDir := filepath.Join(root, group)
+	versions, err := fsutil.Versions(groupDir)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Snapshot, 0, len(versions))
+	for _, v := range versions {
+		dir := filepath.Join(groupDir, fmt.Sprintf("v%d", v))
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		s := Snapshot{Group: group, Version: v, Dir: dir, Time: info.ModTime()}
+		meta, err := fsutil.ReadMeta(dir)
+		switch {
+		case err == nil:
+			s.Meta = &meta
+			s.Time = meta.Time
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Find returns the snapshot for group at version; Latest selects the newest.
+func Find(root, group string, version int) (Snapshot, error) {
+	snaps, err := ListGroup(root, group)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if version == Latest {
+		if len(snaps) == 0 {
+			return Snapshot{}, fmt.Errorf("no backups for %s", group)
+		}
+		return snaps[len(snaps)-1], nil
+	}
+	for _, s := range snaps {
+		if s.Version == version {
+			return s, nil
+		}
+	}
+	return Snapshot{}, fmt.Errorf("backup %s v%d not found", group, version)
+}
+
+// Latest is the version Find and ParseVersion use for "the newest snapshot".
+const Latest = 0
+
+// ParseVersion accepts "3", "v3", or "latest" (returned as Latest).
+func ParseVersion(s string) (int, error) {
+	if s == "latest" {
+		return Latest, nil
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(s, "v"))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid backup version %q (want e.g. v3 or latest)", s)
+	}
+	return n, nil
+}
+
+// Prune deletes all but the newest keep snapshots of every group and returns
+// what it removed (or would remove, when dryRun is set). Stored contents no
+// remaining snapshot refers to are deleted with them.
+func Prune(root string, keep int, dryRun bool) ([]Snapshot, error) {
+	if keep < 0 {
+		return nil, fmt.Errorf("retention must be >= 0, got %d", keep)
+	}
+	all, err := List(root)
+	if err != nil {
+		return nil, err
+	}
+	byGroup := map[string][]Snapshot{}
+	var groups []string
+	for _, s := range all {
+		if _, ok := byGroup[s.Group]; !ok {
+			groups = append(groups, s.Group)
+		}
+		byGroup[s.Group] = append(byGroup[s.Group], s)
+	}
+	sort.Strings(groups)
+
+	var removed []Snapshot
+	for _, g := range groups {
+		snaps := byGroup[g]
+		if len(snaps) <= keep {
+			continue
+		}
+		for _, s := range snaps[:len(snaps)-keep] {
+			if !dryRun {
+				if err := os.RemoveAll(s.Dir); err != nil {
+					return removed, err
+				}
+			}
+			removed = append(removed, s)
+		}
+	}
+	if dryRun || len(removed) == 0 {
+		return removed, nil
+	}
+	_, err = fsutil.PruneObjects(root)
+	return removed, err
+}
+
+// Destination resolves where a top-level snapshot entry was backed up from,
+// using the group's component path table: local paths for component and
+// manual groups, repo paths for "-repo" groups.
+func Destination(p paths.Paths, group, name string) (string, error) {
+	component, repoSide := splitGroup(group)
+	pairs := p.PairsFor(component)
+	if pairs == nil {
+		return "", fmt.Errorf("unknown component for backup group %q", group)
+	}
+	side := func(pr paths.Pair) string {
+		if repoSide {
+			return pr.Repo
+		}
+		return pr.Local
+	}
+
+	for _, pr := range pairs {
+		if filepath.Base(side(pr)) == name {
+			return side(pr), nil
+		}
+	}
+	// Whole parent directories, as `make backup` takes of ~/.byobu.
+	for _, pr := range pairs {
+		if dir := filepath.Dir(side(pr)); filepath.Base(dir) == name {
+			return dir, nil
+		}
+	}
+	// Files copied one by one into an overlay tree (byobu/bin/*).
+	for _, pr := range pairs {
+		if pr.Tree && !pr.Mirror {
+			return filepath.Join(side(pr), name), nil
+		}
+	}
+	return "", fmt.Errorf("cannot tell where %s in %s came from", name, group)
+}
+
+func splitGroup(group string) (component string, repoSide bool) {
+	if c, ok := strings.CutSuffix(group, "-repo"); ok {
+		return c, true
+	}
+	return strings.TrimSuffix(group, "-manual"), false
+}
+
+// Originals maps each entry of s to the absolute path it was backed up from:
+// from the snapshot metadata when present, else via Destination.
+func (s Snapshot) Originals(p paths.Paths) ([]fsutil.BackedUp, error) {
+	if s.Meta != nil {
+		return s.Meta.Files, nil
+	}
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]fsutil.BackedUp, len(entries))
+	for i, name := range entries {
+		dst, err := Destination(p, s.Group, name)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = fsutil.BackedUp{Entry: name, Original: dst}
+	}
+	return out, nil
+}
+
+// Restore copies every entry of s back to where it came from. Whatever
+// currently sits there is itself backed up first — into one new snapshot
+// described by run — so a restore can be undone by restoring that snapshot.
+// Directories are replaced, not merged.
+func Restore(s Snapshot, p paths.Paths, backupRoot string, run fsutil.RunInfo) ([]string, error) {
+	originals, err := s.Originals(p)
+	if err != nil {
+		return nil, err
+	}
+	snap := fsutil.NewSnapshot(backupRoot, s.Group, run)
+	var restored []string
+	for _, o := range originals {
+		src := filepath.Join(s.Dir, o.Entry)
+		if err := restoreOne(snap, src, o.Original); err != nil {
+			return restored, fmt.Errorf("restoring %s: %w", o.Original, err)
+		}
+		restored = append(restored, o.Original)
+	}
+	return restored, nil
+}
+
+func restoreOne(snap *fsutil.Snapshot, src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return snap.Mirror(src, dst)
+	}
+	return snap.SafeCopy(src, dst)
+}
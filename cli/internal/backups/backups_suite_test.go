@@ -0,0 +1,13 @@
+package backups_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBackupsSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "backups Suite")
+}
@@ -0,0 +1,178 @@
+package backups_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/backups"
+	"github.com/cloudwalk/machine-setup/internal/fsutil"
+	"github.com/cloudwalk/machine-setup/internal/paths"
+)
+
+var _ = Describe("backups", func() {
+	var (
+		tmp        string
+		backupRoot string
+		p          paths.Paths
+	)
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+	read := func(path string) string {
+		b, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	BeforeEach(func() {
+		tmp = GinkgoT().TempDir()
+		backupRoot = filepath.Join(tmp, "backups")
+		p = paths.ForOS(filepath.Join(tmp, "repo"), filepath.Join(tmp, "home"), "linux")
+	})
+
+	Describe("List and Prune", func() {
+		BeforeEach(func() {
+			for _, v := range []string{"v1", "v2", "v3", "v10"} {
+				write(filepath.Join(backupRoot, "zsh", v, ".zshrc"), v)
+			}
+			write(filepath.Join(backupRoot, "vim", "v1", ".vimrc"), "x")
+			write(filepath.Join(backupRoot, ".gitkeep"), "")
+		})
+
+		It("lists snapshots by group, in numeric version order", func() {
+			snaps, err := backups.List(backupRoot)
+			Expect(err).NotTo(HaveOccurred())
+
+			var got []string
+			for _, s := range snaps {
+				got = append(got, s.Group+"/"+s.Name())
+			}
+			Expect(got).To(Equal([]string{"vim/v1", "zsh/v1", "zsh/v2", "zsh/v3", "zsh/v10"}))
+		})
+
+		It("prunes all but the newest versions of each group", func() {
+			removed, err := backups.Prune(backupRoot, 2, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(HaveLen(2))
+
+			snaps, err := backups.ListGroup(backupRoot, "zsh")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(snaps).To(HaveLen(2))
+			Expect(snaps[0].Version).To(Equal(3))
+			Expect(snaps[1].Version).To(Equal(10))
+		})
+
+		It("only reports what it would remove in dry-run mode", func() {
+			removed, err := backups.Prune(backupRoot, 1, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(HaveLen(3))
+			Expect(filepath.Join(backupRoot, "zsh", "v1")).To(BeADirectory())
+		})
+
+		It("ignores the object store and drops objects only pruned snapshots used", func() {
+			src := filepath.Join(tmp, ".vimrc")
+			write(src, "stored")
+			_, err := fsutil.NewSnapshot(backupRoot, "vim", fsutil.RunInfo{}).Backup(src)
+			Expect(err).NotTo(HaveOccurred())
+			objects := filepath.Join(backupRoot, fsutil.ObjectsDir)
+			Expect(objects).To(BeADirectory())
+
+			snaps, err := backups.List(backupRoot)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(snaps).To(HaveLen(6))
+
+			_, err = backups.Prune(backupRoot, 0, false)
+			Expect(err).NotTo(HaveOccurred())
+			var left []string
+			Expect(filepath.WalkDir(objects, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					left = append(left, path)
+				}
+				return err
+			})).To(Succeed())
+			Expect(left).To(BeEmpty())
+		})
+	})
+
+	Describe("Destination", func() {
+		It("maps a local file backup to its home path", func() {
+			Expect(backups.Destination(p, "zsh", ".zshrc_aliases")).To(Equal(p.Zsh.AliasesLocal))
+		})
+
+		It("maps a push-side backup to its repo path", func() {
+			Expect(backups.Destination(p, "zsh-repo", "zshrc")).To(Equal(p.Zsh.ZshrcRepo))
+		})
+
+		It("maps byobu bin scripts into the bin dir and whole dirs to themselves", func() {
+			Expect(backups.Destination(p, "byobu", "1_git")).To(Equal(filepath.Join(p.Byobu.BinLocal, "1_git")))
+			Expect(backups.Destination(p, "nvim", "nvim")).To(Equal(p.Nvim.Local))
+			Expect(backups.Destination(p, "byobu-manual", ".byobu")).To(Equal(filepath.Dir(p.Byobu.TmuxConfLocal)))
+		})
+
+		It("rejects an entry it cannot place", func() {
+			_, err := backups.Destination(p, "vim", "mystery")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Restore", func() {
+		It("puts files back where they came from, backing up what it overwrites", func() {
+			write(filepath.Join(backupRoot, "zsh", "v1", ".zshrc"), "OLD")
+			write(p.Zsh.ZshrcLocal, "CURRENT")
+			snap, err := backups.Find(backupRoot, "zsh", 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			restored, err := backups.Restore(snap, p, backupRoot, fsutil.RunInfo{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(restored).To(Equal([]string{p.Zsh.ZshrcLocal}))
+			Expect(read(p.Zsh.ZshrcLocal)).To(Equal("OLD"))
+			Expect(read(filepath.Join(backupRoot, "zsh", "v2", ".zshrc"))).To(Equal("CURRENT"))
+		})
+
+		It("replaces a directory rather than merging into it", func() {
+			write(filepath.Join(backupRoot, "nvim", "v1", "nvim", "init.lua"), "OLD_INIT")
+			write(filepath.Join(p.Nvim.Local, "new.lua"), "NEW")
+			snap, err := backups.Find(backupRoot, "nvim", 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = backups.Restore(snap, p, backupRoot, fsutil.RunInfo{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(read(filepath.Join(p.Nvim.Local, "init.lua"))).To(Equal("OLD_INIT"))
+			Expect(filepath.Join(p.Nvim.Local, "new.lua")).NotTo(BeAnExistingFile())
+		})
+
+		It("restores to the original paths recorded in the snapshot metadata", func() {
+			elsewhere := filepath.Join(GinkgoT().TempDir(), "custom.zsh")
+			write(elsewhere, "OLD")
+			taken := fsutil.NewSnapshot(backupRoot, "zsh", fsutil.RunInfo{Command: "machine-setup pull"})
+			_, err := taken.Backup(elsewhere)
+			Expect(err).NotTo(HaveOccurred())
+			write(elsewhere, "CURRENT")
+
+			snap, err := backups.Find(backupRoot, "zsh", backups.Latest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(snap.Meta).NotTo(BeNil())
+			Expect(snap.Entries()).To(Equal([]string{"custom.zsh"}))
+
+			restored, err := backups.Restore(snap, p, backupRoot, fsutil.RunInfo{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored).To(Equal([]string{elsewhere}))
+			Expect(read(elsewhere)).To(Equal("OLD"))
+		})
+	})
+
+	It("parses versions with or without the v prefix", func() {
+		Expect(backups.ParseVersion("v4")).To(Equal(4))
+		Expect(backups.ParseVersion("4")).To(Equal(4))
+		Expect(backups.ParseVersion("latest")).To(Equal(backups.Latest))
+		_, err := backups.ParseVersion("v0")
+		Expect(err).To(HaveOccurred())
+	})
+})
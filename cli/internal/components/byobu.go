@@ -1,6 +1,7 @@
 package components
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -24,6 +25,11 @@ func (b *Byobu) Name() string { return "byobu" }
 
 // Pull copies all byobu config files (excluding bin/) into ~/.byobu/.
 func (b *Byobu) Pull() error {
+	snap := b.opts.snapshot(b.Name())
+	return b.opts.finish(snap, b.pull(snap))
+}
+
+func (b *Byobu) pull(snap *fsutil.Snapshot) error {
 	copies := []struct{ src, dst string }{
 		{b.p.TmuxConfRepo, b.p.TmuxConfLocal},
 		{b.p.KeybindingsRepo, b.p.KeybindingsLocal},
@@ -31,27 +37,69 @@ func (b *Byobu) Pull() error {
 		{b.p.StatusrcRepo, b.p.StatusrcLocal},
 	}
 	for _, c := range copies {
-		if err := fsutil.SafeCopy(c.src, c.dst, b.Name(), b.opts.BackupRoot); err != nil {
+		if err := snap.SafeCopy(c.src, c.dst); err != nil {
 			return err
 		}
 	}
-	return b.pullBin()
+	return b.pullBin(snap)
 }
 
 // pullBin copies each file inside <repo>/byobu/bin into ~/.byobu/bin, flat.
 // Mirrors `cp -r byobu/bin/* ~/.byobu/bin/` in scripts/components/byobu.sh:29.
-func (b *Byobu) pullBin() error {
+func (b *Byobu) pullBin(snap *fsutil.Snapshot) error {
 	entries, err := os.ReadDir(b.p.BinRepo)
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(b.p.BinLocal, 0o755); err != nil {
-		return err
-	}
 	for _, e := range entries {
 		src := filepath.Join(b.p.BinRepo, e.Name())
 		dst := filepath.Join(b.p.BinLocal, e.Name())
-		if err := fsutil.SafeCopy(src, dst, b.Name(), b.opts.BackupRoot); err != nil {
+		if err := snap.SafeCopy(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Push copies ~/.byobu/bin/* and each byobu config file present locally back
+// into the repo. ~/.byobu itself must exist.
+func (b *Byobu) Push() error {
+	if _, err := os.Stat(filepath.Dir(b.p.TmuxConfLocal)); err != nil {
+		return fmt.Errorf("byobu config not found: %w", err)
+	}
+	snap := b.opts.snapshot(repoBackup(b.Name()))
+	return b.opts.finish(snap, b.push(snap))
+}
+
+func (b *Byobu) push(snap *fsutil.Snapshot) error {
+	copies := []struct{ src, dst string }{
+		{b.p.TmuxConfLocal, b.p.TmuxConfRepo},
+		{b.p.KeybindingsLocal, b.p.KeybindingsRepo},
+		{b.p.DatetimeLocal, b.p.DatetimeRepo},
+		{b.p.StatusrcLocal, b.p.StatusrcRepo},
+	}
+	for _, c := range copies {
+		if err := copyIfPresent(snap, c.src, c.dst); err != nil {
+			return err
+		}
+	}
+	return b.pushBin(snap)
+}
+
+// pushBin copies each file inside ~/.byobu/bin into <repo>/byobu/bin, flat.
+// A missing local bin dir is not an error.
+func (b *Byobu) pushBin(snap *fsutil.Snapshot) error {
+	entries, err := os.ReadDir(b.p.BinLocal)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		src := filepath.Join(b.p.BinLocal, e.Name())
+		dst := filepath.Join(b.p.BinRepo, e.Name())
+		if err := snap.SafeCopy(src, dst); err != nil {
 			return err
 		}
 	}
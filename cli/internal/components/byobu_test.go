@@ -65,3 +65,46 @@ var _ = Describe("Byobu.Pull", func() {
 		Expect(os.IsNotExist(err)).To(BeTrue())
 	})
 })
+
+var _ = Describe("Byobu.Push", func() {
+	var (
+		tmp      string
+		repoRoot string
+		home     string
+		opts     components.Options
+	)
+
+	BeforeEach(func() {
+		tmp = GinkgoT().TempDir()
+		repoRoot = filepath.Join(tmp, "repo")
+		home = filepath.Join(tmp, "home")
+		Expect(os.MkdirAll(filepath.Join(home, ".byobu", "bin"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(home, ".byobu", ".tmux.conf"), []byte("TMUX"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(home, ".byobu", "bin", "5_load"), []byte("LOAD"), 0o755)).To(Succeed())
+
+		opts = components.Options{
+			RepoRoot:   repoRoot,
+			Home:       home,
+			BackupRoot: filepath.Join(tmp, "backups"),
+			Stdout:     &bytes.Buffer{},
+			Stderr:     &bytes.Buffer{},
+		}
+	})
+
+	It("copies present config files and bin scripts into the repo", func() {
+		Expect(components.NewByobu(opts).Push()).To(Succeed())
+
+		b, err := os.ReadFile(filepath.Join(repoRoot, "byobu", ".tmux.conf"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("TMUX"))
+
+		b, err = os.ReadFile(filepath.Join(repoRoot, "byobu", "bin", "5_load"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("LOAD"))
+	})
+
+	It("fails when ~/.byobu does not exist", func() {
+		Expect(os.RemoveAll(filepath.Join(home, ".byobu"))).To(Succeed())
+		Expect(components.NewByobu(opts).Push()).NotTo(Succeed())
+	})
+})
@@ -2,7 +2,14 @@
 // can pull dotfile configs without shelling out to bash.
 package components
 
-import "io"
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cloudwalk/machine-setup/internal/fsutil"
+)
 
 // Component is the unit the orchestrator iterates over during setup.
 type Component interface {
@@ -10,13 +17,44 @@ type Component interface {
 	Pull() error
 }
 
+// Pushable is a Component that can also copy local edits back into the repo,
+// using the same path table as Pull in reverse. Fonts are read-only and do
+// not implement it.
+type Pushable interface {
+	Component
+	Push() error
+}
+
 // Options is the per-run configuration every component needs.
 type Options struct {
-	RepoRoot   string    // root of the machine-setup repo
-	Home       string    // user's HOME (destination root)
-	BackupRoot string    // <repoRoot>/backups in normal use
-	Stdout     io.Writer // progress output
-	Stderr     io.Writer // error/warning output
+	RepoRoot   string         // root of the machine-setup repo
+	Home       string         // user's HOME (destination root)
+	BackupRoot string         // <repoRoot>/backups in normal use
+	Run        fsutil.RunInfo // recorded in each backup snapshot's metadata
+	DryRun     bool           // describe backups and copies instead of making them
+	Stdout     io.Writer      // progress output
+	Stderr     io.Writer      // error/warning output
+}
+
+// snapshot starts the single backup snapshot a Pull or Push of group makes.
+func (o Options) snapshot(group string) *fsutil.Snapshot {
+	if o.DryRun {
+		return fsutil.NewPlan(o.BackupRoot, group)
+	}
+	return fsutil.NewSnapshot(o.BackupRoot, group, o.Run)
+}
+
+// finish reports what snap did (or, in a dry run, would do) once a Pull or
+// Push succeeded, and passes err through.
+func (o Options) finish(snap *fsutil.Snapshot, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, line := range snap.Planned() {
+		fmt.Fprintf(o.Stdout, "    %s\n", line)
+	}
+	fmt.Fprintf(o.Stdout, "    %s\n", snap.Counts())
+	return nil
 }
 
 // AllPullable returns the components in the order scripts/pull.sh iterates them.
@@ -29,3 +67,73 @@ func AllPullable(opts Options) []Component {
 		NewFonts(opts),
 	}
 }
+
+// AllPushable returns the components in the order scripts/push.sh iterates them.
+func AllPushable(opts Options) []Pushable {
+	return []Pushable{
+		NewVim(opts),
+		NewZsh(opts),
+		NewByobu(opts),
+		NewNvim(opts),
+	}
+}
+
+// Select narrows all to the components named in only (every component when
+// only is empty), then drops any named in skip. Declaration order is kept.
+// Unknown names are an error so a typo can't silently select nothing.
+func Select[C Component](all []C, only, skip []string) ([]C, error) {
+	known := make(map[string]bool, len(all))
+	for _, c := range all {
+		known[c.Name()] = true
+	}
+	for _, n := range append(append([]string{}, only...), skip...) {
+		if !known[n] {
+			return nil, fmt.Errorf("unknown component %q (known: %s)", n, strings.Join(names(all), ", "))
+		}
+	}
+
+	keep := toSet(only)
+	drop := toSet(skip)
+	var out []C
+	for _, c := range all {
+		if len(keep) > 0 && !keep[c.Name()] {
+			continue
+		}
+		if drop[c.Name()] {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func names[C Component](cs []C) []string {
+	out := make([]string, len(cs))
+	for i, c := range cs {
+		out[i] = c.Name()
+	}
+	return out
+}
+
+func toSet(s []string) map[string]bool {
+	set := make(map[string]bool, len(s))
+	for _, v := range s {
+		set[v] = true
+	}
+	return set
+}
+
+// repoBackup is the backup group for repo files overwritten by a push, kept
+// apart from the local-file backups a pull makes (e.g. "zsh-repo").
+func repoBackup(component string) string { return component + "-repo" }
+
+// copyIfPresent is SafeCopy for optional sources: a missing src is a no-op.
+func copyIfPresent(snap *fsutil.Snapshot, src, dst string) error {
+	if _, err := os.Stat(src); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return snap.SafeCopy(src, dst)
+}
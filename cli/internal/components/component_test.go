@@ -0,0 +1,55 @@
+package components_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/components"
+)
+
+var _ = Describe("Select", func() {
+	var all []components.Component
+
+	BeforeEach(func() {
+		all = components.AllPullable(components.Options{
+			RepoRoot:   GinkgoT().TempDir(),
+			Home:       GinkgoT().TempDir(),
+			BackupRoot: GinkgoT().TempDir(),
+			Stdout:     &bytes.Buffer{},
+			Stderr:     &bytes.Buffer{},
+		})
+	})
+
+	namesOf := func(cs []components.Component) []string {
+		out := make([]string, len(cs))
+		for i, c := range cs {
+			out[i] = c.Name()
+		}
+		return out
+	}
+
+	It("returns every component when neither only nor skip is given", func() {
+		got, err := components.Select(all, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namesOf(got)).To(Equal([]string{"vim", "zsh", "byobu", "nvim", "fonts"}))
+	})
+
+	It("keeps only the named components, in declaration order", func() {
+		got, err := components.Select(all, []string{"zsh", "vim"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namesOf(got)).To(Equal([]string{"vim", "zsh"}))
+	})
+
+	It("drops skipped components", func() {
+		got, err := components.Select(all, nil, []string{"fonts"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namesOf(got)).To(Equal([]string{"vim", "zsh", "byobu", "nvim"}))
+	})
+
+	It("rejects an unknown component name", func() {
+		_, err := components.Select(all, []string{"emacs"}, nil)
+		Expect(err).To(MatchError(ContainSubstring(`unknown component "emacs"`)))
+	})
+})
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"runtime"
 
+	"github.com/cloudwalk/machine-setup/internal/fsutil"
 	"github.com/cloudwalk/machine-setup/internal/paths"
 )
 
@@ -39,29 +40,46 @@ func NewFontsForOS(opts Options, goos string) *Fonts {
 // Name returns "fonts".
 func (f *Fonts) Name() string { return "fonts" }
 
-// Pull copies every file in <repo>/fonts/ to the OS-appropriate font directory.
+// Pull copies every file in <repo>/fonts/ to the OS-appropriate font directory,
+// skipping fonts that are already installed byte-for-byte.
 func (f *Fonts) Pull() error {
 	dst := f.p.Local
 	if f.LocalOverride != "" {
 		dst = f.LocalOverride
 	}
-	if err := os.MkdirAll(dst, 0o755); err != nil {
-		return err
+	if !f.opts.DryRun {
+		if err := os.MkdirAll(dst, 0o755); err != nil {
+			return err
+		}
 	}
 	entries, err := os.ReadDir(f.p.Repo)
 	if err != nil {
 		return err
 	}
+	var total fsutil.Counts
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
 		}
 		src := filepath.Join(f.p.Repo, e.Name())
 		dstFile := filepath.Join(dst, e.Name())
+		c, err := fsutil.Compare(src, dstFile)
+		if err != nil {
+			return err
+		}
+		total.Add(c)
+		if !c.Dirty() {
+			continue
+		}
+		if f.opts.DryRun {
+			fmt.Fprintf(f.opts.Stdout, "    install %s to %s\n", e.Name(), dst)
+			continue
+		}
 		if err := f.CopyFn(src, dstFile); err != nil {
 			return fmt.Errorf("install font %s: %w", e.Name(), err)
 		}
 	}
+	fmt.Fprintf(f.opts.Stdout, "    %s\n", total)
 	return nil
 }
 
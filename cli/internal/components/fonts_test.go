@@ -69,4 +69,23 @@ var _ = Describe("Fonts.Pull", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(string(b)).To(Equal("FONT_B"))
 	})
+
+	It("skips fonts that are already installed with identical content", func() {
+		localDir := filepath.Join(tmp, "installed-fonts")
+		Expect(os.MkdirAll(localDir, 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(localDir, "Hack Regular.ttf"), []byte("FONT_A"), 0o644)).To(Succeed())
+
+		var copied []string
+		f := components.NewFontsForOS(opts, "linux")
+		f.LocalOverride = localDir
+		f.CopyFn = func(src, dst string) error {
+			copied = append(copied, filepath.Base(dst))
+			return os.WriteFile(dst, []byte("x"), 0o644)
+		}
+
+		Expect(f.Pull()).To(Succeed())
+
+		Expect(copied).To(Equal([]string{"Hack Bold.ttf"}))
+		Expect(opts.Stdout.(*bytes.Buffer).String()).To(ContainSubstring("0 changed, 1 unchanged, 1 new"))
+	})
 })
@@ -25,24 +25,34 @@ func (n *Nvim) Name() string { return "nvim" }
 // Pull replaces ~/.config/nvim with the repo's nvim/ tree, then copies the
 // monokai theme into the packer plugin path.
 func (n *Nvim) Pull() error {
-	// Backup the existing local config (no-op if absent), then wipe so the
-	// new tree is a clean replace rather than a merge.
-	if _, err := fsutil.Backup(n.p.Local, n.Name(), n.opts.BackupRoot); err != nil {
-		return err
-	}
-	if err := os.RemoveAll(n.p.Local); err != nil {
-		return err
-	}
-	if err := os.MkdirAll(filepath.Dir(n.p.Local), 0o755); err != nil {
+	snap := n.opts.snapshot(n.Name())
+	return n.opts.finish(snap, n.pull(snap))
+}
+
+func (n *Nvim) pull(snap *fsutil.Snapshot) error {
+	// Mirror rather than copy so the new tree is a clean replace, not a merge.
+	if err := snap.Mirror(n.p.Repo, n.p.Local); err != nil {
 		return err
 	}
-	if err := fsutil.SafeCopy(n.p.Repo, n.p.Local, n.Name(), n.opts.BackupRoot); err != nil {
+	// Monokai theme; SafeCopy creates the packer plugin path.
+	monokaiDst := filepath.Join(n.p.MonokaiLocal, "monokai.lua")
+	return snap.SafeCopy(n.p.MonokaiRepo, monokaiDst)
+}
+
+// Push replaces the repo's nvim/ tree with ~/.config/nvim, then copies the
+// monokai theme back when it is present locally.
+func (n *Nvim) Push() error {
+	if _, err := os.Stat(n.p.Local); err != nil {
 		return err
 	}
-	// Monokai theme.
-	if err := os.MkdirAll(n.p.MonokaiLocal, 0o755); err != nil {
+	snap := n.opts.snapshot(repoBackup(n.Name()))
+	return n.opts.finish(snap, n.push(snap))
+}
+
+func (n *Nvim) push(snap *fsutil.Snapshot) error {
+	if err := snap.Mirror(n.p.Local, n.p.Repo); err != nil {
 		return err
 	}
-	monokaiDst := filepath.Join(n.p.MonokaiLocal, "monokai.lua")
-	return fsutil.SafeCopy(n.p.MonokaiRepo, monokaiDst, n.Name(), n.opts.BackupRoot)
+	monokaiSrc := filepath.Join(n.p.MonokaiLocal, "monokai.lua")
+	return copyIfPresent(snap, monokaiSrc, n.p.MonokaiRepo)
 }
@@ -70,3 +70,45 @@ var _ = Describe("Nvim.Pull", func() {
 		Expect(string(b)).To(Equal("STALE"))
 	})
 })
+
+var _ = Describe("Nvim.Push", func() {
+	var (
+		tmp      string
+		repoRoot string
+		home     string
+		opts     components.Options
+	)
+
+	BeforeEach(func() {
+		tmp = GinkgoT().TempDir()
+		repoRoot = filepath.Join(tmp, "repo")
+		home = filepath.Join(tmp, "home")
+		Expect(os.MkdirAll(filepath.Join(repoRoot, "nvim"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(repoRoot, "nvim", "removed.lua"), []byte("GONE"), 0o644)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(home, ".config", "nvim"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(home, ".config", "nvim", "init.lua"), []byte("LOCAL_INIT"), 0o644)).To(Succeed())
+
+		opts = components.Options{
+			RepoRoot:   repoRoot,
+			Home:       home,
+			BackupRoot: filepath.Join(tmp, "backups"),
+			Stdout:     &bytes.Buffer{},
+			Stderr:     &bytes.Buffer{},
+		}
+	})
+
+	It("replaces the repo nvim tree with the local one, backing up the old tree", func() {
+		Expect(components.NewNvim(opts).Push()).To(Succeed())
+
+		b, err := os.ReadFile(filepath.Join(repoRoot, "nvim", "init.lua"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("LOCAL_INIT"))
+
+		_, err = os.Stat(filepath.Join(repoRoot, "nvim", "removed.lua"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+
+		b, err = os.ReadFile(filepath.Join(opts.BackupRoot, "nvim-repo", "v1", "nvim", "removed.lua"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("GONE"))
+	})
+})
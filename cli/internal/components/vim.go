@@ -21,8 +21,27 @@ func (v *Vim) Name() string { return "vim" }
 
 // Pull copies vimrc and the sublimemonokai color scheme into HOME.
 func (v *Vim) Pull() error {
-	if err := fsutil.SafeCopy(v.p.VimrcRepo, v.p.VimrcLocal, v.Name(), v.opts.BackupRoot); err != nil {
+	snap := v.opts.snapshot(v.Name())
+	return v.opts.finish(snap, v.pull(snap))
+}
+
+func (v *Vim) pull(snap *fsutil.Snapshot) error {
+	if err := snap.SafeCopy(v.p.VimrcRepo, v.p.VimrcLocal); err != nil {
+		return err
+	}
+	return snap.SafeCopy(v.p.ColorsRepo, v.p.ColorsLocal)
+}
+
+// Push copies the local vimrc and color scheme back into the repo. Both must
+// exist locally.
+func (v *Vim) Push() error {
+	snap := v.opts.snapshot(repoBackup(v.Name()))
+	return v.opts.finish(snap, v.push(snap))
+}
+
+func (v *Vim) push(snap *fsutil.Snapshot) error {
+	if err := snap.SafeCopy(v.p.VimrcLocal, v.p.VimrcRepo); err != nil {
 		return err
 	}
-	return fsutil.SafeCopy(v.p.ColorsRepo, v.p.ColorsLocal, v.Name(), v.opts.BackupRoot)
+	return snap.SafeCopy(v.p.ColorsLocal, v.p.ColorsRepo)
 }
@@ -60,3 +60,52 @@ var _ = Describe("Vim.Pull", func() {
 	})
 
 })
+
+var _ = Describe("Vim.Push", func() {
+	var (
+		tmp      string
+		repoRoot string
+		home     string
+		opts     components.Options
+	)
+
+	BeforeEach(func() {
+		tmp = GinkgoT().TempDir()
+		repoRoot = filepath.Join(tmp, "repo")
+		home = filepath.Join(tmp, "home")
+		Expect(os.MkdirAll(filepath.Join(repoRoot, "vim"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(repoRoot, "vim", "vimrc"), []byte("OLD"), 0o644)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(home, ".vim", "colors"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(home, ".vimrc"), []byte("LOCAL_VIMRC"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(home, ".vim", "colors", "sublimemonokai.vim"), []byte("LOCAL_COLORS"), 0o644)).To(Succeed())
+
+		opts = components.Options{
+			RepoRoot:   repoRoot,
+			Home:       home,
+			BackupRoot: filepath.Join(tmp, "backups"),
+			Stdout:     &bytes.Buffer{},
+			Stderr:     &bytes.Buffer{},
+		}
+	})
+
+	It("copies the local vimrc and colors into the repo, backing up the repo copy", func() {
+		Expect(components.NewVim(opts).Push()).To(Succeed())
+
+		b, err := os.ReadFile(filepath.Join(repoRoot, "vim", "vimrc"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("LOCAL_VIMRC"))
+
+		b, err = os.ReadFile(filepath.Join(repoRoot, "vim", "colors", "sublimemonokai.vim"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("LOCAL_COLORS"))
+
+		b, err = os.ReadFile(filepath.Join(opts.BackupRoot, "vim-repo", "v1", "vimrc"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("OLD"))
+	})
+
+	It("fails when the local vimrc is missing", func() {
+		Expect(os.Remove(filepath.Join(home, ".vimrc"))).To(Succeed())
+		Expect(components.NewVim(opts).Push()).NotTo(Succeed())
+	})
+})
@@ -23,32 +23,55 @@ func (z *Zsh) Name() string { return "zsh" }
 
 // Pull copies zshrc, aliases, and profile into HOME.
 func (z *Zsh) Pull() error {
+	snap := z.opts.snapshot(z.Name())
+	return z.opts.finish(snap, z.pull(snap))
+}
+
+func (z *Zsh) pull(snap *fsutil.Snapshot) error {
 	copies := []struct{ src, dst string }{
 		{z.p.ZshrcRepo, z.p.ZshrcLocal},
 		{z.p.AliasesRepo, z.p.AliasesLocal},
 		{z.p.ProfileRepo, z.p.ProfileLocal},
 	}
 	for _, c := range copies {
-		if err := fsutil.SafeCopy(c.src, c.dst, z.Name(), z.opts.BackupRoot); err != nil {
+		if err := snap.SafeCopy(c.src, c.dst); err != nil {
 			return err
 		}
 	}
-	if _, err := os.Stat(z.p.FuncsRepo); err == nil {
-		if err := fsutil.SafeCopy(z.p.FuncsRepo, z.p.FuncsLocal, z.Name(), z.opts.BackupRoot); err != nil {
-			return err
-		}
+	if err := copyIfPresent(snap, z.p.FuncsRepo, z.p.FuncsLocal); err != nil {
+		return err
+	}
+	return z.seedSecret(snap)
+}
+
+// Push copies zshrc and aliases (required) plus funcs and profile (when
+// present locally) back into the repo. ~/.zshrc_secret is never pushed.
+func (z *Zsh) Push() error {
+	snap := z.opts.snapshot(repoBackup(z.Name()))
+	return z.opts.finish(snap, z.push(snap))
+}
+
+func (z *Zsh) push(snap *fsutil.Snapshot) error {
+	if err := snap.SafeCopy(z.p.ZshrcLocal, z.p.ZshrcRepo); err != nil {
+		return err
+	}
+	if err := snap.SafeCopy(z.p.AliasesLocal, z.p.AliasesRepo); err != nil {
+		return err
+	}
+	if err := copyIfPresent(snap, z.p.FuncsLocal, z.p.FuncsRepo); err != nil {
+		return err
 	}
-	return z.seedSecret()
+	return copyIfPresent(snap, z.p.ProfileLocal, z.p.ProfileRepo)
 }
 
 // seedSecret copies the template to ~/.zshrc_secret iff the local file does
 // not yet exist. Existing local secrets are left untouched (they hold real keys).
-func (z *Zsh) seedSecret() error {
+func (z *Zsh) seedSecret(snap *fsutil.Snapshot) error {
 	if _, err := os.Stat(z.p.SecretLocal); err == nil {
 		return nil
 	}
 	if _, err := os.Stat(z.p.SecretTemplate); err != nil {
 		return nil
 	}
-	return fsutil.SafeCopy(z.p.SecretTemplate, z.p.SecretLocal, z.Name(), z.opts.BackupRoot)
+	return snap.SafeCopy(z.p.SecretTemplate, z.p.SecretLocal)
 }
@@ -9,6 +9,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/cloudwalk/machine-setup/internal/components"
+	"github.com/cloudwalk/machine-setup/internal/fsutil"
 )
 
 var _ = Describe("Zsh.Pull", func() {
@@ -87,4 +88,95 @@ var _ = Describe("Zsh.Pull", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(string(b)).To(Equal("MY_KEY=abc"))
 	})
+
+	It("backs up every overwritten file into a single snapshot", func() {
+		Expect(os.MkdirAll(home, 0o755)).To(Succeed())
+		for _, name := range []string{".zshrc", ".zshrc_aliases", ".profile"} {
+			Expect(os.WriteFile(filepath.Join(home, name), []byte("OLD"), 0o644)).To(Succeed())
+		}
+
+		Expect(components.NewZsh(opts).Pull()).To(Succeed())
+
+		versions, err := fsutil.Versions(filepath.Join(opts.BackupRoot, "zsh"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions).To(Equal([]int{1}))
+		meta, err := fsutil.ReadMeta(filepath.Join(opts.BackupRoot, "zsh", "v1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(meta.Files).To(HaveLen(3))
+	})
+
+	It("neither backs up nor rewrites files that already match the repo", func() {
+		Expect(components.NewZsh(opts).Pull()).To(Succeed())
+		Expect(components.NewZsh(opts).Pull()).To(Succeed())
+
+		Expect(filepath.Join(opts.BackupRoot, "zsh")).NotTo(BeAnExistingFile())
+		Expect(opts.Stdout.(*bytes.Buffer).String()).To(ContainSubstring("0 changed, 3 unchanged, 0 new"))
+	})
+
+	It("only describes its backups and copies in a dry run", func() {
+		Expect(os.MkdirAll(home, 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(home, ".zshrc"), []byte("OLD"), 0o644)).To(Succeed())
+		opts.DryRun = true
+
+		Expect(components.NewZsh(opts).Pull()).To(Succeed())
+
+		out := opts.Stdout.(*bytes.Buffer).String()
+		Expect(out).To(ContainSubstring("back up " + filepath.Join(home, ".zshrc")))
+		Expect(out).To(ContainSubstring("create " + filepath.Join(home, ".profile")))
+		Expect(filepath.Join(home, ".profile")).NotTo(BeAnExistingFile())
+		Expect(opts.BackupRoot).NotTo(BeAnExistingFile())
+		b, err := os.ReadFile(filepath.Join(home, ".zshrc"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("OLD"))
+	})
+})
+
+var _ = Describe("Zsh.Push", func() {
+	var (
+		tmp      string
+		repoRoot string
+		home     string
+		opts     components.Options
+	)
+
+	BeforeEach(func() {
+		tmp = GinkgoT().TempDir()
+		repoRoot = filepath.Join(tmp, "repo")
+		home = filepath.Join(tmp, "home")
+		Expect(os.MkdirAll(filepath.Join(repoRoot, "zsh"), 0o755)).To(Succeed())
+		Expect(os.MkdirAll(home, 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(home, ".zshrc"), []byte("ZSHRC"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(home, ".zshrc_aliases"), []byte("ALIASES"), 0o644)).To(Succeed())
+
+		opts = components.Options{
+			RepoRoot:   repoRoot,
+			Home:       home,
+			BackupRoot: filepath.Join(tmp, "backups"),
+			Stdout:     &bytes.Buffer{},
+			Stderr:     &bytes.Buffer{},
+		}
+	})
+
+	It("copies zshrc and aliases into the repo and skips absent optional files", func() {
+		Expect(components.NewZsh(opts).Push()).To(Succeed())
+
+		b, err := os.ReadFile(filepath.Join(repoRoot, "zsh", "zshrc_aliases"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(b)).To(Equal("ALIASES"))
+
+		_, err = os.Stat(filepath.Join(repoRoot, "zsh", "profile"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("never pushes ~/.zshrc_secret", func() {
+		Expect(os.WriteFile(filepath.Join(home, ".zshrc_secret"), []byte("MY_KEY=abc"), 0o600)).To(Succeed())
+
+		Expect(components.NewZsh(opts).Push()).To(Succeed())
+
+		entries, err := os.ReadDir(filepath.Join(repoRoot, "zsh"))
+		Expect(err).NotTo(HaveOccurred())
+		for _, e := range entries {
+			Expect(e.Name()).NotTo(ContainSubstring("secret"))
+		}
+	})
 })
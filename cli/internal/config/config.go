@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -11,15 +12,50 @@ import (
 // Config is the top-level machine-setup configuration.
 type Config struct {
 	Architecture string    `mapstructure:"architecture" yaml:"architecture"`
-	Sources      []string  `mapstructure:"sources"      yaml:"sources"`
+	Sources      []Source  `mapstructure:"sources"      yaml:"sources"`
 	Packages     []Package `mapstructure:"packages"     yaml:"packages"`
 	Apps         []App     `mapstructure:"apps"         yaml:"apps"`
 }
 
 // Package represents a managed package abstracted over package managers.
+// Version optionally pins it: "1.22" for brew's go@1.22, a full Debian
+// version for apt, a release ("0.11.6") for the Neovim AppImage. A release
+// download also takes "latest" or a range such as ">=0.10 <0.12", which
+// setup resolves once and records in Resolved so later runs install the same
+// release.
 type Package struct {
-	Name    string `mapstructure:"name"    yaml:"name"`
-	Manager string `mapstructure:"manager" yaml:"manager"` // "brew" | "apt"
+	Name     string `mapstructure:"name"     yaml:"name"`
+	Manager  string `mapstructure:"manager"  yaml:"manager"` // "brew" | "apt"
+	Version  string `mapstructure:"version"  yaml:"version,omitempty"`
+	Resolved string `mapstructure:"resolved" yaml:"resolved,omitempty"`
+}
+
+// DefaultCacheDir returns $XDG_CACHE_HOME/machine-setup, falling back to
+// ~/.cache/machine-setup.
+func DefaultCacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "machine-setup")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "machine-setup")
+	}
+	return filepath.Join(home, ".cache", "machine-setup")
+}
+
+// Source is a third-party apt repository, e.g. the GitHub CLI's. Key is the
+// URL of the repository's signing key, which must have Fingerprint. Format
+// is "sources" for a deb822 .sources file (the default) or "list" for a
+// one-line-per-suite .list file.
+type Source struct {
+	Name          string   `mapstructure:"name"          yaml:"name"`
+	URI           string   `mapstructure:"uri"           yaml:"uri"`
+	Suites        []string `mapstructure:"suites"        yaml:"suites"`
+	Components    []string `mapstructure:"components"    yaml:"components,omitempty"`
+	Architectures []string `mapstructure:"architectures" yaml:"architectures,omitempty"`
+	Key           string   `mapstructure:"key"           yaml:"key"`
+	Fingerprint   string   `mapstructure:"fingerprint"   yaml:"fingerprint"`
+	Format        string   `mapstructure:"format"        yaml:"format,omitempty"`
 }
 
 // App represents a desktop application to track.
@@ -46,7 +82,7 @@ func Init(path string) (*Config, error) {
 	v := viper.New()
 
 	v.SetDefault("architecture", runtime.GOARCH)
-	v.SetDefault("sources", []string{})
+	v.SetDefault("sources", []map[string]any{})
 	v.SetDefault("packages", []map[string]string{})
 	v.SetDefault("apps", []map[string]string{})
 
@@ -59,6 +95,9 @@ func Init(path string) (*Config, error) {
 		if err := v.ReadInConfig(); err != nil {
 			return nil, err
 		}
+		if err := checkSources(v); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
@@ -77,6 +116,44 @@ func Init(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// Read loads the config at path without creating or rewriting it. A missing
+// file reads as an empty Config.
+func Read(path string) (*Config, error) {
+	var cfg Config
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &cfg, nil
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	if err := checkSources(v); err != nil {
+		return nil, err
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// checkSources rejects the old form of sources, a list of strings, by name:
+// a bare string says nothing of the repository's URI, suites or signing key,
+// so it cannot be carried over and has to be rewritten by hand.
+func checkSources(v *viper.Viper) error {
+	sources, ok := v.Get("sources").([]any)
+	if !ok {
+		return nil
+	}
+	for i, s := range sources {
+		if s, ok := s.(string); ok {
+			return fmt.Errorf("%s: sources[%d] is %q, a bare string; each source is now an apt repository with name, uri, suites, key and fingerprint", v.ConfigFileUsed(), i, s)
+		}
+	}
+	return nil
+}
+
 // Save writes cfg back to path, preserving any unrecognized keys already in the file.
 func Save(path string, cfg *Config) error {
 	v := viper.New()
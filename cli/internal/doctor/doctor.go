@@ -0,0 +1,290 @@
+// Package doctor checks that a machine is in the state the repo expects:
+// tools installed, shell and prompt set up, fonts registered. Every check
+// reads the machine through a Probe so tests can describe one declaratively.
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Status is the outcome of one check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn" // works, but not the way the repo sets things up
+	Fail Status = "fail"
+)
+
+// Result is one check's verdict. Fix is a remediation hint for anything
+// that did not pass.
+type Result struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// Probe is the read-only view of the machine the checks use.
+type Probe interface {
+	LookPath(file string) (string, error)
+	Output(name string, args ...string) (string, error)
+	Getenv(key string) string
+	Exists(path string) bool
+}
+
+// Host is the production Probe over the real OS.
+type Host struct{}
+
+func (Host) LookPath(file string) (string, error) { return exec.LookPath(file) }
+func (Host) Getenv(key string) string             { return os.Getenv(key) }
+
+func (Host) Output(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	return string(out), err
+}
+
+func (Host) Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// MinNvim is the oldest Neovim the repo's config supports — the same
+// threshold scripts/init.sh checks before installing the AppImage.
+var MinNvim = [2]int{0, 10}
+
+// commands maps registry names to the executables that prove they are
+// installed, when the two differ. Debian ships bat as batcat and yarn as
+// yarnpkg.
+var commands = map[string][]string{
+	"neovim":  {"nvim"},
+	"ripgrep": {"rg"},
+	"python":  {"python3", "python"},
+	"node":    {"node", "nodejs"},
+	"bat":     {"bat", "batcat"},
+	"yarn":    {"yarn", "yarnpkg"},
+}
+
+// Checker runs every check against Probe.
+type Checker struct {
+	Probe Probe
+	GOOS  string
+	Home  string
+	// Tools is the registry's tool names for GOOS; Wanted is the subset the
+	// config selected. A missing wanted tool fails, any other only warns.
+	Tools  []string
+	Wanted []string
+	// Fonts are the font file names under the repo's fonts/ dir and FontDir
+	// where pull installs them.
+	Fonts   []string
+	FontDir string
+}
+
+// Run returns the results of every check, in a stable order.
+func (c Checker) Run() []Result {
+	out := c.tools()
+	out = append(out,
+		c.nvim(),
+		c.loginShell(),
+		c.dir("oh-my-zsh", filepath.Join(c.Home, ".oh-my-zsh")),
+		c.dir("powerlevel10k", filepath.Join(c.Home, ".oh-my-zsh", "custom", "themes", "powerlevel10k")),
+		c.fonts(),
+		c.localBin(),
+	)
+	return out
+}
+
+func (c Checker) tools() []Result {
+	wanted := make(map[string]bool, len(c.Wanted))
+	for _, n := range c.Wanted {
+		wanted[n] = true
+	}
+	out := make([]Result, 0, len(c.Tools))
+	for _, name := range c.Tools {
+		r := Result{Check: "tool " + name}
+		if path, ok := c.find(name); ok {
+			r.Status, r.Detail = Pass, path
+		} else {
+			r.Status, r.Detail = Warn, "not on PATH"
+			if wanted[name] {
+				r.Status, r.Detail = Fail, "selected in config but not on PATH"
+			}
+			r.Fix = fmt.Sprintf("run `machine-setup setup` and select %s", name)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func (c Checker) find(name string) (string, bool) {
+	candidates, ok := commands[name]
+	if !ok {
+		candidates = []string{name}
+	}
+	for _, cmd := range candidates {
+		if path, err := c.Probe.LookPath(cmd); err == nil {
+			return path, true
+		}
+	}
+	// rvm is a shell function once sourced; its script lives under ~/.rvm.
+	if name == "rvm" && c.Probe.Exists(filepath.Join(c.Home, ".rvm", "bin", "rvm")) {
+		return filepath.Join(c.Home, ".rvm", "bin", "rvm"), true
+	}
+	return "", false
+}
+
+var nvimVersionRe = regexp.MustCompile(`v(\d+)\.(\d+)(\.\d+)?`)
+
+func (c Checker) nvim() Result {
+	r := Result{Check: "nvim version"}
+	fix := "run `machine-setup setup` and select neovim"
+	out, err := c.Probe.Output("nvim", "--version")
+	if err != nil {
+		r.Status, r.Detail, r.Fix = Fail, "nvim not found", fix
+		return r
+	}
+	first, _, _ := strings.Cut(out, "\n")
+	m := nvimVersionRe.FindStringSubmatch(first)
+	if m == nil {
+		r.Status, r.Detail, r.Fix = Warn, fmt.Sprintf("cannot parse %q", first), fix
+		return r
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	r.Detail = strings.TrimPrefix(m[0], "v")
+	if major > MinNvim[0] || (major == MinNvim[0] && minor >= MinNvim[1]) {
+		r.Status = Pass
+		return r
+	}
+	r.Status = Fail
+	r.Detail += fmt.Sprintf(" is older than %d.%d", MinNvim[0], MinNvim[1])
+	r.Fix = fix
+	return r
+}
+
+func (c Checker) loginShell() Result {
+	r := Result{Check: "login shell"}
+	shell := c.userShell()
+	if shell == "" {
+		r.Status, r.Detail = Warn, "cannot determine the login shell"
+		r.Fix = "check with `echo $SHELL`"
+		return r
+	}
+	r.Detail = shell
+	if filepath.Base(shell) == "zsh" {
+		r.Status = Pass
+		return r
+	}
+	r.Status = Fail
+	r.Fix = "chsh -s \"$(command -v zsh)\""
+	return r
+}
+
+// userShell asks the account database for the login shell, falling back to
+// $SHELL (which reflects the shell at login, not later chsh calls).
+func (c Checker) userShell() string {
+	user := c.Probe.Getenv("USER")
+	if user != "" {
+		switch c.GOOS {
+		case "darwin":
+			if out, err := c.Probe.Output("dscl", ".", "-read", "/Users/"+user, "UserShell"); err == nil {
+				if _, shell, ok := strings.Cut(strings.TrimSpace(out), ":"); ok {
+					return strings.TrimSpace(shell)
+				}
+			}
+		default:
+			if out, err := c.Probe.Output("getent", "passwd", user); err == nil {
+				fields := strings.Split(strings.TrimSpace(out), ":")
+				if len(fields) == 7 {
+					return fields[6]
+				}
+			}
+		}
+	}
+	return c.Probe.Getenv("SHELL")
+}
+
+func (c Checker) dir(name, path string) Result {
+	r := Result{Check: name, Detail: path}
+	if c.Probe.Exists(path) {
+		r.Status = Pass
+		return r
+	}
+	r.Status = Fail
+	r.Detail += " is missing"
+	r.Fix = fmt.Sprintf("run `machine-setup setup` to install %s", name)
+	return r
+}
+
+func (c Checker) fonts() Result {
+	r := Result{Check: "fonts"}
+	if len(c.Fonts) == 0 {
+		r.Status, r.Detail = Pass, "repo has no fonts"
+		return r
+	}
+	var missing []string
+	if c.GOOS == "darwin" {
+		for _, f := range c.Fonts {
+			if !c.Probe.Exists(filepath.Join(c.FontDir, f)) {
+				missing = append(missing, f)
+			}
+		}
+	} else {
+		listed, err := c.Probe.Output("fc-list")
+		if err != nil {
+			r.Status, r.Detail = Warn, "fc-list unavailable; cannot tell which fonts are registered"
+			r.Fix = "install fontconfig"
+			return r
+		}
+		for _, f := range c.Fonts {
+			if !strings.Contains(listed, "/"+f+":") {
+				missing = append(missing, f)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		r.Status, r.Detail = Pass, fmt.Sprintf("%d font(s) registered", len(c.Fonts))
+		return r
+	}
+	r.Status = Fail
+	r.Detail = "not registered: " + strings.Join(missing, ", ")
+	r.Fix = "machine-setup pull --only fonts"
+	if c.GOOS != "darwin" {
+		r.Fix += " && fc-cache -f"
+	}
+	return r
+}
+
+func (c Checker) localBin() Result {
+	dir := filepath.Join(c.Home, ".local", "bin")
+	r := Result{Check: "~/.local/bin on PATH", Detail: dir}
+	for _, p := range filepath.SplitList(c.Probe.Getenv("PATH")) {
+		if filepath.Clean(p) == dir {
+			r.Status = Pass
+			return r
+		}
+	}
+	// Only Linux installs anything there (the Neovim AppImage).
+	r.Status = Fail
+	if c.GOOS == "darwin" {
+		r.Status = Warn
+	}
+	r.Detail += " is not on PATH"
+	r.Fix = `add export PATH="$HOME/.local/bin:$PATH" to ~/.zshrc`
+	return r
+}
+
+// Summary counts results by status.
+func Summary(results []Result) map[Status]int {
+	out := map[Status]int{Pass: 0, Warn: 0, Fail: 0}
+	for _, r := range results {
+		out[r.Status]++
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+package doctor_test
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/doctor"
+)
+
+// fakeProbe describes a machine: executables on PATH, command outputs,
+// environment and existing paths.
+type fakeProbe struct {
+	bins    map[string]string
+	outputs map[string]string
+	env     map[string]string
+	paths   map[string]bool
+}
+
+func (p fakeProbe) LookPath(file string) (string, error) {
+	if path, ok := p.bins[file]; ok {
+		return path, nil
+	}
+	return "", errors.New("not found")
+}
+
+func (p fakeProbe) Output(name string, args ...string) (string, error) {
+	key := strings.Join(append([]string{name}, args...), " ")
+	if out, ok := p.outputs[key]; ok {
+		return out, nil
+	}
+	return "", errors.New("exit status 1")
+}
+
+func (p fakeProbe) Getenv(key string) string { return p.env[key] }
+func (p fakeProbe) Exists(path string) bool  { return p.paths[path] }
+
+var _ = Describe("Checker", func() {
+	var (
+		probe   fakeProbe
+		checker doctor.Checker
+	)
+
+	byCheck := func(results []doctor.Result, name string) doctor.Result {
+		for _, r := range results {
+			if r.Check == name {
+				return r
+			}
+		}
+		Fail("no result for " + name)
+		return doctor.Result{}
+	}
+
+	// A healthy linux machine; each test breaks one thing.
+	BeforeEach(func() {
+		probe = fakeProbe{
+			bins: map[string]string{"rg": "/usr/bin/rg", "jq": "/usr/bin/jq", "batcat": "/usr/bin/batcat"},
+			outputs: map[string]string{
+				"nvim --version":    "NVIM v0.11.6\nBuild type: Release\n",
+				"getent passwd dev": "dev:x:1000:1000::/home/dev:/usr/bin/zsh\n",
+				"fc-list":           "/home/dev/.local/share/fonts/Hack Regular.ttf: Hack:style=Regular\n",
+			},
+			env: map[string]string{"USER": "dev", "PATH": "/usr/bin:/home/dev/.local/bin"},
+			paths: map[string]bool{
+				"/home/dev/.oh-my-zsh":                             true,
+				"/home/dev/.oh-my-zsh/custom/themes/powerlevel10k": true,
+			},
+		}
+		checker = doctor.Checker{
+			GOOS:    "linux",
+			Home:    "/home/dev",
+			Tools:   []string{"ripgrep", "jq", "bat"},
+			Fonts:   []string{"Hack Regular.ttf"},
+			FontDir: "/home/dev/.local/share/fonts",
+		}
+	})
+
+	run := func() []doctor.Result {
+		checker.Probe = probe
+		return checker.Run()
+	}
+
+	It("passes every check on a machine set up as the repo expects", func() {
+		results := run()
+		Expect(doctor.Summary(results)).To(Equal(map[doctor.Status]int{doctor.Pass: 9, doctor.Warn: 0, doctor.Fail: 0}))
+		Expect(byCheck(results, "tool bat").Detail).To(Equal("/usr/bin/batcat"))
+		Expect(byCheck(results, "nvim version").Detail).To(Equal("0.11.6"))
+	})
+
+	It("finds yarn under Debian's yarnpkg name", func() {
+		checker.Tools = append(checker.Tools, "yarn")
+		probe.bins["yarnpkg"] = "/usr/bin/yarnpkg"
+
+		result := byCheck(run(), "tool yarn")
+		Expect(result.Status).To(Equal(doctor.Pass))
+		Expect(result.Detail).To(Equal("/usr/bin/yarnpkg"))
+	})
+
+	It("warns about a missing registry tool, and fails when the config selected it", func() {
+		delete(probe.bins, "jq")
+		Expect(byCheck(run(), "tool jq").Status).To(Equal(doctor.Warn))
+
+		checker.Wanted = []string{"jq"}
+		r := byCheck(run(), "tool jq")
+		Expect(r.Status).To(Equal(doctor.Fail))
+		Expect(r.Fix).To(ContainSubstring("select jq"))
+	})
+
+	It("fails an nvim older than 0.10", func() {
+		probe.outputs["nvim --version"] = "NVIM v0.9.5\n"
+		r := byCheck(run(), "nvim version")
+		Expect(r.Status).To(Equal(doctor.Fail))
+		Expect(r.Detail).To(ContainSubstring("0.9.5 is older than 0.10"))
+	})
+
+	It("fails when zsh is not the login shell, reading it from the account database", func() {
+		probe.outputs["getent passwd dev"] = "dev:x:1000:1000::/home/dev:/bin/bash\n"
+		probe.env["SHELL"] = "/usr/bin/zsh"
+		r := byCheck(run(), "login shell")
+		Expect(r.Status).To(Equal(doctor.Fail))
+		Expect(r.Fix).To(ContainSubstring("chsh"))
+	})
+
+	It("reads the login shell with dscl on darwin", func() {
+		checker.GOOS = "darwin"
+		probe.outputs["dscl . -read /Users/dev UserShell"] = "UserShell: /bin/zsh\n"
+		delete(probe.outputs, "getent passwd dev")
+		Expect(byCheck(run(), "login shell").Status).To(Equal(doctor.Pass))
+	})
+
+	It("fails when powerlevel10k is missing", func() {
+		delete(probe.paths, "/home/dev/.oh-my-zsh/custom/themes/powerlevel10k")
+		Expect(byCheck(run(), "powerlevel10k").Status).To(Equal(doctor.Fail))
+	})
+
+	It("fails for fonts fc-list does not report, and warns without fc-list", func() {
+		checker.Fonts = append(checker.Fonts, "Hack Bold.ttf")
+		r := byCheck(run(), "fonts")
+		Expect(r.Status).To(Equal(doctor.Fail))
+		Expect(r.Detail).To(Equal("not registered: Hack Bold.ttf"))
+
+		delete(probe.outputs, "fc-list")
+		Expect(byCheck(run(), "fonts").Status).To(Equal(doctor.Warn))
+	})
+
+	It("fails on linux but only warns on darwin when ~/.local/bin is not on PATH", func() {
+		probe.env["PATH"] = "/usr/bin"
+		Expect(byCheck(run(), "~/.local/bin on PATH").Status).To(Equal(doctor.Fail))
+
+		checker.GOOS = "darwin"
+		Expect(byCheck(run(), "~/.local/bin on PATH").Status).To(Equal(doctor.Warn))
+	})
+})
@@ -0,0 +1,160 @@
+// Package drift flattens the components' repo→local path pairs into per-file
+// entries so commands can compare the repo with what is on this machine.
+package drift
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cloudwalk/machine-setup/internal/paths"
+)
+
+// Entry is one managed file: its repo copy and where pull installs it.
+// Either side may be missing on disk.
+type Entry struct {
+	Component string
+	Repo      string
+	Local     string
+}
+
+// Expand flattens pairs into file entries. A tree pair yields one entry per
+// file under its repo dir and, for Mirror trees, one per file that exists
+// only under the local dir. Missing roots yield no entries rather than errors.
+func Expand(component string, pairs []paths.Pair) ([]Entry, error) {
+	var out []Entry
+	for _, p := range pairs {
+		if !p.Tree {
+			out = append(out, Entry{Component: component, Repo: p.Repo, Local: p.Local})
+			continue
+		}
+		rels, err := files(p.Repo)
+		if err != nil {
+			return nil, err
+		}
+		if p.Mirror {
+			local, err := files(p.Local)
+			if err != nil {
+				return nil, err
+			}
+			rels = union(rels, local)
+		}
+		for _, rel := range rels {
+			out = append(out, Entry{
+				Component: component,
+				Repo:      filepath.Join(p.Repo, rel),
+				Local:     filepath.Join(p.Local, rel),
+			})
+		}
+	}
+	return out, nil
+}
+
+// files lists the regular files under root as sorted root-relative paths.
+func files(root string) ([]string, error) {
+	var rels []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rels = append(rels, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(rels)
+	return rels, nil
+}
+
+func union(a, b []string) []string {
+	seen := make(map[string]bool, len(a))
+	for _, s := range a {
+		seen[s] = true
+	}
+	out := append([]string(nil), a...)
+	for _, s := range b {
+		if !seen[s] {
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// State classifies how a managed file's local copy relates to the repo.
+type State string
+
+const (
+	InSync       State = "in-sync"
+	Modified     State = "modified"
+	MissingLocal State = "missing-local"
+	MissingRepo  State = "missing-repo"
+	ModeDiffers  State = "mode-differs"
+	// Absent means neither side exists (e.g. an optional file like
+	// zshrc_funcs that this repo does not ship). It is not drift.
+	Absent State = "absent"
+)
+
+// Drifted reports whether s is a state that a pull or push would change.
+func (s State) Drifted() bool {
+	return s != InSync && s != Absent
+}
+
+// Classify compares e's local copy with its repo copy.
+func Classify(e Entry) (State, error) {
+	repoInfo, repoErr := os.Stat(e.Repo)
+	localInfo, localErr := os.Stat(e.Local)
+	for _, err := range []error{repoErr, localErr} {
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	switch {
+	case repoErr != nil && localErr != nil:
+		return Absent, nil
+	case localErr != nil:
+		return MissingLocal, nil
+	case repoErr != nil:
+		return MissingRepo, nil
+	}
+
+	same, err := sameContent(e.Repo, e.Local, repoInfo, localInfo)
+	if err != nil {
+		return "", err
+	}
+	if !same {
+		return Modified, nil
+	}
+	if repoInfo.Mode().Perm() != localInfo.Mode().Perm() {
+		return ModeDiffers, nil
+	}
+	return InSync, nil
+}
+
+func sameContent(a, b string, aInfo, bInfo os.FileInfo) (bool, error) {
+	if aInfo.Size() != bInfo.Size() {
+		return false, nil
+	}
+	ab, err := os.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	bb, err := os.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ab, bb), nil
+}
@@ -0,0 +1,13 @@
+package drift_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDriftSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "drift Suite")
+}
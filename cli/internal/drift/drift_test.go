@@ -0,0 +1,115 @@
+package drift_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/drift"
+	"github.com/cloudwalk/machine-setup/internal/paths"
+)
+
+var _ = Describe("Expand", func() {
+	var tmp string
+
+	BeforeEach(func() {
+		tmp = GinkgoT().TempDir()
+	})
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	It("passes file pairs through unchanged, even when missing on disk", func() {
+		entries, err := drift.Expand("vim", []paths.Pair{{Repo: "/r/vimrc", Local: "/h/.vimrc"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal([]drift.Entry{{Component: "vim", Repo: "/r/vimrc", Local: "/h/.vimrc"}}))
+	})
+
+	It("expands an overlay tree to the repo's files only", func() {
+		write(filepath.Join(tmp, "repo", "bin", "1_git"), "x")
+		write(filepath.Join(tmp, "home", "bin", "local_only"), "y")
+
+		entries, err := drift.Expand("byobu", []paths.Pair{{
+			Repo: filepath.Join(tmp, "repo", "bin"), Local: filepath.Join(tmp, "home", "bin"), Tree: true,
+		}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal([]drift.Entry{{
+			Component: "byobu",
+			Repo:      filepath.Join(tmp, "repo", "bin", "1_git"),
+			Local:     filepath.Join(tmp, "home", "bin", "1_git"),
+		}}))
+	})
+
+	It("includes local-only files of a mirror tree, recursing into subdirs", func() {
+		write(filepath.Join(tmp, "repo", "nvim", "init.lua"), "x")
+		write(filepath.Join(tmp, "home", "nvim", "lua", "stale.lua"), "y")
+
+		entries, err := drift.Expand("nvim", []paths.Pair{{
+			Repo: filepath.Join(tmp, "repo", "nvim"), Local: filepath.Join(tmp, "home", "nvim"), Tree: true, Mirror: true,
+		}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Local).To(Equal(filepath.Join(tmp, "home", "nvim", "init.lua")))
+		Expect(entries[1].Repo).To(Equal(filepath.Join(tmp, "repo", "nvim", "lua", "stale.lua")))
+	})
+
+	It("yields nothing for a tree whose roots do not exist", func() {
+		entries, err := drift.Expand("fonts", []paths.Pair{{
+			Repo: filepath.Join(tmp, "nope"), Local: filepath.Join(tmp, "nada"), Tree: true, Mirror: true,
+		}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Classify", func() {
+	var (
+		tmp   string
+		entry drift.Entry
+	)
+
+	BeforeEach(func() {
+		tmp = GinkgoT().TempDir()
+		entry = drift.Entry{Component: "zsh", Repo: filepath.Join(tmp, "zshrc"), Local: filepath.Join(tmp, ".zshrc")}
+	})
+
+	classify := func() drift.State {
+		s, err := drift.Classify(entry)
+		Expect(err).NotTo(HaveOccurred())
+		return s
+	}
+
+	It("is in sync when content and mode match", func() {
+		Expect(os.WriteFile(entry.Repo, []byte("x"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(entry.Local, []byte("x"), 0o644)).To(Succeed())
+		Expect(classify()).To(Equal(drift.InSync))
+	})
+
+	It("is modified when content differs", func() {
+		Expect(os.WriteFile(entry.Repo, []byte("x"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(entry.Local, []byte("y"), 0o644)).To(Succeed())
+		Expect(classify()).To(Equal(drift.Modified))
+	})
+
+	It("reports a permissions-only difference", func() {
+		Expect(os.WriteFile(entry.Repo, []byte("x"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(entry.Local, []byte("x"), 0o644)).To(Succeed())
+		Expect(os.Chmod(entry.Local, 0o644)).To(Succeed())
+		Expect(classify()).To(Equal(drift.ModeDiffers))
+	})
+
+	It("distinguishes a file missing locally, missing in the repo, or absent on both sides", func() {
+		Expect(classify()).To(Equal(drift.Absent))
+		Expect(drift.Absent.Drifted()).To(BeFalse())
+
+		Expect(os.WriteFile(entry.Repo, []byte("x"), 0o644)).To(Succeed())
+		Expect(classify()).To(Equal(drift.MissingLocal))
+
+		Expect(os.Rename(entry.Repo, entry.Local)).To(Succeed())
+		Expect(classify()).To(Equal(drift.MissingRepo))
+	})
+})
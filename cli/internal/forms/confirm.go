@@ -0,0 +1,25 @@
+package forms
+
+import (
+	"github.com/charmbracelet/huh"
+)
+
+// Confirm asks a yes/no question, defaulting to "no". When there is no
+// terminal to prompt on (see Interactive) it returns false without prompting,
+// so headless runs never approve a destructive action on the user's behalf.
+func Confirm(title string) (bool, error) {
+	if !Interactive() {
+		return false, nil
+	}
+	var ok bool
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title(title).
+				Affirmative("Yes").
+				Negative("No").
+				Value(&ok),
+		),
+	).Run()
+	return ok, err
+}
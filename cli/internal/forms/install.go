@@ -1,18 +1,21 @@
 package forms
 
 import (
-	"os"
+	"errors"
 
 	"github.com/charmbracelet/huh"
 )
 
-// ShowInstallForm displays a multi-select with all dev tool names pre-checked.
-// When MACHINE_SETUP_NO_FORM=1 it returns toolNames unmodified (tests/CI).
-func ShowInstallForm(toolNames []string) ([]string, error) {
-	if os.Getenv("MACHINE_SETUP_NO_FORM") != "" {
-		result := make([]string, len(toolNames))
-		copy(result, toolNames)
-		return result, nil
+// ErrNotInteractive is returned by forms that must have an answer but have no
+// terminal to ask on.
+var ErrNotInteractive = errors.New("no terminal to prompt on")
+
+// ShowInstallForm displays a multi-select with all dev tool names pre-checked,
+// each labelled with its note (e.g. "jq (installed 1.7.1)") when it has one.
+// Without a terminal (see Interactive) it fails rather than guess a selection.
+func ShowInstallForm(toolNames []string, notes map[string]string) ([]string, error) {
+	if !Interactive() {
+		return nil, ErrNotInteractive
 	}
 
 	selected := make([]string, len(toolNames))
@@ -20,7 +23,11 @@ func ShowInstallForm(toolNames []string) ([]string, error) {
 
 	options := make([]huh.Option[string], len(toolNames))
 	for i, name := range toolNames {
-		options[i] = huh.NewOption(name, name).Selected(true)
+		label := name
+		if note := notes[name]; note != "" {
+			label += " (" + note + ")"
+		}
+		options[i] = huh.NewOption(label, name).Selected(true)
 	}
 
 	err := huh.NewForm(
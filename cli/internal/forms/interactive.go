@@ -0,0 +1,14 @@
+package forms
+
+import "os"
+
+// Interactive reports whether forms can prompt: stdin must be a terminal.
+// MACHINE_SETUP_NO_FORM=1 forces non-interactive mode; it is kept for older
+// scripts, new ones should rely on the setup flags instead.
+func Interactive() bool {
+	if os.Getenv("MACHINE_SETUP_NO_FORM") != "" {
+		return false
+	}
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -1,15 +1,13 @@
 package forms
 
 import (
-	"os"
-
 	"github.com/charmbracelet/huh"
 )
 
-// ShowWelcome displays a full-screen welcome Note using huh.
-// Set MACHINE_SETUP_NO_FORM=1 to skip the TUI (used in tests/CI).
+// ShowWelcome displays a full-screen welcome Note using huh. It is skipped
+// when there is no terminal (see Interactive).
 func ShowWelcome() error {
-	if os.Getenv("MACHINE_SETUP_NO_FORM") != "" {
+	if !Interactive() {
 		return nil
 	}
 	return huh.NewForm(
@@ -0,0 +1,155 @@
+package fsutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Counts tallies, per regular file, what a copy did (or would do) to its
+// destination.
+type Counts struct {
+	New       int // absent at the destination
+	Changed   int // content or permission bits differ
+	Unchanged int // byte-identical with the same permission bits; not copied
+	Removed   int // only at the destination of a mirror; deleted
+}
+
+// Add accumulates o into c.
+func (c *Counts) Add(o Counts) {
+	c.New += o.New
+	c.Changed += o.Changed
+	c.Unchanged += o.Unchanged
+	c.Removed += o.Removed
+}
+
+// Dirty reports whether copying would modify the destination at all.
+func (c Counts) Dirty() bool { return c.New+c.Changed+c.Removed > 0 }
+
+// String renders e.g. "1 changed, 2 unchanged, 0 new".
+func (c Counts) String() string {
+	s := fmt.Sprintf("%d changed, %d unchanged, %d new", c.Changed, c.Unchanged, c.New)
+	if c.Removed > 0 {
+		s += fmt.Sprintf(", %d removed", c.Removed)
+	}
+	return s
+}
+
+// Compare classifies every regular file under src against the same relative
+// path under dst. Files only present under dst are ignored, matching a plain
+// (overlay) copy; see Snapshot.Mirror for the replacing variant.
+func Compare(src, dst string) (Counts, error) {
+	return compare(src, dst, false)
+}
+
+func compare(src, dst string, mirror bool) (Counts, error) {
+	var c Counts
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return c, err
+	}
+	dstInfo, err := os.Stat(dst)
+	if os.IsNotExist(err) {
+		n, err := countFiles(src)
+		c.New = n
+		return c, err
+	}
+	if err != nil {
+		return c, err
+	}
+
+	if !srcInfo.IsDir() {
+		if dstInfo.IsDir() {
+			c.Changed = 1
+			return c, nil
+		}
+		same, err := sameFile(src, dst, srcInfo, dstInfo)
+		if same {
+			c.Unchanged = 1
+		} else {
+			c.Changed = 1
+		}
+		return c, err
+	}
+	if !dstInfo.IsDir() {
+		n, err := countFiles(src)
+		c.Changed = n
+		return c, err
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return c, err
+	}
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		seen[e.Name()] = true
+		sub, err := compare(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()), mirror)
+		if err != nil {
+			return c, err
+		}
+		c.Add(sub)
+	}
+	if !mirror {
+		return c, nil
+	}
+	extra, err := os.ReadDir(dst)
+	if err != nil {
+		return c, err
+	}
+	for _, e := range extra {
+		if seen[e.Name()] {
+			continue
+		}
+		n, err := countFiles(filepath.Join(dst, e.Name()))
+		if err != nil {
+			return c, err
+		}
+		// An empty directory still has to go.
+		c.Removed += max(n, 1)
+	}
+	return c, nil
+}
+
+func sameFile(a, b string, aInfo, bInfo os.FileInfo) (bool, error) {
+	if aInfo.Size() != bInfo.Size() || aInfo.Mode().Perm() != bInfo.Mode().Perm() {
+		return false, nil
+	}
+	ah, err := hashFile(a)
+	if err != nil {
+		return false, err
+	}
+	bh, err := hashFile(b)
+	if err != nil {
+		return false, err
+	}
+	return ah == bh, nil
+}
+
+// hashFile returns the hex SHA-256 of path's content.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func countFiles(path string) (int, error) {
+	n := 0
+	err := filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	return n, err
+}
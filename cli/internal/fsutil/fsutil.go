@@ -1,55 +1,30 @@
-// Package fsutil ports backup_file and safe_copy from scripts/lib/common.sh.
+// Package fsutil ports backup_file and safe_copy from scripts/lib/common.sh,
+// grouping the backups one run makes into a single snapshot per component.
 package fsutil
 
 import (
-	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 )
 
 var versionRe = regexp.MustCompile(`^v(\d+)$`)
 
-// Backup copies src into <backupRoot>/<component>/v<N>/ and returns the v<N> dir.
-// N is the highest existing v<digits> directory under the component dir + 1, or 1.
+// Backup copies src into a fresh <backupRoot>/<component>/v<N>/ and returns
+// the v<N> dir. N is the highest existing v<digits> directory under the
+// component dir + 1, or 1. Callers making several backups in one run should
+// use a Snapshot instead so they land in a single version.
 func Backup(src, component, backupRoot string) (string, error) {
-	if _, err := os.Stat(src); err != nil {
-		if os.IsNotExist(err) {
-			return "", nil
-		}
-		return "", err
-	}
-	componentDir := filepath.Join(backupRoot, component)
-	version, err := nextVersion(componentDir)
-	if err != nil {
-		return "", err
-	}
-	dst := filepath.Join(componentDir, fmt.Sprintf("v%d", version))
-	if err := os.MkdirAll(dst, 0o755); err != nil {
-		return "", err
-	}
-	return dst, copyPath(src, filepath.Join(dst, filepath.Base(src)))
+	return NewSnapshot(backupRoot, component, RunInfo{}).Backup(src)
 }
 
 // SafeCopy validates src exists, backs up dst (if present) under component,
 // then copies src to dst, creating dst's parent if needed.
 func SafeCopy(src, dst, component, backupRoot string) error {
-	if _, err := os.Stat(src); err != nil {
-		return err
-	}
-	if _, err := os.Stat(dst); err == nil {
-		if _, err := Backup(dst, component, backupRoot); err != nil {
-			return err
-		}
-	} else if !os.IsNotExist(err) {
-		return err
-	}
-	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
-		return err
-	}
-	return copyPath(src, dst)
+	return NewSnapshot(backupRoot, component, RunInfo{}).SafeCopy(src, dst)
 }
 
 func copyPath(src, dst string) error {
@@ -80,14 +55,27 @@ func copyDir(src, dst string) error {
 }
 
 func nextVersion(componentDir string) (int, error) {
+	vs, err := Versions(componentDir)
+	if err != nil {
+		return 0, err
+	}
+	if len(vs) == 0 {
+		return 1, nil
+	}
+	return vs[len(vs)-1] + 1, nil
+}
+
+// Versions returns the N of every v<N> directory under componentDir in
+// ascending order. A missing componentDir has no versions.
+func Versions(componentDir string) ([]int, error) {
 	entries, err := os.ReadDir(componentDir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return 1, nil
+			return nil, nil
 		}
-		return 0, err
+		return nil, err
 	}
-	max := 0
+	var out []int
 	for _, e := range entries {
 		if !e.IsDir() {
 			continue
@@ -100,19 +88,24 @@ func nextVersion(componentDir string) (int, error) {
 		if err != nil {
 			continue
 		}
-		if n > max {
-			max = n
-		}
+		out = append(out, n)
 	}
-	return max + 1, nil
+	sort.Ints(out)
+	return out, nil
 }
 
+// copyFile copies src's content and permission bits to dst, so executable
+// scripts (byobu/bin) stay executable and synced files don't show mode drift.
 func copyFile(src, dst string) error {
 	in, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
 	out, err := os.Create(dst)
 	if err != nil {
 		return err
@@ -121,5 +114,8 @@ func copyFile(src, dst string) error {
 		_ = out.Close()
 		return err
 	}
-	return out.Close()
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, info.Mode().Perm())
 }
@@ -109,3 +109,18 @@ var _ = Describe("SafeCopy", func() {
 		Expect(err).To(HaveOccurred())
 	})
 })
+
+var _ = Describe("SafeCopy permissions", func() {
+	It("preserves the source file's permission bits", func() {
+		tmp := GinkgoT().TempDir()
+		src := filepath.Join(tmp, "script")
+		dst := filepath.Join(tmp, "out", "script")
+		Expect(os.WriteFile(src, []byte("#!/bin/sh\n"), 0o755)).To(Succeed())
+
+		Expect(fsutil.SafeCopy(src, dst, "byobu", filepath.Join(tmp, "backups"))).To(Succeed())
+
+		info, err := os.Stat(dst)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o755)))
+	})
+})
@@ -0,0 +1,120 @@
+package fsutil
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ObjectsDir holds the content-addressed store under a backup root. Every
+// file in a snapshot is a hard link to <root>/.objects/<ab>/<sha256>-<perm>,
+// so backing up the same content again costs a directory entry, not a copy.
+const ObjectsDir = ".objects"
+
+// storePath copies src into the snapshot at dst, file by file, through the
+// object store under root.
+func storePath(root, src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return storeFile(root, src, dst, info)
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := storePath(root, filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func storeFile(root, src, dst string, info os.FileInfo) error {
+	sum, err := hashFile(src)
+	if err != nil {
+		return err
+	}
+	obj := filepath.Join(root, ObjectsDir, sum[:2], fmt.Sprintf("%s-%o", sum, info.Mode().Perm()))
+	if _, err := os.Stat(obj); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(obj), 0o755); err != nil {
+			return err
+		}
+		tmp := obj + ".tmp"
+		if err := copyFile(src, tmp); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, obj); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	// Filesystems without hard links still get a correct, if larger, backup.
+	if err := os.Link(obj, dst); err == nil {
+		return nil
+	}
+	return copyFile(obj, dst)
+}
+
+// PruneObjects deletes every object under root that no snapshot links to any
+// more — run it after removing snapshot directories. It returns how many
+// objects it removed.
+func PruneObjects(root string) (int, error) {
+	objects := filepath.Join(root, ObjectsDir)
+	// Index snapshot files by size so each object is only compared with
+	// plausible candidates.
+	bySize := map[int64][]os.FileInfo{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == objects {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || d.Name() == MetaFile {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		bySize[info.Size()] = append(bySize[info.Size()], info)
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	removed := 0
+	err = filepath.WalkDir(objects, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		for _, f := range bySize[info.Size()] {
+			if os.SameFile(info, f) {
+				return nil
+			}
+		}
+		removed++
+		return os.Remove(path)
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return removed, err
+}
@@ -0,0 +1,238 @@
+package fsutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MetaFile is the name of the metadata file written into every snapshot dir.
+const MetaFile = ".snapshot.json"
+
+// RunInfo describes the run that is taking backups. It is recorded in each
+// snapshot's metadata so a backup can be traced to the command that made it.
+type RunInfo struct {
+	Time    time.Time // run start; zero means "when the snapshot is created"
+	Command string    // e.g. "machine-setup pull --only zsh"
+	Commit  string    // repo HEAD at run time, if known
+}
+
+// Meta is the content of a snapshot's MetaFile.
+type Meta struct {
+	Time    time.Time  `json:"time"`
+	Command string     `json:"command,omitempty"`
+	Commit  string     `json:"commit,omitempty"`
+	Files   []BackedUp `json:"files"`
+}
+
+// BackedUp maps one top-level entry of a snapshot to the absolute path it
+// was copied from.
+type BackedUp struct {
+	Entry    string `json:"entry"`
+	Original string `json:"original"`
+}
+
+// Snapshot collects every backup one run makes for a component into a single
+// <backupRoot>/<component>/v<N> directory. The directory is created lazily on
+// the first backup, so a run that overwrites nothing leaves no trace. Copies
+// made through it skip destinations that are already identical and are
+// tallied in Counts.
+type Snapshot struct {
+	root      string
+	component string
+	dir       string
+	meta      Meta
+	counts    Counts
+	dryRun    bool
+	planned   []string
+}
+
+// NewSnapshot returns an empty snapshot for component under backupRoot.
+func NewSnapshot(backupRoot, component string, run RunInfo) *Snapshot {
+	return &Snapshot{
+		root:      backupRoot,
+		component: component,
+		meta:      Meta{Time: run.Time, Command: run.Command, Commit: run.Commit},
+	}
+}
+
+// NewPlan returns a snapshot that only describes what it would do: backups,
+// copies and removals are recorded in Planned and the filesystem is left
+// untouched. Counts are tallied as for a real run.
+func NewPlan(backupRoot, component string) *Snapshot {
+	s := NewSnapshot(backupRoot, component, RunInfo{})
+	s.dryRun = true
+	return s
+}
+
+// Planned returns the actions a NewPlan snapshot recorded, in order.
+func (s *Snapshot) Planned() []string { return s.planned }
+
+// Dir returns the snapshot's v<N> directory, or "" if nothing was backed up yet.
+func (s *Snapshot) Dir() string { return s.dir }
+
+// Counts returns the per-file tally of every SafeCopy and Mirror so far.
+func (s *Snapshot) Counts() Counts { return s.counts }
+
+// Backup copies src into the snapshot and returns the snapshot dir. A missing
+// src is a no-op (empty dir, nil error). Backing up the same path twice keeps
+// the first copy, i.e. the state from before the run touched it.
+func (s *Snapshot) Backup(src string) (string, error) {
+	if _, err := os.Stat(src); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range s.meta.Files {
+		if f.Original == abs {
+			return s.dir, nil
+		}
+	}
+	if err := s.ensureDir(); err != nil {
+		return "", err
+	}
+	entry := s.entryName(filepath.Base(src))
+	if s.dryRun {
+		s.meta.Files = append(s.meta.Files, BackedUp{Entry: entry, Original: abs})
+		s.planned = append(s.planned, fmt.Sprintf("back up %s to %s", abs, filepath.Join(s.dir, entry)))
+		return s.dir, nil
+	}
+	if err := storePath(s.root, src, filepath.Join(s.dir, entry)); err != nil {
+		return "", err
+	}
+	s.meta.Files = append(s.meta.Files, BackedUp{Entry: entry, Original: abs})
+	return s.dir, s.writeMeta()
+}
+
+// SafeCopy validates src exists, backs up dst (if present) into the
+// snapshot, then copies src to dst, creating dst's parent if needed. When
+// every file of src already matches dst, neither the backup nor the copy
+// happens. Files only present under dst are left alone.
+func (s *Snapshot) SafeCopy(src, dst string) error {
+	c, err := compare(src, dst, false)
+	if err != nil {
+		return err
+	}
+	return s.apply(src, dst, c, false)
+}
+
+// Mirror makes dst an exact copy of src, deleting files only present under
+// dst. Like SafeCopy it backs up dst first and does nothing at all when the
+// two trees already match.
+func (s *Snapshot) Mirror(src, dst string) error {
+	c, err := compare(src, dst, true)
+	if err != nil {
+		return err
+	}
+	return s.apply(src, dst, c, true)
+}
+
+func (s *Snapshot) apply(src, dst string, c Counts, replace bool) error {
+	s.counts.Add(c)
+	if !c.Dirty() {
+		return nil
+	}
+	_, statErr := os.Stat(dst)
+	existed := statErr == nil
+	if _, err := s.Backup(dst); err != nil {
+		return err
+	}
+	if s.dryRun {
+		s.planned = append(s.planned, planLine(src, dst, c, existed, replace))
+		return nil
+	}
+	if replace {
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return copyPath(src, dst)
+}
+
+func (s *Snapshot) ensureDir() error {
+	if s.dir != "" {
+		return nil
+	}
+	componentDir := filepath.Join(s.root, s.component)
+	version, err := nextVersion(componentDir)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(componentDir, fmt.Sprintf("v%d", version))
+	if !s.dryRun {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	if s.meta.Time.IsZero() {
+		s.meta.Time = time.Now()
+	}
+	s.dir = dir
+	return nil
+}
+
+func planLine(src, dst string, c Counts, existed, replace bool) string {
+	verb := "overwrite"
+	switch {
+	case !existed:
+		verb = "create"
+	case replace:
+		verb = "replace"
+	}
+	line := fmt.Sprintf("%s %s from %s", verb, dst, src)
+	if c.New+c.Changed+c.Unchanged+c.Removed > 1 {
+		line += " (" + c.String() + ")"
+	}
+	return line
+}
+
+// entryName returns base, suffixed with ~2, ~3, … if an earlier backup in
+// this snapshot already used it (two files with the same basename).
+func (s *Snapshot) entryName(base string) string {
+	name := base
+	for n := 2; s.hasEntry(name); n++ {
+		name = fmt.Sprintf("%s~%d", base, n)
+	}
+	return name
+}
+
+func (s *Snapshot) hasEntry(name string) bool {
+	for _, f := range s.meta.Files {
+		if f.Entry == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Snapshot) writeMeta() error {
+	b, err := json.MarshalIndent(s.meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, MetaFile), append(b, '\n'), 0o644)
+}
+
+// ReadMeta loads the metadata of the snapshot in dir. Snapshots written
+// before metadata existed return an error satisfying os.IsNotExist.
+func ReadMeta(dir string) (Meta, error) {
+	var m Meta
+	b, err := os.ReadFile(filepath.Join(dir, MetaFile))
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return m, fmt.Errorf("reading %s: %w", MetaFile, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,229 @@
+package fsutil_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/fsutil"
+)
+
+var _ = Describe("Snapshot", func() {
+	var (
+		tmp        string
+		backupRoot string
+		run        fsutil.RunInfo
+	)
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		tmp = GinkgoT().TempDir()
+		backupRoot = filepath.Join(tmp, "backups")
+		run = fsutil.RunInfo{
+			Time:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			Command: "machine-setup pull",
+			Commit:  "abc123",
+		}
+	})
+
+	It("puts every file a run backs up into one version dir", func() {
+		write(filepath.Join(tmp, "a", ".zshrc"), "A")
+		write(filepath.Join(tmp, "a", ".zsh_aliases"), "B")
+		snap := fsutil.NewSnapshot(backupRoot, "zsh", run)
+
+		Expect(snap.Backup(filepath.Join(tmp, "a", ".zshrc"))).To(Equal(filepath.Join(backupRoot, "zsh", "v1")))
+		Expect(snap.Backup(filepath.Join(tmp, "a", ".zsh_aliases"))).To(Equal(filepath.Join(backupRoot, "zsh", "v1")))
+
+		Expect(filepath.Join(backupRoot, "zsh", "v2")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(backupRoot, "zsh", "v1", ".zshrc")).To(BeAnExistingFile())
+		Expect(filepath.Join(backupRoot, "zsh", "v1", ".zsh_aliases")).To(BeAnExistingFile())
+	})
+
+	It("records the run and each file's original path in the metadata", func() {
+		src := filepath.Join(tmp, ".vimrc")
+		write(src, "x")
+		snap := fsutil.NewSnapshot(backupRoot, "vim", run)
+		Expect(snap.SafeCopy(src, filepath.Join(tmp, "home", ".vimrc"))).To(Succeed())
+		_, err := snap.Backup(src)
+		Expect(err).NotTo(HaveOccurred())
+
+		meta, err := fsutil.ReadMeta(snap.Dir())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(meta.Time.Equal(run.Time)).To(BeTrue())
+		Expect(meta.Command).To(Equal("machine-setup pull"))
+		Expect(meta.Commit).To(Equal("abc123"))
+		Expect(meta.Files).To(Equal([]fsutil.BackedUp{{Entry: ".vimrc", Original: src}}))
+	})
+
+	It("disambiguates two originals with the same basename", func() {
+		write(filepath.Join(tmp, "one", "init.lua"), "1")
+		write(filepath.Join(tmp, "two", "init.lua"), "2")
+		snap := fsutil.NewSnapshot(backupRoot, "nvim", run)
+		_, err := snap.Backup(filepath.Join(tmp, "one", "init.lua"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = snap.Backup(filepath.Join(tmp, "two", "init.lua"))
+		Expect(err).NotTo(HaveOccurred())
+
+		got, err := os.ReadFile(filepath.Join(snap.Dir(), "init.lua~2"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(got)).To(Equal("2"))
+	})
+
+	It("creates nothing when the run overwrites nothing", func() {
+		snap := fsutil.NewSnapshot(backupRoot, "vim", run)
+		Expect(snap.SafeCopy(writeTemp(tmp), filepath.Join(tmp, "fresh", ".vimrc"))).To(Succeed())
+
+		Expect(snap.Dir()).To(BeEmpty())
+		Expect(filepath.Join(backupRoot, "vim")).NotTo(BeAnExistingFile())
+	})
+
+	It("reports legacy snapshots without metadata as not existing", func() {
+		dir := filepath.Join(backupRoot, "vim", "v1")
+		Expect(os.MkdirAll(dir, 0o755)).To(Succeed())
+		_, err := fsutil.ReadMeta(dir)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+})
+
+func writeTemp(dir string) string {
+	path := filepath.Join(dir, "src")
+	Expect(os.WriteFile(path, []byte("src"), 0o644)).To(Succeed())
+	return path
+}
+
+var _ = Describe("Snapshot content awareness", func() {
+	var (
+		tmp        string
+		backupRoot string
+	)
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		tmp = GinkgoT().TempDir()
+		backupRoot = filepath.Join(tmp, "backups")
+	})
+
+	It("skips both the backup and the copy when dst is identical", func() {
+		write(filepath.Join(tmp, "src"), "same")
+		write(filepath.Join(tmp, "dst"), "same")
+		snap := fsutil.NewSnapshot(backupRoot, "vim", fsutil.RunInfo{})
+
+		Expect(snap.SafeCopy(filepath.Join(tmp, "src"), filepath.Join(tmp, "dst"))).To(Succeed())
+
+		Expect(snap.Dir()).To(BeEmpty())
+		Expect(snap.Counts()).To(Equal(fsutil.Counts{Unchanged: 1}))
+	})
+
+	It("treats a permission change as a change", func() {
+		write(filepath.Join(tmp, "src"), "same")
+		write(filepath.Join(tmp, "dst"), "same")
+		Expect(os.Chmod(filepath.Join(tmp, "src"), 0o755)).To(Succeed())
+		snap := fsutil.NewSnapshot(backupRoot, "byobu", fsutil.RunInfo{})
+
+		Expect(snap.SafeCopy(filepath.Join(tmp, "src"), filepath.Join(tmp, "dst"))).To(Succeed())
+
+		Expect(snap.Counts()).To(Equal(fsutil.Counts{Changed: 1}))
+		info, err := os.Stat(filepath.Join(tmp, "dst"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o755)))
+	})
+
+	It("counts changed, unchanged, new and removed files when mirroring a tree", func() {
+		write(filepath.Join(tmp, "repo", "init.lua"), "NEW INIT")
+		write(filepath.Join(tmp, "repo", "lua", "keys.lua"), "keys")
+		write(filepath.Join(tmp, "repo", "lua", "added.lua"), "added")
+		write(filepath.Join(tmp, "local", "init.lua"), "OLD INIT")
+		write(filepath.Join(tmp, "local", "lua", "keys.lua"), "keys")
+		write(filepath.Join(tmp, "local", "stale.lua"), "stale")
+		snap := fsutil.NewSnapshot(backupRoot, "nvim", fsutil.RunInfo{})
+
+		Expect(snap.Mirror(filepath.Join(tmp, "repo"), filepath.Join(tmp, "local"))).To(Succeed())
+
+		Expect(snap.Counts()).To(Equal(fsutil.Counts{Changed: 1, Unchanged: 1, New: 1, Removed: 1}))
+		Expect(filepath.Join(tmp, "local", "stale.lua")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(tmp, "local", "lua", "added.lua")).To(BeAnExistingFile())
+		Expect(filepath.Join(snap.Dir(), "local", "stale.lua")).To(BeAnExistingFile())
+	})
+
+	It("does nothing when mirroring an identical tree", func() {
+		write(filepath.Join(tmp, "repo", "init.lua"), "init")
+		write(filepath.Join(tmp, "local", "init.lua"), "init")
+		snap := fsutil.NewSnapshot(backupRoot, "nvim", fsutil.RunInfo{})
+
+		Expect(snap.Mirror(filepath.Join(tmp, "repo"), filepath.Join(tmp, "local"))).To(Succeed())
+
+		Expect(snap.Dir()).To(BeEmpty())
+		Expect(snap.Counts().Dirty()).To(BeFalse())
+	})
+
+	It("stores identical content once across snapshots", func() {
+		src := filepath.Join(tmp, ".vimrc")
+		write(src, "set nu")
+		first := fsutil.NewSnapshot(backupRoot, "vim", fsutil.RunInfo{})
+		_, err := first.Backup(src)
+		Expect(err).NotTo(HaveOccurred())
+		second := fsutil.NewSnapshot(backupRoot, "vim", fsutil.RunInfo{})
+		_, err = second.Backup(src)
+		Expect(err).NotTo(HaveOccurred())
+
+		a, err := os.Stat(filepath.Join(first.Dir(), ".vimrc"))
+		Expect(err).NotTo(HaveOccurred())
+		b, err := os.Stat(filepath.Join(second.Dir(), ".vimrc"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first.Dir()).NotTo(Equal(second.Dir()))
+		Expect(os.SameFile(a, b)).To(BeTrue())
+	})
+
+	It("prunes objects no snapshot links to", func() {
+		src := filepath.Join(tmp, ".vimrc")
+		write(src, "set nu")
+		snap := fsutil.NewSnapshot(backupRoot, "vim", fsutil.RunInfo{})
+		_, err := snap.Backup(src)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fsutil.PruneObjects(backupRoot)).To(Equal(0))
+		Expect(os.RemoveAll(snap.Dir())).To(Succeed())
+		Expect(fsutil.PruneObjects(backupRoot)).To(Equal(1))
+	})
+})
+
+var _ = Describe("NewPlan", func() {
+	It("describes backups and copies without touching the filesystem", func() {
+		tmp := GinkgoT().TempDir()
+		backupRoot := filepath.Join(tmp, "backups")
+		src := filepath.Join(tmp, "repo", "vimrc")
+		dst := filepath.Join(tmp, "home", ".vimrc")
+		fresh := filepath.Join(tmp, "home", ".vim", "colors", "monokai.vim")
+		Expect(os.MkdirAll(filepath.Dir(src), 0o755)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Dir(dst), 0o755)).To(Succeed())
+		Expect(os.WriteFile(src, []byte("new"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(dst, []byte("old"), 0o644)).To(Succeed())
+
+		plan := fsutil.NewPlan(backupRoot, "vim")
+		Expect(plan.SafeCopy(src, dst)).To(Succeed())
+		Expect(plan.SafeCopy(src, fresh)).To(Succeed())
+
+		Expect(plan.Planned()).To(Equal([]string{
+			"back up " + dst + " to " + filepath.Join(backupRoot, "vim", "v1", ".vimrc"),
+			"overwrite " + dst + " from " + src,
+			"create " + fresh + " from " + src,
+		}))
+		Expect(plan.Counts()).To(Equal(fsutil.Counts{Changed: 1, New: 1}))
+		Expect(backupRoot).NotTo(BeAnExistingFile())
+		Expect(fresh).NotTo(BeAnExistingFile())
+		got, err := os.ReadFile(dst)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(got)).To(Equal("old"))
+	})
+})
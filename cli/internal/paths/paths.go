@@ -122,3 +122,52 @@ func ForOS(repoRoot, home, goos string) Paths {
 		},
 	}
 }
+
+// Pair is one repo→local mapping managed by a component. Tree pairs name
+// directories that are compared file by file; Mirror trees are replaced
+// wholesale on pull (nvim/), so files that exist only locally count as drift
+// too, while other trees (byobu/bin, fonts) only overlay the repo's files.
+type Pair struct {
+	Repo   string
+	Local  string
+	Tree   bool
+	Mirror bool
+}
+
+// PairsFor returns the repo→local pairs the named component manages, or nil
+// for an unknown name. The zsh secret is deliberately absent: it is seeded
+// once from a template and never synced.
+func (p Paths) PairsFor(component string) []Pair {
+	switch component {
+	case "vim":
+		return []Pair{
+			{Repo: p.Vim.VimrcRepo, Local: p.Vim.VimrcLocal},
+			{Repo: p.Vim.ColorsRepo, Local: p.Vim.ColorsLocal},
+		}
+	case "zsh":
+		return []Pair{
+			{Repo: p.Zsh.ZshrcRepo, Local: p.Zsh.ZshrcLocal},
+			{Repo: p.Zsh.AliasesRepo, Local: p.Zsh.AliasesLocal},
+			{Repo: p.Zsh.FuncsRepo, Local: p.Zsh.FuncsLocal},
+			{Repo: p.Zsh.ProfileRepo, Local: p.Zsh.ProfileLocal},
+		}
+	case "byobu":
+		return []Pair{
+			{Repo: p.Byobu.TmuxConfRepo, Local: p.Byobu.TmuxConfLocal},
+			{Repo: p.Byobu.KeybindingsRepo, Local: p.Byobu.KeybindingsLocal},
+			{Repo: p.Byobu.DatetimeRepo, Local: p.Byobu.DatetimeLocal},
+			{Repo: p.Byobu.StatusrcRepo, Local: p.Byobu.StatusrcLocal},
+			{Repo: p.Byobu.BinRepo, Local: p.Byobu.BinLocal, Tree: true},
+		}
+	case "nvim":
+		return []Pair{
+			{Repo: p.Nvim.Repo, Local: p.Nvim.Local, Tree: true, Mirror: true},
+			{Repo: p.Nvim.MonokaiRepo, Local: filepath.Join(p.Nvim.MonokaiLocal, "monokai.lua")},
+		}
+	case "fonts":
+		return []Pair{
+			{Repo: p.Fonts.Repo, Local: p.Fonts.Local, Tree: true},
+		}
+	}
+	return nil
+}
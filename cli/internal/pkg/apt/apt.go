@@ -1,27 +1,52 @@
-// Package apt provides polymorphic Installable implementations for the
-// Debian/Ubuntu side of the CLI's curated install list. Each installable
-// type (Package, NeovimAppImage) is its own kind that knows how to install
-// itself — no dispatcher map, no type switches.
+// Package apt provides the Installable for the Debian/Ubuntu side of the
+// CLI's curated install list: Package, an apt package that knows how to
+// install itself — no dispatcher map, no type switches.
 package apt
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"net/http"
-	"os"
 	"os/exec"
-	"path/filepath"
-	"runtime"
+	"strings"
+	"time"
 )
 
 // Runner runs an apt subcommand. Production wiring shells out to
-// `sudo apt …`; tests inject a recorder.
+// `sudo apt-get …`; tests inject a recorder.
 type Runner func(args []string, stdout, stderr io.Writer) error
 
-// DefaultRunner returns the production Runner.
+// LockTimeout is how long an apt-get run waits for the dpkg lock, which
+// unattended-upgrades tends to hold for the first minutes of a fresh VM.
+const LockTimeout = 5 * time.Minute
+
+// DefaultRunner returns the production Runner. It runs apt-get, whose output
+// is stable for scripts unlike apt's, with DEBIAN_FRONTEND=noninteractive so
+// no package stops to ask a question, waiting up to LockTimeout for the dpkg
+// lock instead of failing at once.
 func DefaultRunner() Runner {
 	return func(args []string, stdout, stderr io.Writer) error {
-		cmd := exec.Command("sudo", append([]string{"apt"}, args...)...)
+		cmd := exec.Command("sudo", append([]string{
+			"env", "DEBIAN_FRONTEND=noninteractive",
+			"apt-get", "-o", fmt.Sprintf("DPkg::Lock::Timeout=%d", int(LockTimeout.Seconds())),
+		}, args...)...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		return cmd.Run()
+	}
+}
+
+// command renders apt-get args as the command line DefaultRunner executes,
+// leaving out its environment and lock options.
+func command(args []string) string {
+	return "sudo apt-get " + strings.Join(args, " ")
+}
+
+// DefaultQueryRunner returns the production Runner for read-only package
+// queries: it runs `dpkg-query` directly, without sudo.
+func DefaultQueryRunner() Runner {
+	return func(args []string, stdout, stderr io.Writer) error {
+		cmd := exec.Command("dpkg-query", args...)
 		cmd.Stdout = stdout
 		cmd.Stderr = stderr
 		return cmd.Run()
@@ -36,70 +61,87 @@ var aptNames = map[string]string{
 }
 
 // Package is an apt-installable package referenced by its brew-style name.
-// Install resolves the name to the apt package and runs `apt install -y`.
+// Install resolves the name to the apt package and runs `apt-get install -y`;
+// Detect asks dpkg-query through query.
 type Package struct {
-	name string
-	run  Runner
+	name    string
+	version string
+	run     Runner
+	query   Runner
 }
 
-// NewPackage returns a Package bound to a runner.
-func NewPackage(name string, run Runner) Package {
-	return Package{name: name, run: run}
+// NewPackage returns a Package bound to an install runner and a query runner.
+func NewPackage(name string, run, query Runner) Package {
+	return Package{name: name, run: run, query: query}
+}
+
+// At returns p pinned to version, installed as `apt-get install <pkg>=<version>`.
+// apt wants the full Debian version, epoch included (e.g. "2:1.22~2").
+func (p Package) At(version string) Package {
+	p.version = version
+	return p
 }
 
 // Name returns the brew-style name (unresolved). This is what the user sees.
 func (p Package) Name() string { return p.name }
 
-// Install runs `apt install -y <resolved-name>`.
+// Install runs `apt-get install -y <resolved-name>[=<version>]`.
 func (p Package) Install(stdout, stderr io.Writer) error {
-	resolved := p.name
-	if mapped, ok := aptNames[p.name]; ok {
-		resolved = mapped
+	return p.run(p.args(), stdout, stderr)
+}
+
+// Plan returns the apt-get command Install runs.
+func (p Package) Plan() []string { return []string{command(p.args())} }
+
+// Detect reads the package's dpkg status. dpkg-query exits non-zero for a
+// package it has never heard of, which is simply "not installed"; a package
+// that was removed but not purged reports "deinstall ok config-files".
+func (p Package) Detect() (bool, string, error) {
+	var out bytes.Buffer
+	if err := p.query([]string{"-W", "-f=${Status}\t${Version}", p.resolved()}, &out, io.Discard); err != nil {
+		return false, "", nil
 	}
-	return p.run([]string{"install", "-y", resolved}, stdout, stderr)
+	status, version, _ := strings.Cut(out.String(), "\t")
+	if !strings.HasSuffix(status, " installed") {
+		return false, "", nil
+	}
+	return true, strings.TrimSpace(version), nil
 }
 
-// NeovimAppImage installs Neovim by downloading the upstream AppImage to
-// ~/.local/bin/nvim. Used on Linux where the apt package is often outdated.
-type NeovimAppImage struct{}
+// Lock serializes apt installs: dpkg holds one lock for the whole system.
+func (Package) Lock() string { return "dpkg" }
 
-// Name reports "neovim" to match its brew counterpart for the form display.
-func (NeovimAppImage) Name() string { return "neovim" }
+// Version is the pinned version, or empty when whatever the distribution
+// ships will do.
+func (p Package) Version() string { return p.version }
 
-// Install downloads the AppImage and makes it executable.
-func (NeovimAppImage) Install(stdout, stderr io.Writer) error {
-	arch := runtime.GOARCH
-	if arch == "amd64" {
-		arch = "x86_64"
-	} else if arch == "arm64" {
-		arch = "aarch64"
-	}
+func (p Package) args() []string { return []string{"install", "-y", p.BatchArg()} }
 
-	url := fmt.Sprintf("https://github.com/neovim/neovim/releases/download/v0.11.6/nvim-linux-%s.appimage", arch)
-	dest := filepath.Join(os.Getenv("HOME"), ".local", "bin", "nvim")
+// BatchKey lets packages share one `apt-get install -y a b c`.
+func (Package) BatchKey() string { return "apt" }
 
-	fmt.Fprintf(stdout, "Downloading Neovim AppImage to %s...\n", dest)
-	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
-		return fmt.Errorf("creating directory: %w", err)
+// BatchArg is the package's argument to apt-get install: the resolved name,
+// with "=<version>" when pinned.
+func (p Package) BatchArg() string {
+	if p.version != "" {
+		return p.resolved() + "=" + p.version
 	}
+	return p.resolved()
+}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("downloading neovim: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
-	}
+// InstallBatch runs `apt-get install -y <args...>`.
+func (p Package) InstallBatch(args []string, stdout, stderr io.Writer) error {
+	return p.run(append([]string{"install", "-y"}, args...), stdout, stderr)
+}
 
-	out, err := os.Create(dest)
-	if err != nil {
-		return fmt.Errorf("creating file: %w", err)
-	}
-	defer out.Close()
-	if _, err := io.Copy(out, resp.Body); err != nil {
-		return fmt.Errorf("writing file: %w", err)
-	}
-	return os.Chmod(dest, 0o755)
+// BatchPlan returns the apt-get command InstallBatch runs.
+func (Package) BatchPlan(args []string) []string {
+	return []string{command(append([]string{"install", "-y"}, args...))}
 }
 
+func (p Package) resolved() string {
+	if mapped, ok := aptNames[p.name]; ok {
+		return mapped
+	}
+	return p.name
+}
@@ -2,6 +2,7 @@ package apt_test
 
 import (
 	"bytes"
+	"errors"
 	"io"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -18,9 +19,72 @@ var _ = Describe("Package.Install", func() {
 			return nil
 		}
 
-		err := apt.NewPackage("byobu", spy).Install(&bytes.Buffer{}, &bytes.Buffer{})
+		err := apt.NewPackage("byobu", spy, nil).Install(&bytes.Buffer{}, &bytes.Buffer{})
 
 		Expect(err).NotTo(HaveOccurred())
 		Expect(gotArgs).To(Equal([]string{"install", "-y", "byobu"}))
 	})
 })
+
+var _ = Describe("Package.Plan", func() {
+	It("shows the resolved apt name in the exact command", func() {
+		spy := func(_ []string, _, _ io.Writer) error { panic("runner must not be called") }
+
+		Expect(apt.NewPackage("go", spy, nil).Plan()).To(Equal([]string{"sudo apt-get install -y golang"}))
+	})
+})
+
+var _ = Describe("Package.Detect", func() {
+	query := func(out string, err error) apt.Runner {
+		return func(args []string, stdout, _ io.Writer) error {
+			Expect(args).To(Equal([]string{"-W", "-f=${Status}\t${Version}", "python3"}))
+			_, _ = io.WriteString(stdout, out)
+			return err
+		}
+	}
+
+	It("reports the dpkg version of an installed package", func() {
+		installed, version, err := apt.NewPackage("python", nil, query("install ok installed\t3.12.3-0ubuntu1", nil)).Detect()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeTrue())
+		Expect(version).To(Equal("3.12.3-0ubuntu1"))
+	})
+
+	It("treats a removed package that kept its config files as not installed", func() {
+		installed, _, err := apt.NewPackage("python", nil, query("deinstall ok config-files\t3.12.3-0ubuntu1", nil)).Detect()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeFalse())
+	})
+
+	It("treats a package dpkg does not know as not installed", func() {
+		installed, _, err := apt.NewPackage("python", nil, query("", errors.New("exit status 1"))).Detect()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeFalse())
+	})
+})
+
+var _ = Describe("Package.At", func() {
+	It("installs the pinned version with pkg=version", func() {
+		Expect(apt.NewPackage("go", nil, nil).At("2:1.22~2").Plan()).To(Equal([]string{"sudo apt-get install -y golang=2:1.22~2"}))
+	})
+})
+
+var _ = Describe("Package.InstallBatch", func() {
+	It("installs every resolved, pinned argument in one apt-get install", func() {
+		var gotArgs []string
+		spy := func(args []string, _, _ io.Writer) error {
+			gotArgs = args
+			return nil
+		}
+		jq, gop := apt.NewPackage("jq", spy, nil), apt.NewPackage("go", spy, nil).At("2:1.22~2")
+
+		err := jq.InstallBatch([]string{jq.BatchArg(), gop.BatchArg()}, &bytes.Buffer{}, &bytes.Buffer{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gotArgs).To(Equal([]string{"install", "-y", "jq", "golang=2:1.22~2"}))
+		Expect(jq.BatchPlan([]string{"jq", "golang"})).To(Equal([]string{"sudo apt-get install -y jq golang"}))
+	})
+})
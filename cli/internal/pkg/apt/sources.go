@@ -0,0 +1,357 @@
+package apt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloudwalk/machine-setup/internal/config"
+	"github.com/cloudwalk/machine-setup/internal/pkg/verify"
+)
+
+// Where Sources writes repositories and their signing keys.
+const (
+	SourcesDir  = "/etc/apt/sources.list.d"
+	KeyringsDir = "/etc/apt/keyrings"
+)
+
+// managedPrefix starts the name of every file Sources writes, so it never
+// touches a repository added by hand or by another tool.
+const managedPrefix = "machine-setup-"
+
+// Files writes and removes files in root-owned directories.
+type Files interface {
+	Write(path string, data []byte) error
+	Remove(path string) error
+}
+
+// SudoFiles is the production Files: it stages data in a temp file and moves
+// it into place with `sudo install`, creating missing directories.
+type SudoFiles struct{}
+
+func (SudoFiles) Write(path string, data []byte) error {
+	tmp, err := os.CreateTemp("", "machine-setup-apt-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return sudo("install", "-D", "-m", "0644", tmp.Name(), path)
+}
+
+func (SudoFiles) Remove(path string) error { return sudo("rm", "-f", path) }
+
+func sudo(args ...string) error {
+	out, err := exec.Command("sudo", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("sudo %s: %w: %s", strings.Join(args, " "), err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Fetcher downloads the document at url.
+type Fetcher func(url string) ([]byte, error)
+
+// DefaultFetcher returns the production Fetcher, for signing keys: small
+// documents, so anything over 1 MiB is refused.
+func DefaultFetcher() Fetcher {
+	client := &http.Client{Timeout: 30 * time.Second}
+	return func(url string) ([]byte, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: HTTP %d", url, resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > 1<<20 {
+			return nil, fmt.Errorf("GET %s: larger than 1 MiB", url)
+		}
+		return data, nil
+	}
+}
+
+// Sources keeps the third-party apt repositories machine-setup manages in
+// line with Want. Each gets a keyring in Keyrings that its source file in Dir
+// names as signed-by, so the key only vouches for that repository. A key is
+// only written once every primary key in it has the pinned fingerprint. A
+// managed repository no longer in Want is removed along with its keyring.
+// When anything changed, Install refreshes the package indexes through Run.
+type Sources struct {
+	Want     []config.Source
+	Dir      string
+	Keyrings string
+	Files    Files
+	Fetch    Fetcher
+	Run      Runner
+
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// sourceChange is one change towards Want: the steps it takes and how. A
+// source and its key are one change, so a source is never written when its
+// key fails to verify.
+type sourceChange struct {
+	steps []string
+	apply func() error
+}
+
+// Install applies every change, reporting failures per source. The package
+// indexes are refreshed if any change was applied.
+func (s Sources) Install() error {
+	changes, err := s.changes()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	applied := 0
+	for _, c := range changes {
+		for _, step := range c.steps {
+			fmt.Fprintf(s.Stdout, "  %s\n", step)
+		}
+		if err := c.apply(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		applied++
+	}
+	if applied > 0 {
+		fmt.Fprintln(s.Stdout, "Refreshing apt package indexes...")
+		if err := s.Run([]string{"update"}, s.Stdout, s.Stderr); err != nil {
+			errs = append(errs, fmt.Errorf("apt-get update: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Plan describes the changes Install would make, without fetching keys.
+func (s Sources) Plan() []string {
+	changes, err := s.changes()
+	if err != nil {
+		return []string{"invalid: " + err.Error()}
+	}
+	var plan []string
+	for _, c := range changes {
+		plan = append(plan, c.steps...)
+	}
+	if len(plan) > 0 {
+		plan = append(plan, command([]string{"update"}))
+	}
+	return plan
+}
+
+// changes compares Want with what is on disk. An invalid Want is an error
+// before anything is touched.
+func (s Sources) changes() ([]sourceChange, error) {
+	var errs []error
+	seen := map[string]bool{}
+	for _, src := range s.Want {
+		if err := validateSource(src); err != nil {
+			errs = append(errs, err)
+		} else if seen[src.Name] {
+			errs = append(errs, fmt.Errorf("source %q: defined twice", src.Name))
+		}
+		seen[src.Name] = true
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var changes []sourceChange
+	keep := map[string]bool{}
+	for _, src := range s.Want {
+		keyring := filepath.Join(s.Keyrings, managedPrefix+src.Name+".gpg")
+		file := filepath.Join(s.Dir, managedPrefix+src.Name+sourceExt(src))
+		keep[keyring], keep[file] = true, true
+
+		var c sourceChange
+		needsKey := !s.keyringTrusted(keyring, src.Fingerprint)
+		if needsKey {
+			c.steps = append(c.steps, fmt.Sprintf("fetch %s's signing key %s, expecting fingerprint %s",
+				src.Name, src.Key, verify.NormalizeFingerprint(src.Fingerprint)))
+		}
+		content := renderSource(src, keyring)
+		current, err := os.ReadFile(file)
+		needsFile := err != nil || !bytes.Equal(current, content)
+		if needsFile {
+			verb := "add"
+			if err == nil {
+				verb = "update"
+			}
+			c.steps = append(c.steps, fmt.Sprintf("%s source %s: %s", verb, src.Name, file))
+		}
+		if len(c.steps) == 0 {
+			continue
+		}
+		c.apply = func() error {
+			if needsKey {
+				if err := s.installKey(src, keyring); err != nil {
+					return err
+				}
+			}
+			if needsFile {
+				return s.write(file, content)
+			}
+			return nil
+		}
+		changes = append(changes, c)
+	}
+	for _, dir := range []string{s.Dir, s.Keyrings} {
+		for _, path := range managedFiles(dir) {
+			if keep[path] {
+				continue
+			}
+			changes = append(changes, sourceChange{
+				steps: []string{"remove " + path},
+				apply: func() error { return s.remove(path) },
+			})
+		}
+	}
+	return changes, nil
+}
+
+// keyringTrusted reports whether keyring holds exactly the key pinned by
+// fingerprint.
+func (s Sources) keyringTrusted(keyring, fingerprint string) bool {
+	data, err := os.ReadFile(keyring)
+	if err != nil {
+		return false
+	}
+	_, fprs, err := verify.ParseKey(data)
+	return err == nil && checkFingerprints(fprs, fingerprint) == nil
+}
+
+func (s Sources) installKey(src config.Source, keyring string) error {
+	data, err := s.Fetch(src.Key)
+	if err != nil {
+		return fmt.Errorf("source %s: fetching key: %w", src.Name, err)
+	}
+	key, fprs, err := verify.ParseKey(data)
+	if err != nil {
+		return fmt.Errorf("source %s: key %s: %w", src.Name, src.Key, err)
+	}
+	if err := checkFingerprints(fprs, src.Fingerprint); err != nil {
+		return fmt.Errorf("source %s: key %s: %w", src.Name, src.Key, err)
+	}
+	return s.write(keyring, key)
+}
+
+// checkFingerprints requires every key in a keyring to be the pinned one: an
+// extra key would be trusted for the repository too.
+func checkFingerprints(fprs []string, pinned string) error {
+	want := verify.NormalizeFingerprint(pinned)
+	for _, fpr := range fprs {
+		if fpr != want {
+			return fmt.Errorf("has fingerprint %s, want %s", fpr, want)
+		}
+	}
+	return nil
+}
+
+func (s Sources) write(path string, data []byte) error {
+	if err := s.Files.Write(path, data); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+func (s Sources) remove(path string) error {
+	if err := s.Files.Remove(path); err != nil {
+		return fmt.Errorf("removing %s: %w", path, err)
+	}
+	return nil
+}
+
+// managedFiles lists the files in dir that Sources wrote.
+func managedFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), managedPrefix) {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	return paths
+}
+
+var sourceName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+var hexFingerprint = regexp.MustCompile(`^([0-9A-F]{40}|[0-9A-F]{64})$`)
+
+func validateSource(src config.Source) error {
+	var problem string
+	switch {
+	case !sourceName.MatchString(src.Name):
+		problem = "name must be lowercase letters, digits, '.', '_' or '-'"
+	case src.URI == "":
+		problem = "uri is required"
+	case len(src.Suites) == 0:
+		problem = "suites is required"
+	case src.Key == "":
+		problem = "key is required"
+	case !hexFingerprint.MatchString(verify.NormalizeFingerprint(src.Fingerprint)):
+		problem = "fingerprint must be the key's full 40 or 64 hex digit fingerprint"
+	case !slices.Contains([]string{"", "sources", "list"}, src.Format):
+		problem = fmt.Sprintf("unknown format %q (want sources or list)", src.Format)
+	default:
+		return nil
+	}
+	return fmt.Errorf("source %q: %s", src.Name, problem)
+}
+
+func sourceExt(src config.Source) string {
+	if src.Format == "list" {
+		return ".list"
+	}
+	return ".sources"
+}
+
+// renderSource writes src as a deb822 stanza or, for format "list", one
+// deb line per suite.
+func renderSource(src config.Source, keyring string) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Managed by machine-setup: edit the sources in its config instead.\n")
+	if src.Format == "list" {
+		opts := "signed-by=" + keyring
+		if len(src.Architectures) > 0 {
+			opts = "arch=" + strings.Join(src.Architectures, ",") + " " + opts
+		}
+		for _, suite := range src.Suites {
+			fields := append([]string{"deb", "[" + opts + "]", src.URI, suite}, src.Components...)
+			b.WriteString(strings.Join(fields, " ") + "\n")
+		}
+		return b.Bytes()
+	}
+	fmt.Fprintf(&b, "Types: deb\nURIs: %s\nSuites: %s\n", src.URI, strings.Join(src.Suites, " "))
+	if len(src.Components) > 0 {
+		fmt.Fprintf(&b, "Components: %s\n", strings.Join(src.Components, " "))
+	}
+	if len(src.Architectures) > 0 {
+		fmt.Fprintf(&b, "Architectures: %s\n", strings.Join(src.Architectures, " "))
+	}
+	fmt.Fprintf(&b, "Signed-By: %s\n", keyring)
+	return b.Bytes()
+}
@@ -0,0 +1,204 @@
+package apt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/config"
+	"github.com/cloudwalk/machine-setup/internal/pkg/apt"
+)
+
+// The key in testdata/key.asc (and key.gpg, unarmored) has an encryption
+// subkey; two-keys.asc adds an unrelated second key.
+const testFingerprint = "FB81 247E C870 6671 2FA7  69F4 F8C9 FF96 535D A99D"
+
+// dirFiles is a Files writing straight to disk, for temp directories.
+type dirFiles struct{}
+
+func (dirFiles) Write(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (dirFiles) Remove(path string) error { return os.Remove(path) }
+
+var _ = Describe("Sources", func() {
+	var (
+		sources apt.Sources
+		fetched []string
+		updates int
+		stdout  *bytes.Buffer
+	)
+
+	testdata := func(name string) []byte {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		Expect(err).NotTo(HaveOccurred())
+		return data
+	}
+	serve := func(file string) apt.Fetcher {
+		return func(url string) ([]byte, error) {
+			fetched = append(fetched, url)
+			return testdata(file), nil
+		}
+	}
+	gh := func() config.Source {
+		return config.Source{
+			Name:        "gh",
+			URI:         "https://cli.github.com/packages",
+			Suites:      []string{"stable"},
+			Components:  []string{"main"},
+			Key:         "https://cli.github.com/packages/githubcli-archive-keyring.gpg",
+			Fingerprint: testFingerprint,
+		}
+	}
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	BeforeEach(func() {
+		root := GinkgoT().TempDir()
+		fetched, updates, stdout = nil, 0, &bytes.Buffer{}
+		sources = apt.Sources{
+			Dir:      filepath.Join(root, "sources.list.d"),
+			Keyrings: filepath.Join(root, "keyrings"),
+			Files:    dirFiles{},
+			Fetch:    serve("key.asc"),
+			Run: func(args []string, _, _ io.Writer) error {
+				Expect(args).To(Equal([]string{"update"}))
+				updates++
+				return nil
+			},
+			Stdout: stdout,
+			Stderr: io.Discard,
+		}
+	})
+
+	It("adds a deb822 source signed by its own verified keyring, then updates", func() {
+		sources.Want = []config.Source{gh()}
+
+		Expect(sources.Install()).To(Succeed())
+
+		keyring := filepath.Join(sources.Keyrings, "machine-setup-gh.gpg")
+		Expect(read(keyring)).To(Equal(string(testdata("key.gpg"))))
+		Expect(read(filepath.Join(sources.Dir, "machine-setup-gh.sources"))).To(Equal(
+			"# Managed by machine-setup: edit the sources in its config instead.\n" +
+				"Types: deb\n" +
+				"URIs: https://cli.github.com/packages\n" +
+				"Suites: stable\n" +
+				"Components: main\n" +
+				"Signed-By: " + keyring + "\n"))
+		Expect(updates).To(Equal(1))
+	})
+
+	It("writes one deb line per suite for format list", func() {
+		src := gh()
+		src.Format, src.Suites, src.Architectures = "list", []string{"stable", "edge"}, []string{"amd64"}
+		sources.Want = []config.Source{src}
+
+		Expect(sources.Install()).To(Succeed())
+
+		keyring := filepath.Join(sources.Keyrings, "machine-setup-gh.gpg")
+		Expect(read(filepath.Join(sources.Dir, "machine-setup-gh.list"))).To(HaveSuffix(
+			"deb [arch=amd64 signed-by=" + keyring + "] https://cli.github.com/packages stable main\n" +
+				"deb [arch=amd64 signed-by=" + keyring + "] https://cli.github.com/packages edge main\n"))
+	})
+
+	It("accepts an unarmored key", func() {
+		sources.Want, sources.Fetch = []config.Source{gh()}, serve("key.gpg")
+
+		Expect(sources.Install()).To(Succeed())
+	})
+
+	It("refuses a key with another fingerprint and writes nothing", func() {
+		src := gh()
+		src.Fingerprint = "E77A1BF0D3028D124E738D11310BB901C0429E17"
+		sources.Want = []config.Source{src}
+
+		err := sources.Install()
+
+		Expect(err).To(MatchError(ContainSubstring("has fingerprint FB81247EC87066712FA769F4F8C9FF96535DA99D, want E77A1BF0D3028D124E738D11310BB901C0429E17")))
+		Expect(filepath.Join(sources.Dir, "machine-setup-gh.sources")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(sources.Keyrings, "machine-setup-gh.gpg")).NotTo(BeAnExistingFile())
+		Expect(updates).To(BeZero())
+	})
+
+	It("refuses a key file that carries an extra key besides the pinned one", func() {
+		sources.Want, sources.Fetch = []config.Source{gh()}, serve("two-keys.asc")
+
+		Expect(sources.Install()).To(MatchError(ContainSubstring("has fingerprint E77A1BF0")))
+	})
+
+	It("changes nothing, and fetches nothing, when the source is already in place", func() {
+		sources.Want = []config.Source{gh()}
+		Expect(sources.Install()).To(Succeed())
+		fetched, updates = nil, 0
+
+		Expect(sources.Install()).To(Succeed())
+
+		Expect(fetched).To(BeEmpty())
+		Expect(updates).To(BeZero())
+		Expect(sources.Plan()).To(BeEmpty())
+	})
+
+	It("removes a dropped source and its keyring, leaving other files alone", func() {
+		sources.Want = []config.Source{gh()}
+		Expect(sources.Install()).To(Succeed())
+		hand := filepath.Join(sources.Dir, "docker.list")
+		Expect(os.WriteFile(hand, []byte("deb https://download.docker.com/linux/ubuntu noble stable\n"), 0o644)).To(Succeed())
+
+		sources.Want = nil
+		Expect(sources.Install()).To(Succeed())
+
+		Expect(filepath.Join(sources.Dir, "machine-setup-gh.sources")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(sources.Keyrings, "machine-setup-gh.gpg")).NotTo(BeAnExistingFile())
+		Expect(hand).To(BeAnExistingFile())
+		Expect(updates).To(Equal(2))
+	})
+
+	It("plans the changes without fetching keys", func() {
+		sources.Want = []config.Source{gh()}
+
+		Expect(sources.Plan()).To(Equal([]string{
+			"fetch gh's signing key https://cli.github.com/packages/githubcli-archive-keyring.gpg, expecting fingerprint FB81247EC87066712FA769F4F8C9FF96535DA99D",
+			"add source gh: " + filepath.Join(sources.Dir, "machine-setup-gh.sources"),
+			"sudo apt-get update",
+		}))
+		Expect(fetched).To(BeEmpty())
+	})
+
+	It("rejects an invalid source before changing anything", func() {
+		src := gh()
+		src.Fingerprint = "535DA99D" // a short key ID is not a pin
+		sources.Want = []config.Source{src}
+
+		Expect(sources.Install()).To(MatchError(ContainSubstring(`source "gh": fingerprint must be`)))
+		Expect(fetched).To(BeEmpty())
+	})
+
+	It("reports a failed fetch for that source only", func() {
+		other := gh()
+		other.Name = "hashicorp"
+		sources.Want = []config.Source{gh(), other}
+		sources.Fetch = func(url string) ([]byte, error) {
+			if len(fetched) == 0 {
+				fetched = append(fetched, url)
+				return nil, errors.New("connection reset")
+			}
+			return testdata("key.asc"), nil
+		}
+
+		Expect(sources.Install()).To(MatchError(ContainSubstring("source gh: fetching key: connection reset")))
+		Expect(filepath.Join(sources.Dir, "machine-setup-hashicorp.sources")).To(BeAnExistingFile())
+		Expect(updates).To(Equal(1))
+	})
+})
@@ -0,0 +1,60 @@
+package apt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ListsDir is where apt keeps the package indexes `apt-get update` fetches.
+const ListsDir = "/var/lib/apt/lists"
+
+// MaxIndexAge is how old the package indexes may get before a run refreshes
+// them.
+const MaxIndexAge = 6 * time.Hour
+
+// Updating wraps run so the first install it runs is preceded by
+// `apt-get update`, unless the indexes in lists were fetched within maxAge.
+// It refreshes at most once, however many installs follow. A failed refresh
+// is only warned about: the install goes ahead on the indexes there are.
+func Updating(run Runner, lists string, maxAge time.Duration) Runner {
+	var once sync.Once
+	return func(args []string, stdout, stderr io.Writer) error {
+		if len(args) > 0 && args[0] == "install" {
+			once.Do(func() { refresh(run, lists, maxAge, stdout, stderr) })
+		}
+		return run(args, stdout, stderr)
+	}
+}
+
+func refresh(run Runner, lists string, maxAge time.Duration, stdout, stderr io.Writer) {
+	if fetched, ok := indexTime(lists); ok && time.Since(fetched) < maxAge {
+		return
+	}
+	fmt.Fprintln(stdout, "Refreshing apt package indexes...")
+	if err := run([]string{"update"}, stdout, stderr); err != nil {
+		fmt.Fprintf(stderr, "warning: apt-get update failed, installing from the current indexes: %v\n", err)
+	}
+}
+
+// indexTime returns when the newest package index in lists was fetched. A
+// fresh image often ships none, which reports false.
+func indexTime(lists string) (time.Time, bool) {
+	entries, err := os.ReadDir(lists)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var newest time.Time
+	for _, e := range entries {
+		if !strings.Contains(e.Name(), "_Packages") {
+			continue
+		}
+		if info, err := e.Info(); err == nil && info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, !newest.IsZero()
+}
@@ -0,0 +1,87 @@
+package apt_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/apt"
+)
+
+var _ = Describe("Updating", func() {
+	var (
+		lists string
+		calls [][]string
+		fail  error
+	)
+
+	BeforeEach(func() {
+		lists = GinkgoT().TempDir()
+		calls, fail = nil, nil
+	})
+
+	run := func(args []string, _, _ io.Writer) error {
+		calls = append(calls, args)
+		if args[0] == "update" {
+			return fail
+		}
+		return nil
+	}
+	index := func(age time.Duration) {
+		path := filepath.Join(lists, "archive.ubuntu.com_ubuntu_dists_noble_main_binary-amd64_Packages")
+		Expect(os.WriteFile(path, nil, 0o644)).To(Succeed())
+		at := time.Now().Add(-age)
+		Expect(os.Chtimes(path, at, at)).To(Succeed())
+	}
+	install := func(r apt.Runner, name string) error {
+		return r([]string{"install", "-y", name}, &bytes.Buffer{}, &bytes.Buffer{})
+	}
+
+	It("updates once before the first install when there are no indexes", func() {
+		r := apt.Updating(run, lists, time.Hour)
+
+		Expect(install(r, "jq")).To(Succeed())
+		Expect(install(r, "gh")).To(Succeed())
+
+		Expect(calls).To(Equal([][]string{{"update"}, {"install", "-y", "jq"}, {"install", "-y", "gh"}}))
+	})
+
+	It("updates indexes older than maxAge", func() {
+		index(2 * time.Hour)
+
+		Expect(install(apt.Updating(run, lists, time.Hour), "jq")).To(Succeed())
+
+		Expect(calls[0]).To(Equal([]string{"update"}))
+	})
+
+	It("leaves fresh indexes alone", func() {
+		index(time.Minute)
+
+		Expect(install(apt.Updating(run, lists, time.Hour), "jq")).To(Succeed())
+
+		Expect(calls).To(Equal([][]string{{"install", "-y", "jq"}}))
+	})
+
+	It("warns about a failed update and installs anyway", func() {
+		fail = errors.New("temporary failure resolving archive.ubuntu.com")
+		stderr := &bytes.Buffer{}
+
+		err := apt.Updating(run, lists, time.Hour)([]string{"install", "-y", "jq"}, &bytes.Buffer{}, stderr)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stderr.String()).To(ContainSubstring("warning: apt-get update failed"))
+		Expect(calls).To(HaveLen(2))
+	})
+
+	It("does not update for anything but an install", func() {
+		Expect(apt.Updating(run, lists, time.Hour)([]string{"remove", "-y", "jq"}, io.Discard, io.Discard)).To(Succeed())
+
+		Expect(calls).To(Equal([][]string{{"remove", "-y", "jq"}}))
+	})
+})
@@ -0,0 +1,196 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Batchable is implemented by kinds that can share one package-manager
+// invocation with others of the same BatchKey: `brew install a b c` instead
+// of three `brew install` runs. BatchArg is this installable's argument in
+// that invocation, and InstallBatch/BatchPlan run or describe it for a set
+// of such arguments. An empty BatchKey opts out.
+type Batchable interface {
+	BatchKey() string
+	BatchArg() string
+	InstallBatch(args []string, stdout, stderr io.Writer) error
+	BatchPlan(args []string) []string
+}
+
+func batchKey(t Installable) string {
+	if b, ok := t.(Batchable); ok {
+		return b.BatchKey()
+	}
+	return ""
+}
+
+// Batch merges the batchable installables of ordered that share a key into
+// one Batched each, placed where its first member was. A tool only joins a
+// batch when everything it depends on (per deps) in this run is in the same
+// batch, so the result stays in dependency order.
+func Batch(ordered []Installable, deps map[string][]string) []Installable {
+	inRun := map[string]bool{}
+	for _, t := range ordered {
+		inRun[t.Name()] = true
+	}
+	batches := map[string]*Batched{}
+	memberOf := map[string]string{}
+	var out []Installable
+	for _, t := range ordered {
+		key := batchKey(t)
+		if key == "" || !joinable(deps[t.Name()], inRun, memberOf, key) {
+			out = append(out, t)
+			continue
+		}
+		memberOf[t.Name()] = key
+		if b, ok := batches[key]; ok {
+			b.members = append(b.members, t)
+			continue
+		}
+		b := &Batched{key: key, members: []Installable{t}, deps: deps}
+		batches[key] = b
+		out = append(out, b)
+	}
+	// A batch of one is just that installable.
+	for i, t := range out {
+		if b, ok := t.(*Batched); ok && len(b.members) == 1 {
+			out[i] = b.members[0]
+		}
+	}
+	return out
+}
+
+func joinable(deps []string, inRun map[string]bool, memberOf map[string]string, key string) bool {
+	for _, d := range deps {
+		if inRun[d] && memberOf[d] != key {
+			return false
+		}
+	}
+	return true
+}
+
+// Batched installs its members in one invocation. If that fails it installs
+// them one by one, so each failure is attributed to the member at fault; a
+// member whose dependency failed is skipped. Install then returns a
+// *BatchError.
+type Batched struct {
+	key     string
+	members []Installable
+	deps    map[string][]string
+}
+
+// Name lists the members, e.g. "jq, gh, go".
+func (b *Batched) Name() string { return strings.Join(b.Members(), ", ") }
+
+// Members returns the members' names in install order.
+func (b *Batched) Members() []string {
+	names := make([]string, len(b.members))
+	for i, m := range b.members {
+		names[i] = m.Name()
+	}
+	return names
+}
+
+// Plan returns the single batched command.
+func (b *Batched) Plan() []string { return b.first().BatchPlan(b.args()) }
+
+// Detect is never consulted: batches are formed from tools detection already
+// found missing.
+func (b *Batched) Detect() (bool, string, error) { return false, "", nil }
+
+// Version is empty; members carry their own pins in their BatchArg.
+func (b *Batched) Version() string { return "" }
+
+// Lock is the members' shared lock.
+func (b *Batched) Lock() string { return LockOf(b.members[0]) }
+
+func (b *Batched) Install(stdout, stderr io.Writer) error {
+	err := b.first().InstallBatch(b.args(), stdout, stderr)
+	if err == nil {
+		return nil
+	}
+	fmt.Fprintf(stderr, "batch install of %s failed (%v); installing one at a time\n", b.Name(), err)
+	be := &BatchError{Failed: map[string]error{}, Skipped: map[string]string{}}
+	failed := map[string]string{}
+	for _, m := range b.members {
+		name := m.Name()
+		if cause := FailedDependency(b.deps[name], failed); cause != "" {
+			failed[name], be.Skipped[name] = cause, cause
+			continue
+		}
+		if err := m.Install(stdout, stderr); err != nil {
+			failed[name], be.Failed[name] = name, err
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return be
+}
+
+func (b *Batched) first() Batchable { return b.members[0].(Batchable) }
+
+func (b *Batched) args() []string {
+	args := make([]string, len(b.members))
+	for i, m := range b.members {
+		args[i] = m.(Batchable).BatchArg()
+	}
+	return args
+}
+
+// BatchError reports the members of a Batched that failed on their own
+// retry, and those skipped because a dependency in the batch failed (mapped
+// to that dependency).
+type BatchError struct {
+	Failed  map[string]error
+	Skipped map[string]string
+}
+
+func (e *BatchError) Error() string {
+	var parts []string
+	for name, err := range e.Failed {
+		parts = append(parts, fmt.Sprintf("%s: %v", name, err))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Members returns the names of the tools t installs: a Batched's members, or
+// just t's name.
+func Members(t Installable) []string {
+	if b, ok := t.(*Batched); ok {
+		return b.Members()
+	}
+	return []string{t.Name()}
+}
+
+// Outcome is how one tool's install went: Err when it failed, Cause (the
+// failed dependency) when it was skipped.
+type Outcome struct {
+	Name  string
+	Err   error
+	Cause string
+}
+
+// Outcomes splits the result of installing t into one Outcome per tool —
+// one per member for a Batched.
+func Outcomes(t Installable, err error) []Outcome {
+	b, ok := t.(*Batched)
+	if !ok {
+		return []Outcome{{Name: t.Name(), Err: err}}
+	}
+	be, _ := err.(*BatchError)
+	out := make([]Outcome, len(b.members))
+	for i, name := range b.Members() {
+		out[i] = Outcome{Name: name}
+		switch {
+		case be == nil:
+			out[i].Err = err
+		case be.Failed[name] != nil:
+			out[i].Err = be.Failed[name]
+		case be.Skipped[name] != "":
+			out[i].Cause = be.Skipped[name]
+		}
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package pkg_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg"
+)
+
+// batchRecorder collects what a set of batchFakes ran.
+type batchRecorder struct {
+	runs        []string
+	fail        map[string]bool // names whose install fails, alone or in a batch
+	failBatches bool            // every install of more than one name fails
+}
+
+// batchFake is a Batchable whose installs are recorded in rec.
+type batchFake struct {
+	fakeInstallable
+	key string
+	rec *batchRecorder
+}
+
+func (f batchFake) BatchKey() string { return f.key }
+func (f batchFake) BatchArg() string { return f.name }
+func (f batchFake) Install(_, _ io.Writer) error {
+	return f.InstallBatch([]string{f.name}, nil, nil)
+}
+func (f batchFake) InstallBatch(args []string, _, _ io.Writer) error {
+	f.rec.runs = append(f.rec.runs, f.key+" "+strings.Join(args, " "))
+	if f.rec.failBatches && len(args) > 1 {
+		return errors.New("batch failed")
+	}
+	for _, a := range args {
+		if f.rec.fail[a] {
+			return fmt.Errorf("%s failed", a)
+		}
+	}
+	return nil
+}
+func (f batchFake) BatchPlan(args []string) []string {
+	return []string{f.key + " install " + strings.Join(args, " ")}
+}
+
+var _ = Describe("Batch", func() {
+	var rec *batchRecorder
+
+	BeforeEach(func() {
+		rec = &batchRecorder{fail: map[string]bool{}}
+	})
+
+	brew := func(name string) pkg.Installable {
+		return batchFake{fakeInstallable: fakeInstallable{name: name}, key: "brew", rec: rec}
+	}
+	apt := func(name string) pkg.Installable {
+		return batchFake{fakeInstallable: fakeInstallable{name: name}, key: "apt", rec: rec}
+	}
+	members := func(ts []pkg.Installable) [][]string {
+		out := make([][]string, len(ts))
+		for i, t := range ts {
+			out[i] = pkg.Members(t)
+		}
+		return out
+	}
+
+	It("merges installables sharing a key where the first of them was", func() {
+		got := pkg.Batch([]pkg.Installable{
+			brew("jq"), fakeInstallable{name: "rvm"}, apt("curl"), brew("gh"), apt("gpg"),
+		}, nil)
+
+		Expect(members(got)).To(Equal([][]string{{"jq", "gh"}, {"rvm"}, {"curl", "gpg"}}))
+		Expect(got[0].Name()).To(Equal("jq, gh"))
+		Expect(got[0].Plan()).To(Equal([]string{"brew install jq gh"}))
+	})
+
+	It("leaves a lone batchable installable as it is", func() {
+		only := brew("jq")
+
+		Expect(pkg.Batch([]pkg.Installable{only}, nil)).To(Equal([]pkg.Installable{only}))
+	})
+
+	It("keeps out a tool that depends on one outside its batch", func() {
+		deps := map[string][]string{"plugin": {"neovim"}, "yarn": {"node"}}
+
+		got := pkg.Batch([]pkg.Installable{
+			brew("node"), fakeInstallable{name: "neovim"}, brew("yarn"), brew("plugin"),
+		}, deps)
+
+		Expect(members(got)).To(Equal([][]string{{"node", "yarn"}, {"neovim"}, {"plugin"}}))
+	})
+
+	It("installs a batch in one invocation", func() {
+		b := pkg.Batch([]pkg.Installable{brew("jq"), brew("gh")}, nil)[0]
+
+		Expect(b.Install(io.Discard, io.Discard)).To(Succeed())
+		Expect(rec.runs).To(Equal([]string{"brew jq gh"}))
+	})
+
+	It("retries members one by one when the batch fails, attributing failures", func() {
+		rec.fail["gh"] = true
+		deps := map[string][]string{"yarn": {"gh"}}
+		b := pkg.Batch([]pkg.Installable{brew("jq"), brew("gh"), brew("yarn")}, deps)[0]
+
+		err := b.Install(io.Discard, io.Discard)
+
+		Expect(rec.runs).To(Equal([]string{"brew jq gh yarn", "brew jq", "brew gh"}))
+		var be *pkg.BatchError
+		Expect(errors.As(err, &be)).To(BeTrue())
+		Expect(pkg.Outcomes(b, err)).To(Equal([]pkg.Outcome{
+			{Name: "jq"},
+			{Name: "gh", Err: be.Failed["gh"]},
+			{Name: "yarn", Cause: "gh"},
+		}))
+	})
+
+	It("succeeds when every member installs on its own after the batch failed", func() {
+		rec.failBatches = true
+		b := pkg.Batch([]pkg.Installable{brew("jq"), brew("gh")}, nil)[0]
+
+		Expect(b.Install(io.Discard, io.Discard)).To(Succeed())
+		Expect(rec.runs).To(Equal([]string{"brew jq gh", "brew jq", "brew gh"}))
+	})
+})
+
+var _ = Describe("Outcomes", func() {
+	It("reports a plain installable's error under its name", func() {
+		err := errors.New("boom")
+
+		Expect(pkg.Outcomes(fakeInstallable{name: "rvm"}, err)).To(Equal([]pkg.Outcome{{Name: "rvm", Err: err}}))
+	})
+})
@@ -1,8 +1,10 @@
 package brew
 
 import (
+	"bytes"
 	"io"
 	"os/exec"
+	"strings"
 )
 
 // DefaultRunner returns the production Runner that shells out to `brew`.
@@ -15,3 +17,36 @@ func DefaultRunner() Runner {
 		return cmd.Run()
 	}
 }
+
+// InstallLock is the lock every brew install shares: brew refuses to run two
+// installs at once.
+const InstallLock = "brew"
+
+// detectPinned detects ref@version, falling back to the unversioned ref.
+func detectPinned(run Runner, ref, version string, cask bool) (bool, string, error) {
+	if version != "" {
+		if ok, v, err := detect(run, versioned(ref, version), cask); ok || err != nil {
+			return ok, v, err
+		}
+	}
+	return detect(run, ref, cask)
+}
+
+// detect runs `brew list --versions [--cask] <ref>`. brew prints
+// "<name> <version>..." for an installed package and exits non-zero
+// otherwise; with several versions installed the newest is listed last.
+func detect(run Runner, ref string, cask bool) (bool, string, error) {
+	args := []string{"list", "--versions"}
+	if cask {
+		args = append(args, "--cask")
+	}
+	var out bytes.Buffer
+	if err := run(append(args, ref), &out, io.Discard); err != nil {
+		return false, "", nil
+	}
+	fields := strings.Fields(out.String())
+	if len(fields) == 0 {
+		return false, "", nil
+	}
+	return true, fields[len(fields)-1], nil
+}
@@ -18,5 +18,36 @@ func (c Cask) Name() string { return c.name }
 
 // Install runs `brew install --cask <name>`.
 func (c Cask) Install(stdout, stderr io.Writer) error {
-	return c.run([]string{"install", "--cask", c.name}, stdout, stderr)
+	return c.run(c.args(), stdout, stderr)
+}
+
+// Plan returns the brew command Install runs.
+func (c Cask) Plan() []string { return []string{command(c.args())} }
+
+// Detect asks `brew list --versions --cask` whether the cask is installed.
+func (c Cask) Detect() (bool, string, error) { return detect(c.run, c.name, true) }
+
+// Version is empty: casks track their latest release and cannot be pinned.
+func (c Cask) Version() string { return "" }
+
+func (c Cask) args() []string { return []string{"install", "--cask", c.name} }
+
+// Lock is the same brew lock formulas take.
+func (Cask) Lock() string { return InstallLock }
+
+// BatchKey lets casks share one `brew install --cask a b c`; they do not
+// batch with formulas.
+func (Cask) BatchKey() string { return "brew-cask" }
+
+// BatchArg is the cask's argument to a batched install.
+func (c Cask) BatchArg() string { return c.name }
+
+// InstallBatch runs `brew install --cask <args...>`.
+func (c Cask) InstallBatch(args []string, stdout, stderr io.Writer) error {
+	return c.run(append([]string{"install", "--cask"}, args...), stdout, stderr)
+}
+
+// BatchPlan returns the brew command InstallBatch runs.
+func (Cask) BatchPlan(args []string) []string {
+	return []string{command(append([]string{"install", "--cask"}, args...))}
 }
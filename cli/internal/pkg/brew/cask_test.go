@@ -24,3 +24,18 @@ var _ = Describe("Cask.Install", func() {
 		Expect(gotArgs).To(Equal([]string{"install", "--cask", "rustup"}))
 	})
 })
+
+var _ = Describe("Cask.InstallBatch", func() {
+	It("installs every argument in one brew install --cask, apart from formulas", func() {
+		var gotArgs []string
+		spy := func(args []string, _, _ io.Writer) error {
+			gotArgs = args
+			return nil
+		}
+		cask := brew.NewCask("rustup", spy)
+
+		Expect(cask.InstallBatch([]string{"rustup", "iterm2"}, &bytes.Buffer{}, &bytes.Buffer{})).To(Succeed())
+		Expect(gotArgs).To(Equal([]string{"install", "--cask", "rustup", "iterm2"}))
+		Expect(cask.BatchKey()).NotTo(Equal(brew.NewFormula("jq", spy).BatchKey()))
+	})
+})
@@ -1,15 +1,20 @@
 package brew
 
-import "io"
+import (
+	"io"
+	"strings"
+)
 
 // Runner runs a brew subcommand with the given args. Returned errors propagate
 // to the caller; stdout/stderr are streamed to the provided writers.
 type Runner func(args []string, stdout, stderr io.Writer) error
 
-// Formula is a brew package installed via `brew install <name>`.
+// Formula is a brew package installed via `brew install <name>`, or via the
+// versioned formula `brew install <name>@<version>` when pinned.
 type Formula struct {
-	name string
-	run  Runner
+	name    string
+	version string
+	run     Runner
 }
 
 // NewFormula returns a Formula bound to a runner.
@@ -17,10 +22,64 @@ func NewFormula(name string, run Runner) Formula {
 	return Formula{name: name, run: run}
 }
 
+// At returns f pinned to version, e.g. go at "1.22" installs go@1.22. An
+// empty version unpins it.
+func (f Formula) At(version string) Formula {
+	f.version = version
+	return f
+}
+
 // Name returns the formula's brew name.
 func (f Formula) Name() string { return f.name }
 
-// Install runs `brew install <name>`.
+// Install runs `brew install <name>[@<version>]`.
 func (f Formula) Install(stdout, stderr io.Writer) error {
-	return f.run([]string{"install", f.name}, stdout, stderr)
+	return f.run(f.args(), stdout, stderr)
+}
+
+// Plan returns the brew command Install runs.
+func (f Formula) Plan() []string { return []string{command(f.args())} }
+
+// Detect asks `brew list --versions` whether the formula is installed. For a
+// pinned formula that is missing, the unversioned one is reported instead so
+// a mismatch shows up rather than "not installed".
+func (f Formula) Detect() (bool, string, error) {
+	return detectPinned(f.run, f.name, f.version, false)
+}
+
+// Version is the pinned version, or empty when whatever brew installs will do.
+func (f Formula) Version() string { return f.version }
+
+func (f Formula) args() []string { return []string{"install", versioned(f.name, f.version)} }
+
+// versioned returns brew's name for a versioned formula, "<name>@<version>".
+func versioned(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + "@" + version
+}
+
+// command renders brew args as the command line DefaultRunner executes.
+func command(args []string) string {
+	return "brew " + strings.Join(args, " ")
+}
+
+// Lock is brew's install lock; formulas install one at a time.
+func (Formula) Lock() string { return InstallLock }
+
+// BatchKey lets formulas share one `brew install a b c`.
+func (Formula) BatchKey() string { return "brew" }
+
+// BatchArg is the formula's argument to a batched install.
+func (f Formula) BatchArg() string { return versioned(f.name, f.version) }
+
+// InstallBatch runs `brew install <args...>`.
+func (f Formula) InstallBatch(args []string, stdout, stderr io.Writer) error {
+	return f.run(append([]string{"install"}, args...), stdout, stderr)
+}
+
+// BatchPlan returns the brew command InstallBatch runs.
+func (Formula) BatchPlan(args []string) []string {
+	return []string{command(append([]string{"install"}, args...))}
 }
@@ -2,6 +2,7 @@ package brew_test
 
 import (
 	"bytes"
+	"errors"
 	"io"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -24,3 +25,78 @@ var _ = Describe("Formula.Install", func() {
 		Expect(gotArgs).To(Equal([]string{"install", "yarn"}))
 	})
 })
+
+var _ = Describe("Formula.Detect", func() {
+	It("reports the newest version `brew list --versions` prints", func() {
+		spy := func(args []string, stdout, _ io.Writer) error {
+			Expect(args).To(Equal([]string{"list", "--versions", "jq"}))
+			_, _ = io.WriteString(stdout, "jq 1.6 1.7.1\n")
+			return nil
+		}
+
+		installed, version, err := brew.NewFormula("jq", spy).Detect()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeTrue())
+		Expect(version).To(Equal("1.7.1"))
+	})
+
+	It("reports not installed when brew exits non-zero", func() {
+		spy := func(_ []string, _, _ io.Writer) error { return errors.New("exit status 1") }
+
+		installed, _, err := brew.NewFormula("jq", spy).Detect()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeFalse())
+	})
+})
+
+var _ = Describe("Formula.At", func() {
+	It("installs the versioned formula", func() {
+		var gotArgs []string
+		spy := func(args []string, _, _ io.Writer) error {
+			gotArgs = args
+			return nil
+		}
+
+		f := brew.NewFormula("go", spy).At("1.22")
+
+		Expect(f.Install(&bytes.Buffer{}, &bytes.Buffer{})).To(Succeed())
+		Expect(gotArgs).To(Equal([]string{"install", "go@1.22"}))
+		Expect(f.Name()).To(Equal("go"))
+		Expect(f.Version()).To(Equal("1.22"))
+	})
+
+	It("detects the unversioned formula when the pinned one is missing", func() {
+		spy := func(args []string, stdout, _ io.Writer) error {
+			if args[len(args)-1] == "go@1.22" {
+				return errors.New("exit status 1")
+			}
+			_, _ = io.WriteString(stdout, "go 1.23.4\n")
+			return nil
+		}
+
+		installed, version, err := brew.NewFormula("go", spy).At("1.22").Detect()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeTrue())
+		Expect(version).To(Equal("1.23.4"))
+	})
+})
+
+var _ = Describe("Formula.InstallBatch", func() {
+	It("installs every argument in one brew install", func() {
+		var gotArgs []string
+		spy := func(args []string, _, _ io.Writer) error {
+			gotArgs = args
+			return nil
+		}
+		jq, gop := brew.NewFormula("jq", spy), brew.NewFormula("go", spy).At("1.22")
+
+		err := jq.InstallBatch([]string{jq.BatchArg(), gop.BatchArg()}, &bytes.Buffer{}, &bytes.Buffer{})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gotArgs).To(Equal([]string{"install", "jq", "go@1.22"}))
+		Expect(jq.BatchPlan([]string{"jq", "go@1.22"})).To(Equal([]string{"brew install jq go@1.22"}))
+	})
+})
@@ -7,9 +7,10 @@ import "io"
 // `brew install <tap>/<name>` so the tap is fetched on first use and the
 // install resolves to the qualified formula.
 type TappedFormula struct {
-	name string
-	tap  string
-	run  Runner
+	name    string
+	tap     string
+	version string
+	run     Runner
 }
 
 // NewTappedFormula binds a name + its tap to a runner.
@@ -17,13 +18,50 @@ func NewTappedFormula(name, tap string, run Runner) TappedFormula {
 	return TappedFormula{name: name, tap: tap, run: run}
 }
 
+// At returns t pinned to version, installing <tap>/<name>@<version>. The tap
+// must publish that versioned formula.
+func (t TappedFormula) At(version string) TappedFormula {
+	t.version = version
+	return t
+}
+
 // Name returns the formula's unqualified name (what the user sees).
 func (t TappedFormula) Name() string { return t.name }
 
 // Install taps then installs.
 func (t TappedFormula) Install(stdout, stderr io.Writer) error {
-	if err := t.run([]string{"tap", t.tap}, stdout, stderr); err != nil {
-		return err
+	for _, args := range t.steps() {
+		if err := t.run(args, stdout, stderr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Plan returns the two brew commands Install runs.
+func (t TappedFormula) Plan() []string {
+	steps := t.steps()
+	out := make([]string, len(steps))
+	for i, args := range steps {
+		out[i] = command(args)
+	}
+	return out
+}
+
+// Detect asks `brew list --versions` about the tap-qualified formula.
+func (t TappedFormula) Detect() (bool, string, error) {
+	return detectPinned(t.run, t.tap+"/"+t.name, t.version, false)
+}
+
+// Version is the pinned version, or empty when whatever the tap ships will do.
+func (t TappedFormula) Version() string { return t.version }
+
+func (t TappedFormula) steps() [][]string {
+	return [][]string{
+		{"tap", t.tap},
+		{"install", versioned(t.tap+"/"+t.name, t.version)},
 	}
-	return t.run([]string{"install", t.tap + "/" + t.name}, stdout, stderr)
 }
+
+// Lock is brew's: both the tap and the install take it.
+func (TappedFormula) Lock() string { return InstallLock }
@@ -28,3 +28,14 @@ var _ = Describe("TappedFormula.Install", func() {
 		}))
 	})
 })
+
+var _ = Describe("TappedFormula.Plan", func() {
+	It("lists both brew commands without running them", func() {
+		spy := func(_ []string, _, _ io.Writer) error { panic("runner must not be called") }
+
+		Expect(brew.NewTappedFormula("terraform", "hashicorp/tap", spy).Plan()).To(Equal([]string{
+			"brew tap hashicorp/tap",
+			"brew install hashicorp/tap/terraform",
+		}))
+	})
+})
@@ -0,0 +1,332 @@
+// Package cache keeps what setup downloads — release archives and their
+// checksum files, install scripts, signing keys, git clones — under the XDG
+// cache directory, so a machine re-provisioned, or a fleet of VMs
+// provisioned, fetches each once. Files are stored by their SHA-256, so
+// identical content is kept once whatever its URL. Offline, only what is
+// cached is used, and anything else fails at once instead of waiting on the
+// network.
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/download"
+	"github.com/cloudwalk/machine-setup/internal/pkg/verify"
+)
+
+// ErrOffline is the error, wrapped, for anything Offline needs the network
+// for.
+var ErrOffline = errors.New("offline")
+
+// Kinds of Entry.
+const (
+	KindFile = "file"
+	KindGit  = "git"
+)
+
+// Cache is the download cache in Dir:
+//
+//	objects/<sha256>      a downloaded file, named by its content's hash
+//	urls/<key>.json       the Entry for a URL
+//	git/<key>.git         a shallow mirror of a git repository
+//
+// where key is the SHA-256 of the URL.
+type Cache struct {
+	Dir     string
+	Offline bool
+}
+
+// Entry is what the cache holds for a URL: a file (by SHA256) or a git
+// mirror.
+type Entry struct {
+	URL     string    `json:"url"`
+	Kind    string    `json:"kind"`
+	SHA256  string    `json:"sha256,omitempty"`
+	Size    int64     `json:"size"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// Getter wraps next for documents that never change once published, such
+// as a release's assets: a cached copy is used without asking the network.
+// One that turns out to be bad, failing its checksum, is dropped with Evict
+// so the next run fetches it afresh.
+func (c Cache) Getter(next download.Getter) download.Getter {
+	return func(url string) (io.ReadCloser, error) {
+		if f, err := c.open(url); err == nil {
+			return f, nil
+		}
+		if c.Offline {
+			return nil, notCached(url)
+		}
+		body, err := next(url)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		sum, err := c.store(url, body)
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(c.object(sum))
+	}
+}
+
+// Fetcher wraps next for documents that can change at the same URL, such as
+// an install script on a branch: online they are fetched every time, and the
+// cached copy only stands in for them offline.
+func (c Cache) Fetcher(next verify.Fetcher) verify.Fetcher {
+	return func(url string) ([]byte, error) {
+		if c.Offline {
+			f, err := c.open(url)
+			if err != nil {
+				return nil, notCached(url)
+			}
+			defer f.Close()
+			return io.ReadAll(f)
+		}
+		data, err := next(url)
+		if err != nil {
+			return nil, err
+		}
+		// A copy that cannot be kept only costs the offline fallback.
+		_, _ = c.store(url, bytes.NewReader(data))
+		return data, nil
+	}
+}
+
+// Clone makes dir a shallow clone of the git repository at url, by way of a
+// mirror of it in the cache: fetched afresh online, used as it is offline.
+// The clone's origin is url, so it pulls from upstream as usual.
+func (c Cache) Clone(url, dir string, stdout, stderr io.Writer) error {
+	mirror := filepath.Join(c.Dir, "git", key(url)+".git")
+	_, err := os.Stat(mirror)
+	switch {
+	case err == nil && c.Offline:
+	case err == nil:
+		if err := git(stdout, stderr, "-C", mirror, "fetch", "--depth=1", "--prune"); err != nil {
+			return fmt.Errorf("updating the cached clone of %s: %w", url, err)
+		}
+	case c.Offline:
+		return notCached(url)
+	default:
+		if err := os.MkdirAll(filepath.Dir(mirror), 0o755); err != nil {
+			return err
+		}
+		tmp, err := os.MkdirTemp(filepath.Dir(mirror), ".clone-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		if err := git(stdout, stderr, "clone", "--mirror", "--depth=1", url, tmp); err != nil {
+			return fmt.Errorf("cloning %s: %w", url, err)
+		}
+		if err := os.Rename(tmp, mirror); err != nil {
+			return err
+		}
+	}
+	if err := git(stdout, stderr, "clone", "--depth=1", "file://"+mirror, dir); err != nil {
+		return fmt.Errorf("cloning %s from the cache: %w", url, err)
+	}
+	if err := git(stdout, stderr, "-C", dir, "remote", "set-url", "origin", url); err != nil {
+		return err
+	}
+	if c.Offline {
+		return nil
+	}
+	size, _ := dirSize(mirror)
+	return c.record(Entry{URL: url, Kind: KindGit, Size: size, Fetched: time.Now()})
+}
+
+// Evict drops url's entry, and its file unless another URL has the same
+// content. A URL that is not cached is not an error.
+func (c Cache) Evict(url string) error {
+	data, err := os.ReadFile(c.entry(url))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+	if err := os.Remove(c.entry(url)); err != nil {
+		return err
+	}
+	if e.Kind != KindFile {
+		return nil
+	}
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+	for _, other := range entries {
+		if other.Kind == KindFile && other.SHA256 == e.SHA256 {
+			return nil
+		}
+	}
+	if err := os.Remove(c.object(e.SHA256)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List returns every entry, by URL.
+func (c Cache) List() ([]Entry, error) {
+	files, err := filepath.Glob(filepath.Join(c.Dir, "urls", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+	return entries, nil
+}
+
+// Clean removes the whole cache, returning how many bytes it held.
+func (c Cache) Clean() (int64, error) {
+	size, err := dirSize(c.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return size, os.RemoveAll(c.Dir)
+}
+
+// open opens url's cached file.
+func (c Cache) open(url string) (*os.File, error) {
+	data, err := os.ReadFile(c.entry(url))
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	if e.Kind != KindFile {
+		return nil, fmt.Errorf("%s is cached as a %s", url, e.Kind)
+	}
+	return os.Open(c.object(e.SHA256))
+}
+
+// store saves body as url's content, returning its SHA-256.
+func (c Cache) store(url string, body io.Reader) (string, error) {
+	dir := filepath.Join(c.Dir, "objects")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".fetch-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), body)
+	if err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err := os.Rename(tmp.Name(), c.object(sum)); err != nil {
+		return "", err
+	}
+	return sum, c.record(Entry{URL: url, Kind: KindFile, SHA256: sum, Size: size, Fetched: time.Now()})
+}
+
+// record writes e, replacing url's previous entry in one rename.
+func (c Cache) record(e Entry) error {
+	file := c.entry(e.URL)
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".entry-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (c Cache) entry(url string) string {
+	return filepath.Join(c.Dir, "urls", key(url)+".json")
+}
+
+func (c Cache) object(sum string) string { return filepath.Join(c.Dir, "objects", sum) }
+
+func key(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+func notCached(url string) error {
+	return fmt.Errorf("%w: %s is not in the download cache", ErrOffline, url)
+}
+
+func git(stdout, stderr io.Writer, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// dirSize is the total size of the files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
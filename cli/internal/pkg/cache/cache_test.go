@@ -0,0 +1,196 @@
+package cache_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/cache"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		c       cache.Cache
+		served  map[string]string
+		fetched []string
+	)
+
+	BeforeEach(func() {
+		c = cache.Cache{Dir: GinkgoT().TempDir()}
+		served = map[string]string{
+			"https://example.com/v1/tool.tar.gz": "archive",
+			"https://example.com/v1/copy.tar.gz": "archive",
+			"https://example.com/install.sh":     "echo v1",
+		}
+		fetched = nil
+	})
+
+	get := func(url string) (io.ReadCloser, error) {
+		fetched = append(fetched, url)
+		if body, ok := served[url]; ok {
+			return io.NopCloser(bytes.NewReader([]byte(body))), nil
+		}
+		return nil, fmt.Errorf("GET %s: HTTP 404", url)
+	}
+	fetch := func(url string) ([]byte, error) {
+		body, err := get(url)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(body)
+	}
+	read := func(body io.ReadCloser, err error) string {
+		Expect(err).NotTo(HaveOccurred())
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	Describe("Getter", func() {
+		It("fetches a release file once and serves it from the cache after", func() {
+			getter := c.Getter(get)
+
+			Expect(read(getter("https://example.com/v1/tool.tar.gz"))).To(Equal("archive"))
+			Expect(read(getter("https://example.com/v1/tool.tar.gz"))).To(Equal("archive"))
+
+			Expect(fetched).To(HaveLen(1))
+		})
+
+		It("keeps identical content once, whatever its URL", func() {
+			getter := c.Getter(get)
+			read(getter("https://example.com/v1/tool.tar.gz"))
+			read(getter("https://example.com/v1/copy.tar.gz"))
+
+			objects, err := os.ReadDir(filepath.Join(c.Dir, "objects"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(HaveLen(1))
+		})
+
+		It("fails at once offline for anything not cached", func() {
+			c.Offline = true
+
+			_, err := c.Getter(get)("https://example.com/v1/tool.tar.gz")
+
+			Expect(err).To(MatchError(cache.ErrOffline))
+			Expect(err).To(MatchError(ContainSubstring("https://example.com/v1/tool.tar.gz is not in the download cache")))
+			Expect(fetched).To(BeEmpty())
+		})
+	})
+
+	Describe("Evict", func() {
+		It("drops a bad download so the next get fetches it afresh", func() {
+			getter := c.Getter(get)
+			read(getter("https://example.com/v1/tool.tar.gz"))
+
+			Expect(c.Evict("https://example.com/v1/tool.tar.gz")).To(Succeed())
+			served["https://example.com/v1/tool.tar.gz"] = "fixed"
+
+			Expect(read(getter("https://example.com/v1/tool.tar.gz"))).To(Equal("fixed"))
+			Expect(fetched).To(HaveLen(2))
+			Expect(c.Evict("https://example.com/never-cached")).To(Succeed())
+		})
+
+		It("keeps the content while another URL still has it", func() {
+			getter := c.Getter(get)
+			read(getter("https://example.com/v1/tool.tar.gz"))
+			read(getter("https://example.com/v1/copy.tar.gz"))
+
+			Expect(c.Evict("https://example.com/v1/tool.tar.gz")).To(Succeed())
+
+			Expect(read(getter("https://example.com/v1/copy.tar.gz"))).To(Equal("archive"))
+			Expect(fetched).To(HaveLen(2))
+			Expect(c.List()).To(ConsistOf(HaveField("URL", "https://example.com/v1/copy.tar.gz")))
+		})
+	})
+
+	Describe("Fetcher", func() {
+		It("fetches afresh online and stands in with the cached copy offline", func() {
+			Expect(c.Fetcher(fetch)("https://example.com/install.sh")).To(Equal([]byte("echo v1")))
+			served["https://example.com/install.sh"] = "echo v2"
+			Expect(c.Fetcher(fetch)("https://example.com/install.sh")).To(Equal([]byte("echo v2")))
+			Expect(fetched).To(HaveLen(2))
+
+			c.Offline = true
+			Expect(c.Fetcher(fetch)("https://example.com/install.sh")).To(Equal([]byte("echo v2")))
+			Expect(fetched).To(HaveLen(2))
+		})
+	})
+
+	It("lists what it holds and cleans it all away", func() {
+		read(c.Getter(get)("https://example.com/v1/tool.tar.gz"))
+		_, err := c.Fetcher(fetch)("https://example.com/install.sh")
+		Expect(err).NotTo(HaveOccurred())
+
+		entries, err := c.List()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].URL).To(Equal("https://example.com/install.sh"))
+		Expect(entries[0].Kind).To(Equal(cache.KindFile))
+		Expect(entries[0].Size).To(Equal(int64(len("echo v1"))))
+		Expect(entries[1].URL).To(Equal("https://example.com/v1/tool.tar.gz"))
+
+		freed, err := c.Clean()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(freed).To(BeNumerically(">", 0))
+		Expect(c.Dir).NotTo(BeAnExistingFile())
+		Expect(c.List()).To(BeEmpty())
+	})
+
+	Describe("Clone", func() {
+		var upstream string
+
+		git := func(dir string, args ...string) string {
+			cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+			cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.invalid",
+				"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.invalid")
+			out, err := cmd.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(out))
+			return string(out)
+		}
+
+		BeforeEach(func() {
+			if _, err := exec.LookPath("git"); err != nil {
+				Skip("git is not installed")
+			}
+			upstream = filepath.Join(GinkgoT().TempDir(), "theme")
+			Expect(os.MkdirAll(upstream, 0o755)).To(Succeed())
+			git(upstream, "init", "-q")
+			Expect(os.WriteFile(filepath.Join(upstream, "theme.zsh"), []byte("v1\n"), 0o644)).To(Succeed())
+			git(upstream, "add", ".")
+			git(upstream, "commit", "-q", "-m", "v1")
+		})
+
+		It("clones through a cached mirror, offline too, pointing origin upstream", func() {
+			url := "file://" + upstream
+			first := filepath.Join(GinkgoT().TempDir(), "first")
+			Expect(c.Clone(url, first, io.Discard, io.Discard)).To(Succeed())
+			Expect(os.ReadFile(filepath.Join(first, "theme.zsh"))).To(Equal([]byte("v1\n")))
+			Expect(git(first, "remote", "get-url", "origin")).To(Equal(url + "\n"))
+
+			c.Offline = true
+			second := filepath.Join(GinkgoT().TempDir(), "second")
+			Expect(os.RemoveAll(upstream)).To(Succeed())
+
+			Expect(c.Clone(url, second, io.Discard, io.Discard)).To(Succeed())
+			Expect(os.ReadFile(filepath.Join(second, "theme.zsh"))).To(Equal([]byte("v1\n")))
+			entries, err := c.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(ConsistOf(HaveField("Kind", cache.KindGit)))
+		})
+
+		It("fails at once offline for a repository it has no mirror of", func() {
+			c.Offline = true
+
+			err := c.Clone("file://"+upstream, filepath.Join(GinkgoT().TempDir(), "clone"), io.Discard, io.Discard)
+
+			Expect(err).To(MatchError(cache.ErrOffline))
+		})
+	})
+})
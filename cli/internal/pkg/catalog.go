@@ -0,0 +1,307 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CatalogFile is the tool manifest's name at the repo root.
+const CatalogFile = "tools.yaml"
+
+// Install strategies a catalog entry can use for an OS.
+const (
+	StrategyFormula  = "formula"
+	StrategyCask     = "cask"
+	StrategyTap      = "tap"
+	StrategyApt      = "apt"
+	StrategyDownload = "download"
+	StrategyScript   = "script"
+)
+
+// strategyOS lists the OSes each strategy can install on; "" means any.
+var strategyOS = map[string]string{
+	StrategyFormula:  "darwin",
+	StrategyCask:     "darwin",
+	StrategyTap:      "darwin",
+	StrategyApt:      "linux",
+	StrategyDownload: "",
+	StrategyScript:   "",
+}
+
+// catalogOSes are the install keys a tool may have.
+var catalogOSes = []string{"darwin", "linux"}
+
+// Catalog is the parsed tool manifest: what the CLI offers, in order, and how
+// each tool installs per OS.
+type Catalog struct {
+	Tools []Tool
+	// File is where the catalog was read from, for error messages.
+	File string
+}
+
+// Tool is one catalog entry.
+type Tool struct {
+	Name        string
+	Description string
+	Category    string
+	// Depends names the catalog tools that must be installed first.
+	Depends []string
+	// Install maps an OS to its strategies, in order of preference: the
+	// registry uses the first one that can install on the machine, falling
+	// back to the next when an install fails.
+	Install map[string][]Strategy
+	// Line is the entry's line in the manifest.
+	Line int
+}
+
+// Strategy says how to install a tool on one OS. Package is the package
+// manager's name for it (the tool's name when empty), Tap the brew tap for
+// StrategyTap, and Source the built-in download or script to run.
+type Strategy struct {
+	Kind    string `yaml:"strategy"`
+	Package string `yaml:"package"`
+	Tap     string `yaml:"tap"`
+	Source  string `yaml:"source"`
+}
+
+// packageOr returns s.Package, or name when unset.
+func (s Strategy) packageOr(name string) string {
+	if s.Package != "" {
+		return s.Package
+	}
+	return name
+}
+
+// LoadCatalog reads and validates the manifest at path.
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading tool catalog: %w", err)
+	}
+	return ParseCatalog(path, data)
+}
+
+// ParseCatalog parses and validates a manifest. file names it in errors,
+// which point at the offending entry as "<file>:<line>: tool "<name>": ...".
+// Every problem found is reported, not just the first.
+func ParseCatalog(file string, data []byte) (*Catalog, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: want a mapping with a tools list", file)
+	}
+	root := doc.Content[0]
+	if err := checkKeys(root, "tools"); err != nil {
+		return nil, fmt.Errorf("%s:%d: %w", file, root.Line, err)
+	}
+	tools := mapValue(root, "tools")
+	if tools == nil || tools.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%s:%d: tools must be a list", file, root.Line)
+	}
+
+	c := &Catalog{File: file}
+	var errs []error
+	seen := map[string]int{}
+	for i, n := range tools.Content {
+		t, line, err := parseTool(n)
+		if err != nil {
+			label := fmt.Sprintf("tool %d", i+1)
+			if t.Name != "" {
+				label = fmt.Sprintf("tool %q", t.Name)
+			}
+			errs = append(errs, fmt.Errorf("%s:%d: %s: %w", file, line, label, err))
+			continue
+		}
+		if line, dup := seen[t.Name]; dup {
+			errs = append(errs, fmt.Errorf("%s:%d: tool %q: already defined at line %d", file, n.Line, t.Name, line))
+			continue
+		}
+		seen[t.Name] = n.Line
+		c.Tools = append(c.Tools, t)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if err := c.checkDepends(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// checkDepends requires every dependency to be a catalog tool installable on
+// each OS its dependent is, and the dependencies to be acyclic.
+func (c *Catalog) checkDepends() error {
+	var errs []error
+	for _, t := range c.Tools {
+		for _, d := range t.Depends {
+			dep, ok := c.Find(d)
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s:%d: tool %q: depends on unknown tool %q", c.File, t.Line, t.Name, d))
+				continue
+			}
+			for _, goos := range catalogOSes {
+				if len(t.Install[goos]) > 0 {
+					if len(dep.Install[goos]) == 0 {
+						errs = append(errs, fmt.Errorf("%s:%d: tool %q: depends on %q, which does not install on %s", c.File, t.Line, t.Name, d, goos))
+					}
+				}
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	deps := make(map[string][]string, len(c.Tools))
+	names := make([]string, len(c.Tools))
+	for i, t := range c.Tools {
+		deps[t.Name], names[i] = t.Depends, t.Name
+	}
+	if cycle := findCycle(names, deps); cycle != nil {
+		t, _ := c.Find(cycle[0])
+		return fmt.Errorf("%s:%d: tool %q: dependency cycle %s", c.File, t.Line, t.Name, strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// parseTool decodes and validates one entry. On error it also returns the
+// line of the node at fault.
+func parseTool(n *yaml.Node) (Tool, int, error) {
+	t := Tool{Line: n.Line}
+	if n.Kind != yaml.MappingNode {
+		return t, n.Line, errors.New("want a mapping")
+	}
+	var raw struct {
+		Name        string   `yaml:"name"`
+		Description string   `yaml:"description"`
+		Category    string   `yaml:"category"`
+		Depends     []string `yaml:"depends"`
+	}
+	if name := mapValue(n, "name"); name != nil {
+		t.Name = name.Value // labels errors found before decoding succeeds
+	}
+	if err := checkKeys(n, "name", "description", "category", "depends", "install"); err != nil {
+		return t, n.Line, err
+	}
+	if err := n.Decode(&raw); err != nil {
+		return t, n.Line, err
+	}
+	t.Name, t.Description, t.Category, t.Depends = raw.Name, raw.Description, raw.Category, raw.Depends
+	if t.Name == "" {
+		return t, n.Line, errors.New("name is required")
+	}
+	install := mapValue(n, "install")
+	if install == nil || len(install.Content) == 0 {
+		return t, n.Line, fmt.Errorf("install needs a strategy for at least one of %s", strings.Join(catalogOSes, ", "))
+	}
+	if err := checkKeys(install, catalogOSes...); err != nil {
+		return t, install.Line, fmt.Errorf("install: %w", err)
+	}
+	t.Install = map[string][]Strategy{}
+	for _, goos := range catalogOSes {
+		node := mapValue(install, goos)
+		if node == nil {
+			continue
+		}
+		strategies, line, err := parseStrategies(node, goos)
+		if err != nil {
+			return t, line, err
+		}
+		t.Install[goos] = strategies
+	}
+	return t, 0, nil
+}
+
+// parseStrategies reads an OS's install value: one strategy, or a list of
+// them in order of preference. Errors are labelled "linux" or, within a
+// list, "linux[2]".
+func parseStrategies(node *yaml.Node, goos string) ([]Strategy, int, error) {
+	items, list := []*yaml.Node{node}, node.Kind == yaml.SequenceNode
+	if list {
+		items = node.Content
+		if len(items) == 0 {
+			return nil, node.Line, fmt.Errorf("%s: want at least one strategy", goos)
+		}
+	}
+	var out []Strategy
+	for i, item := range items {
+		label := goos
+		if list {
+			label = fmt.Sprintf("%s[%d]", goos, i+1)
+		}
+		if err := checkKeys(item, "strategy", "package", "tap", "source"); err != nil {
+			return nil, item.Line, fmt.Errorf("%s: %w", label, err)
+		}
+		var s Strategy
+		if err := item.Decode(&s); err != nil {
+			return nil, item.Line, fmt.Errorf("%s: %w", label, err)
+		}
+		if err := s.validate(goos); err != nil {
+			return nil, item.Line, fmt.Errorf("%s: %w", label, err)
+		}
+		out = append(out, s)
+	}
+	return out, 0, nil
+}
+
+func (s Strategy) validate(goos string) error {
+	only, known := strategyOS[s.Kind]
+	switch {
+	case s.Kind == "":
+		return errors.New("strategy is required")
+	case !known:
+		return fmt.Errorf("unknown strategy %q (want formula, cask, tap, apt, download or script)", s.Kind)
+	case only != "" && only != goos:
+		return fmt.Errorf("strategy %s only installs on %s", s.Kind, only)
+	case s.Kind == StrategyTap && s.Tap == "":
+		return errors.New("strategy tap needs tap")
+	case s.Kind != StrategyTap && s.Tap != "":
+		return fmt.Errorf("tap only applies to strategy tap")
+	case (s.Kind == StrategyDownload || s.Kind == StrategyScript) && s.Source == "":
+		return fmt.Errorf("strategy %s needs source", s.Kind)
+	case s.Kind != StrategyDownload && s.Kind != StrategyScript && s.Source != "":
+		return fmt.Errorf("source only applies to strategies download and script")
+	}
+	return nil
+}
+
+// checkKeys rejects mapping keys outside allowed, so a typo such as
+// "strategey" is an error rather than a silently ignored field.
+func checkKeys(n *yaml.Node, allowed ...string) error {
+	if n.Kind != yaml.MappingNode {
+		return errors.New("want a mapping")
+	}
+	for i := 0; i < len(n.Content); i += 2 {
+		key := n.Content[i].Value
+		if !slices.Contains(allowed, key) {
+			return fmt.Errorf("unknown field %q (want %s)", key, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+func mapValue(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// Find returns the tool called name.
+func (c *Catalog) Find(name string) (Tool, bool) {
+	for _, t := range c.Tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
@@ -0,0 +1,154 @@
+package pkg_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg"
+)
+
+// repoCatalog loads the tools.yaml shipped at the repo root.
+func repoCatalog() *pkg.Catalog {
+	c, err := pkg.LoadCatalog(filepath.Join("..", "..", "..", pkg.CatalogFile))
+	Expect(err).NotTo(HaveOccurred())
+	return c
+}
+
+var _ = Describe("ParseCatalog", func() {
+	parse := func(data string) error {
+		_, err := pkg.ParseCatalog("tools.yaml", []byte(data))
+		return err
+	}
+
+	It("loads the repo's catalog", func() {
+		c := repoCatalog()
+		jq, ok := c.Find("jq")
+		Expect(ok).To(BeTrue())
+		Expect(jq.Category).To(Equal("terminal"))
+		Expect(jq.Install["linux"]).To(Equal([]pkg.Strategy{{Kind: pkg.StrategyApt}}))
+	})
+
+	It("reads a list of strategies in order of preference", func() {
+		c, err := pkg.ParseCatalog("tools.yaml", []byte(`tools:
+  - name: rustup
+    install:
+      linux:
+        - {strategy: apt}
+        - {strategy: script, source: rustup}
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Tools[0].Install["linux"]).To(Equal([]pkg.Strategy{
+			{Kind: pkg.StrategyApt},
+			{Kind: pkg.StrategyScript, Source: "rustup"},
+		}))
+	})
+
+	It("points at the offending strategy within a list", func() {
+		err := parse(`tools:
+  - name: rustup
+    install:
+      linux:
+        - {strategy: apt}
+        - {strategy: script}
+`)
+		Expect(err).To(MatchError(`tools.yaml:6: tool "rustup": linux[2]: strategy script needs source`))
+	})
+
+	It("rejects an empty list of strategies", func() {
+		err := parse(`tools:
+  - name: rustup
+    install:
+      linux: []
+`)
+		Expect(err).To(MatchError(`tools.yaml:4: tool "rustup": linux: want at least one strategy`))
+	})
+
+	It("points at the strategy of the offending entry", func() {
+		err := parse(`tools:
+  - name: jq
+    install:
+      darwin: {strategy: formula}
+  - name: fd
+    install:
+      darwin: {strategy: formla}
+`)
+		Expect(err).To(MatchError(`tools.yaml:7: tool "fd": darwin: unknown strategy "formla" (want formula, cask, tap, apt, download or script)`))
+	})
+
+	It("reports every bad entry at once", func() {
+		err := parse(`tools:
+  - description: nameless
+    install:
+      linux: {strategy: apt}
+  - name: terraform
+    install:
+      darwin: {strategy: tap}
+  - name: jq
+    instal:
+      linux: {strategy: apt}
+`)
+		Expect(err).To(MatchError(ContainSubstring(`tools.yaml:2: tool 1: name is required`)))
+		Expect(err).To(MatchError(ContainSubstring(`tools.yaml:7: tool "terraform": darwin: strategy tap needs tap`)))
+		Expect(err).To(MatchError(ContainSubstring(`tools.yaml:8: tool "jq": unknown field "instal"`)))
+	})
+
+	It("rejects a strategy on an OS it cannot install on", func() {
+		err := parse(`tools:
+  - name: jq
+    install:
+      darwin: {strategy: apt}
+`)
+		Expect(err).To(MatchError(`tools.yaml:4: tool "jq": darwin: strategy apt only installs on linux`))
+	})
+
+	It("rejects duplicate names", func() {
+		err := parse(`tools:
+  - name: jq
+    install: {linux: {strategy: apt}}
+  - name: jq
+    install: {darwin: {strategy: formula}}
+`)
+		Expect(err).To(MatchError(`tools.yaml:4: tool "jq": already defined at line 2`))
+	})
+})
+
+var _ = Describe("ParseCatalog dependencies", func() {
+	parse := func(data string) error {
+		_, err := pkg.ParseCatalog("tools.yaml", []byte(data))
+		return err
+	}
+
+	It("rejects a dependency on an unknown tool", func() {
+		err := parse(`tools:
+  - name: yarn
+    depends: [nodejs]
+    install: {darwin: {strategy: formula}}
+`)
+		Expect(err).To(MatchError(`tools.yaml:2: tool "yarn": depends on unknown tool "nodejs"`))
+	})
+
+	It("rejects a dependency missing on an OS its dependent installs on", func() {
+		err := parse(`tools:
+  - name: node
+    install: {darwin: {strategy: formula}}
+  - name: yarn
+    depends: [node]
+    install: {darwin: {strategy: formula}, linux: {strategy: apt}}
+`)
+		Expect(err).To(MatchError(`tools.yaml:4: tool "yarn": depends on "node", which does not install on linux`))
+	})
+
+	It("rejects a dependency cycle", func() {
+		err := parse(`tools:
+  - name: a
+    depends: [b]
+    install: {linux: {strategy: apt}}
+  - name: b
+    depends: [a]
+    install: {linux: {strategy: apt}}
+`)
+		Expect(err).To(MatchError(`tools.yaml:2: tool "a": dependency cycle a -> b -> a`))
+	})
+})
@@ -0,0 +1,136 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithDependencies returns names plus every tool they transitively depend on
+// per deps, in names order with each dependency added after its first
+// dependent. The second result maps each added name to that dependent.
+func WithDependencies(deps map[string][]string, names []string) ([]string, map[string]string) {
+	out := append([]string(nil), names...)
+	seen := stringSet(names)
+	addedFor := map[string]string{}
+	for i := 0; i < len(out); i++ {
+		for _, d := range deps[out[i]] {
+			if !seen[d] {
+				seen[d] = true
+				addedFor[d] = out[i]
+				out = append(out, d)
+			}
+		}
+	}
+	return out, addedFor
+}
+
+// Order returns the installables named in names, dependencies before their
+// dependents and otherwise in available order. Dependencies outside names
+// are taken as already satisfied. A cycle is an error naming it.
+func Order(available []Installable, deps map[string][]string, names []string) ([]Installable, error) {
+	want := stringSet(names)
+	byName := map[string]Installable{}
+	var order []string
+	for _, t := range available {
+		if want[t.Name()] {
+			byName[t.Name()] = t
+			order = append(order, t.Name())
+		}
+	}
+	within := func(name string) []string {
+		var out []string
+		for _, d := range deps[name] {
+			if want[d] {
+				out = append(out, d)
+			}
+		}
+		return out
+	}
+	restricted := make(map[string][]string, len(order))
+	for _, n := range order {
+		restricted[n] = within(n)
+	}
+	if cycle := findCycle(order, restricted); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle %s", strings.Join(cycle, " -> "))
+	}
+
+	out := make([]Installable, 0, len(order))
+	done := map[string]bool{}
+	var visit func(n string)
+	visit = func(n string) {
+		if done[n] {
+			return
+		}
+		done[n] = true
+		for _, d := range restricted[n] {
+			visit(d)
+		}
+		out = append(out, byName[n])
+	}
+	for _, n := range order {
+		visit(n)
+	}
+	return out, nil
+}
+
+// findCycle returns a dependency cycle among names as a path that starts and
+// ends with the same name, or nil when there is none.
+func findCycle(names []string, deps map[string][]string) []string {
+	const (
+		unvisited = iota
+		active
+		finished
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(n string) []string
+	visit = func(n string) []string {
+		switch state[n] {
+		case active:
+			for i, p := range path {
+				if p == n {
+					return append(append([]string(nil), path[i:]...), n)
+				}
+			}
+		case finished:
+			return nil
+		}
+		state[n] = active
+		path = append(path, n)
+		for _, d := range deps[n] {
+			if cycle := visit(d); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = finished
+		return nil
+	}
+	for _, n := range names {
+		if cycle := visit(n); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+func stringSet(s []string) map[string]bool {
+	set := make(map[string]bool, len(s))
+	for _, v := range s {
+		set[v] = true
+	}
+	return set
+}
+
+// FailedDependency returns the failed install that blocks a tool depending
+// on deps, or "" when none does. failed maps every tool that failed or was
+// skipped to the failed install at the root of it, so a chain of skips names
+// the original failure.
+func FailedDependency(deps []string, failed map[string]string) string {
+	for _, d := range deps {
+		if cause, ok := failed[d]; ok {
+			return cause
+		}
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package pkg_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg"
+)
+
+var _ = Describe("dependency ordering", func() {
+	tools := func(names ...string) []pkg.Installable {
+		out := make([]pkg.Installable, len(names))
+		for i, n := range names {
+			out[i] = fakeInstallable{name: n}
+		}
+		return out
+	}
+	names := func(ts []pkg.Installable) []string {
+		out := make([]string, len(ts))
+		for i, t := range ts {
+			out[i] = t.Name()
+		}
+		return out
+	}
+
+	It("adds transitive dependencies, recording who needed them", func() {
+		deps := map[string][]string{"plugin": {"yarn"}, "yarn": {"node"}}
+
+		got, addedFor := pkg.WithDependencies(deps, []string{"plugin", "jq"})
+
+		Expect(got).To(Equal([]string{"plugin", "jq", "yarn", "node"}))
+		Expect(addedFor).To(Equal(map[string]string{"yarn": "plugin", "node": "yarn"}))
+	})
+
+	It("puts dependencies first and keeps declaration order otherwise", func() {
+		deps := map[string][]string{"yarn": {"node"}, "golangci-lint": {"go"}}
+
+		ordered, err := pkg.Order(tools("yarn", "jq", "golangci-lint", "go", "node"), deps,
+			[]string{"node", "go", "golangci-lint", "jq", "yarn"})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(ordered)).To(Equal([]string{"node", "yarn", "jq", "go", "golangci-lint"}))
+	})
+
+	It("treats dependencies outside the set as satisfied", func() {
+		ordered, err := pkg.Order(tools("node", "yarn"), map[string][]string{"yarn": {"node"}}, []string{"yarn"})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(ordered)).To(Equal([]string{"yarn"}))
+	})
+
+	It("reports a cycle", func() {
+		deps := map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}}
+
+		_, err := pkg.Order(tools("a", "b", "c"), deps, []string{"a", "b", "c"})
+
+		Expect(err).To(MatchError("dependency cycle a -> b -> c -> a"))
+	})
+
+	It("traces a skipped tool back to the failure behind it", func() {
+		failed := map[string]string{"node": "node", "yarn": "node"}
+
+		Expect(pkg.FailedDependency([]string{"jq", "yarn"}, failed)).To(Equal("node"))
+		Expect(pkg.FailedDependency([]string{"jq"}, failed)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,85 @@
+// Package distro identifies the running Linux distribution from os-release,
+// so the registry can install through the package manager it actually has.
+package distro
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// OSRelease is where a Linux distribution describes itself.
+const OSRelease = "/etc/os-release"
+
+// Families of distributions, each installing through one package manager.
+const (
+	Debian = "debian" // apt
+	Fedora = "fedora" // dnf
+	Arch   = "arch"   // pacman
+)
+
+// families maps os-release IDs to their family.
+var families = map[string]string{
+	"debian":      Debian,
+	"ubuntu":      Debian,
+	"fedora":      Fedora,
+	"rhel":        Fedora,
+	"centos":      Fedora,
+	"arch":        Arch,
+	"archlinux":   Arch,
+	"manjaro":     Arch,
+	"endeavouros": Arch,
+}
+
+// Distro is what os-release says about the distribution: its ID, the IDs it
+// derives from (ID_LIKE), and its human-readable name.
+type Distro struct {
+	ID   string
+	Like []string
+	Name string
+}
+
+// Read parses the os-release file at path.
+func Read(path string) (Distro, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Distro{}, err
+	}
+	return Parse(string(data)), nil
+}
+
+// Parse reads os-release's KEY=value lines, unquoting values.
+func Parse(data string) Distro {
+	var d Distro
+	for _, line := range strings.Split(data, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			d.ID = value
+		case "ID_LIKE":
+			d.Like = strings.Fields(value)
+		case "PRETTY_NAME":
+			d.Name = value
+		}
+	}
+	if d.Name == "" {
+		d.Name = d.ID
+	}
+	return d
+}
+
+// Family is the family of d's ID or, failing that, of the first ID_LIKE
+// entry that has one (Linux Mint is like ubuntu, Rocky like rhel). It is ""
+// for a distribution this CLI cannot install packages on.
+func (d Distro) Family() string {
+	for _, id := range slices.Concat([]string{d.ID}, d.Like) {
+		if f, ok := families[id]; ok {
+			return f
+		}
+	}
+	return ""
+}
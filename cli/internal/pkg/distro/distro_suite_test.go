@@ -0,0 +1,13 @@
+package distro_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDistroSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "distro Suite")
+}
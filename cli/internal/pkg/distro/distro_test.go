@@ -0,0 +1,57 @@
+package distro_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/distro"
+)
+
+var _ = Describe("Parse", func() {
+	It("reads the ID, ID_LIKE and pretty name, unquoting them", func() {
+		d := distro.Parse(`NAME="Linux Mint"
+# a comment
+ID=linuxmint
+ID_LIKE="ubuntu debian"
+PRETTY_NAME="Linux Mint 22"
+`)
+
+		Expect(d).To(Equal(distro.Distro{ID: "linuxmint", Like: []string{"ubuntu", "debian"}, Name: "Linux Mint 22"}))
+	})
+
+	It("falls back to the ID for the name", func() {
+		Expect(distro.Parse("ID=arch\n").Name).To(Equal("arch"))
+	})
+})
+
+var _ = Describe("Distro.Family", func() {
+	DescribeTable("classifies by ID, then by ID_LIKE",
+		func(id string, like []string, want string) {
+			Expect(distro.Distro{ID: id, Like: like}.Family()).To(Equal(want))
+		},
+		Entry("Ubuntu", "ubuntu", nil, distro.Debian),
+		Entry("Pop!_OS", "pop", []string{"ubuntu", "debian"}, distro.Debian),
+		Entry("Fedora", "fedora", nil, distro.Fedora),
+		Entry("Rocky", "rocky", []string{"rhel", "centos", "fedora"}, distro.Fedora),
+		Entry("Arch", "arch", nil, distro.Arch),
+		Entry("Manjaro", "manjaro", []string{"arch"}, distro.Arch),
+		Entry("NixOS", "nixos", nil, ""),
+		Entry("openSUSE", "opensuse-tumbleweed", []string{"opensuse", "suse"}, ""),
+	)
+})
+
+var _ = Describe("Read", func() {
+	It("parses the file at path", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "os-release")
+		Expect(os.WriteFile(path, []byte("ID=fedora\nPRETTY_NAME=\"Fedora Linux 40\"\n"), 0o644)).To(Succeed())
+
+		d, err := distro.Read(path)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(d.Family()).To(Equal(distro.Fedora))
+		Expect(d.Name).To(Equal("Fedora Linux 40"))
+	})
+})
@@ -0,0 +1,132 @@
+// Package dnf provides the Installable kind for Fedora and its relatives,
+// installing packages with dnf.
+package dnf
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Runner runs a dnf subcommand. Production wiring shells out to
+// `sudo dnf …`; tests inject a recorder.
+type Runner func(args []string, stdout, stderr io.Writer) error
+
+// DefaultRunner returns the production Runner. --assumeyes is passed on
+// every run so no transaction stops to ask.
+func DefaultRunner() Runner {
+	return func(args []string, stdout, stderr io.Writer) error {
+		cmd := exec.Command("sudo", append([]string{"dnf", "--assumeyes"}, args...)...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		return cmd.Run()
+	}
+}
+
+// DefaultQueryRunner returns the production Runner for read-only package
+// queries: it runs `rpm` directly, without sudo.
+func DefaultQueryRunner() Runner {
+	return func(args []string, stdout, stderr io.Writer) error {
+		cmd := exec.Command("rpm", args...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		return cmd.Run()
+	}
+}
+
+// Names maps catalog tool names to Fedora package names. A tool missing here
+// has no dnf package this CLI knows of and is not offered on Fedora.
+var Names = map[string]string{
+	"byobu":         "byobu",
+	"fzf":           "fzf",
+	"ripgrep":       "ripgrep",
+	"bat":           "bat",
+	"eza":           "eza",
+	"jq":            "jq",
+	"gh":            "gh",
+	"go":            "golang",
+	"node":          "nodejs",
+	"python":        "python3",
+	"yarn":          "yarnpkg",
+	"rustup":        "rustup",
+	"ruby":          "ruby",
+	"ansible":       "ansible",
+	"golangci-lint": "golangci-lint",
+	"curl":          "curl",
+	"gpg":           "gnupg2",
+}
+
+// Package is a dnf package. Install runs `dnf install`; Detect asks rpm.
+type Package struct {
+	name    string
+	version string
+	run     Runner
+	query   Runner
+}
+
+// NewPackage returns the package called name (a Fedora name, see Names)
+// bound to an install runner and a query runner.
+func NewPackage(name string, run, query Runner) Package {
+	return Package{name: name, run: run, query: query}
+}
+
+// At returns p pinned to version, installed as `dnf install <name>-<version>`.
+func (p Package) At(version string) Package {
+	p.version = version
+	return p
+}
+
+// Name returns the Fedora package name.
+func (p Package) Name() string { return p.name }
+
+// Install runs `dnf install <name>[-<version>]`.
+func (p Package) Install(stdout, stderr io.Writer) error {
+	return p.run(p.args(), stdout, stderr)
+}
+
+// Plan returns the dnf command Install runs.
+func (p Package) Plan() []string { return []string{command(p.args())} }
+
+// Detect asks rpm for the installed version-release. rpm exits non-zero for
+// a package that is not installed.
+func (p Package) Detect() (bool, string, error) {
+	var out bytes.Buffer
+	if err := p.query([]string{"-q", "--qf", "%{VERSION}-%{RELEASE}", p.name}, &out, io.Discard); err != nil {
+		return false, "", nil
+	}
+	return true, strings.TrimSpace(out.String()), nil
+}
+
+// Version is the pinned version, or empty when whatever Fedora ships will do.
+func (p Package) Version() string { return p.version }
+
+// Lock serializes dnf transactions: rpm's database takes one at a time.
+func (Package) Lock() string { return "rpm" }
+
+// BatchKey lets packages share one `dnf install a b c`.
+func (Package) BatchKey() string { return "dnf" }
+
+// BatchArg is the package's argument to dnf install.
+func (p Package) BatchArg() string {
+	if p.version != "" {
+		return p.name + "-" + p.version
+	}
+	return p.name
+}
+
+// InstallBatch runs `dnf install <args...>`.
+func (p Package) InstallBatch(args []string, stdout, stderr io.Writer) error {
+	return p.run(append([]string{"install"}, args...), stdout, stderr)
+}
+
+// BatchPlan returns the dnf command InstallBatch runs.
+func (Package) BatchPlan(args []string) []string {
+	return []string{command(append([]string{"install"}, args...))}
+}
+
+func (p Package) args() []string { return []string{"install", p.BatchArg()} }
+
+func command(args []string) string {
+	return "sudo dnf --assumeyes " + strings.Join(args, " ")
+}
@@ -0,0 +1,13 @@
+package dnf_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDnfSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "dnf Suite")
+}
@@ -0,0 +1,67 @@
+package dnf_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/dnf"
+)
+
+var _ = Describe("Package", func() {
+	It("installs with dnf install, pinned as <name>-<version>", func() {
+		var gotArgs []string
+		spy := func(args []string, _, _ io.Writer) error {
+			gotArgs = args
+			return nil
+		}
+
+		Expect(dnf.NewPackage("golang", spy, nil).At("1.22.5").Install(&bytes.Buffer{}, &bytes.Buffer{})).To(Succeed())
+
+		Expect(gotArgs).To(Equal([]string{"install", "golang-1.22.5"}))
+	})
+
+	It("plans the exact command", func() {
+		Expect(dnf.NewPackage("jq", nil, nil).Plan()).To(Equal([]string{"sudo dnf --assumeyes install jq"}))
+	})
+
+	It("batches packages into one dnf install", func() {
+		var gotArgs []string
+		spy := func(args []string, _, _ io.Writer) error {
+			gotArgs = args
+			return nil
+		}
+
+		Expect(dnf.NewPackage("jq", spy, nil).InstallBatch([]string{"jq", "gh"}, io.Discard, io.Discard)).To(Succeed())
+
+		Expect(gotArgs).To(Equal([]string{"install", "jq", "gh"}))
+	})
+
+	Describe("Detect", func() {
+		query := func(out string, err error) dnf.Runner {
+			return func(args []string, stdout, _ io.Writer) error {
+				Expect(args).To(Equal([]string{"-q", "--qf", "%{VERSION}-%{RELEASE}", "jq"}))
+				_, _ = io.WriteString(stdout, out)
+				return err
+			}
+		}
+
+		It("reports rpm's version-release of an installed package", func() {
+			installed, version, err := dnf.NewPackage("jq", nil, query("1.7.1-8.fc40", nil)).Detect()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installed).To(BeTrue())
+			Expect(version).To(Equal("1.7.1-8.fc40"))
+		})
+
+		It("treats rpm's non-zero exit as not installed", func() {
+			installed, _, err := dnf.NewPackage("jq", nil, query("package jq is not installed", errors.New("exit status 1"))).Detect()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installed).To(BeFalse())
+		})
+	})
+})
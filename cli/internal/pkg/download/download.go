@@ -0,0 +1,530 @@
+// Package download installs tools from upstream release archives: a URL
+// built from the release's version and the machine's OS and architecture,
+// checked against a SHA-256 before anything is unpacked, with the tool's
+// binary taken out of the archive and moved into place in one rename.
+package download
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/verify"
+)
+
+// Getter opens the document at url.
+type Getter func(url string) (io.ReadCloser, error)
+
+// DefaultGetter returns the production Getter, which fetches over HTTP and
+// treats any status but 200 as an error.
+func DefaultGetter() Getter {
+	client := &http.Client{Timeout: 10 * time.Minute}
+	return func(url string) (io.ReadCloser, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: HTTP %d", url, resp.StatusCode)
+		}
+		return resp.Body, nil
+	}
+}
+
+// Release is a tool installed from an upstream release download, checked
+// against Checksums or the release's checksum file at Sums.
+type Release struct {
+	Tool string
+	// Repo is the project's GitHub "owner/name", whose releases a version
+	// spec such as "latest" is resolved against (internal/pkg/release).
+	Repo string
+	// Default is the release installed unless pinned with At.
+	Default string
+	// URL, Sums and Binary are templates for {version}, {os} and {arch},
+	// spelled as the project names its assets through OS and Arch.
+	URL       string
+	Sums      string
+	Checksums map[string]map[string]string // version → asset → SHA-256
+	Signature string                       // of the checksum file, checked by Verifier
+	Verifier  verify.Verifier
+	// SkipVerify installs without checking Signature, warning that it did
+	// (--insecure-skip-verify).
+	SkipVerify bool
+	// Binary is the binary's path inside the archive; empty when the
+	// download is the binary itself.
+	Binary string
+	// Bin is the installed file's name, in Dir (~/.local/bin when empty).
+	Bin  string
+	Dir  string
+	OS   map[string]string
+	Arch map[string]string
+	// VersionArgs make Bin print its version; --version when empty.
+	VersionArgs []string
+	Get         Getter
+	Evict       func(url string) error // drops a download that fails its check
+
+	version      string
+	goos, goarch string
+}
+
+// At returns r pinned to version, e.g. "0.10.4" or the tag "v0.10.4". An
+// empty version means Default.
+func (r Release) At(version string) Release {
+	r.version = strings.TrimPrefix(version, "v")
+	return r
+}
+
+// For returns r for another platform, e.g. to plan a download for a
+// different machine.
+func (r Release) For(goos, goarch string) Release {
+	r.goos, r.goarch = goos, goarch
+	return r
+}
+
+// Name is the tool's catalog name.
+func (r Release) Name() string { return r.Tool }
+
+// Version is the pinned release, or Default.
+func (r Release) Version() string {
+	if r.version == "" {
+		return r.Default
+	}
+	return r.version
+}
+
+// Plan names the download, how it is checked, and where the binary goes.
+func (r Release) Plan() []string {
+	url := r.url()
+	plan := []string{"download " + url}
+	if sum := r.Checksums[r.Version()][path.Base(url)]; sum != "" {
+		plan = append(plan, "check its SHA-256 is "+sum)
+	} else if r.Sums != "" {
+		plan = append(plan, "check its SHA-256 against "+r.expand(r.Sums))
+		if r.Signature != "" && !r.SkipVerify {
+			plan = append(plan, "check that file's signature "+r.expand(r.Signature))
+		} else if r.Signature != "" {
+			plan = append(plan, "do NOT check that file's signature (--insecure-skip-verify)")
+		}
+	} else {
+		plan = append(plan, "refuse it: no checksum to check it against")
+	}
+	if r.Binary != "" {
+		plan = append(plan, "extract "+r.expand(r.Binary))
+	}
+	return append(plan, fmt.Sprintf("install it to %s (mode 0755)", r.dest()))
+}
+
+// Detect runs the installed binary for its version. A missing file is "not
+// installed"; one that does not run (e.g. an AppImage without FUSE) is an
+// error.
+func (r Release) Detect() (bool, string, error) {
+	dest := r.dest()
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		return false, "", nil
+	}
+	args := r.VersionArgs
+	if len(args) == 0 {
+		args = []string{"--version"}
+	}
+	out, err := exec.Command(dest, args...).Output()
+	if err != nil {
+		return false, "", fmt.Errorf("running %s %s: %w", dest, strings.Join(args, " "), err)
+	}
+	return true, versionPattern.FindString(string(out)), nil
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// Install downloads the release, checks it, and moves the binary into place.
+// Nothing is written to Dir unless the checksum matches.
+func (r Release) Install(stdout, stderr io.Writer) error {
+	url, dest := r.url(), r.dest()
+	fmt.Fprintf(stdout, "Downloading %s %s to %s...\n", r.Tool, r.Version(), dest)
+
+	tmp, err := os.MkdirTemp("", "machine-setup-"+r.Tool+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	archive := filepath.Join(tmp, path.Base(url))
+	got, err := r.fetch(url, archive)
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", r.Tool, err)
+	}
+	want, err := r.checksum(url, stderr)
+	if err != nil {
+		r.evict(stderr, r.expand(r.Sums), r.expand(r.Signature))
+		return fmt.Errorf("%s: %w", r.Tool, err)
+	}
+	if !strings.EqualFold(got, want) {
+		// Whichever of the download and the checksum file is bad, neither
+		// is served again.
+		r.evict(stderr, url, r.expand(r.Sums), r.expand(r.Signature))
+		return fmt.Errorf("%s: %s has SHA-256 %s, want %s", r.Tool, path.Base(url), got, want)
+	}
+
+	bin, err := r.open(archive)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.Tool, err)
+	}
+	defer bin.Close()
+	return place(bin, dest)
+}
+
+// fetch saves url to file, returning its SHA-256.
+func (r Release) fetch(url, file string) (string, error) {
+	body, err := r.getter()(url)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	out, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), out.Close()
+}
+
+// checksum returns the SHA-256 the asset at url must have: the pinned one,
+// or the release's checksum file's entry for it, once the file's signature
+// checks out.
+func (r Release) checksum(url string, stderr io.Writer) (string, error) {
+	asset := path.Base(url)
+	if sum := r.Checksums[r.Version()][asset]; sum != "" {
+		return sum, nil
+	}
+	if r.Sums == "" {
+		return "", fmt.Errorf("no checksum for %s: pin one or name the release's checksum file", asset)
+	}
+	sums := r.expand(r.Sums)
+	body, err := r.getter()(sums)
+	if err != nil {
+		return "", fmt.Errorf("fetching checksums: %w", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("fetching checksums: %w", err)
+	}
+	if err := r.verify(sums, data, stderr); err != nil {
+		return "", err
+	}
+	if sum := findSum(data, asset); sum != "" {
+		return sum, nil
+	}
+	return "", fmt.Errorf("%s lists no checksum for %s", sums, asset)
+}
+
+// verify checks Signature over the checksum file at sums, whose content is
+// data.
+func (r Release) verify(sums string, data []byte, stderr io.Writer) error {
+	if r.Signature == "" {
+		return nil
+	}
+	if r.SkipVerify {
+		verify.Skipped(stderr, sums)
+		return nil
+	}
+	if r.Verifier == nil {
+		return fmt.Errorf("no key to check the signature of %s", sums)
+	}
+	sig := r.expand(r.Signature)
+	body, err := r.getter()(sig)
+	if err != nil {
+		return fmt.Errorf("fetching signature: %w", err)
+	}
+	defer body.Close()
+	signature, err := io.ReadAll(io.LimitReader(body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("fetching signature: %w", err)
+	}
+	if err := r.Verifier.Verify(data, signature); err != nil {
+		return fmt.Errorf("%s does not match its signature %s: %w", sums, sig, err)
+	}
+	return nil
+}
+
+// findSum looks asset up in a sha256sum-style file: "<hex>  <name>" lines,
+// the name possibly marked binary with "*" or prefixed with a directory.
+func findSum(data []byte, asset string) string {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			continue
+		}
+		if path.Base(strings.TrimPrefix(fields[1], "*")) == asset {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// open returns the binary in the downloaded file: the file itself, or its
+// Binary entry when it is a .tar.gz, .tgz or .zip archive.
+func (r Release) open(file string) (io.ReadCloser, error) {
+	if r.Binary == "" {
+		return os.Open(file)
+	}
+	want := path.Clean(r.expand(r.Binary))
+	switch name := filepath.Base(file); {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return fromTar(file, want)
+	case strings.HasSuffix(name, ".zip"):
+		return fromZip(file, want)
+	default:
+		return nil, fmt.Errorf("%s is not a .tar.gz or .zip archive", name)
+	}
+}
+
+func fromTar(file, want string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading %s: %w", filepath.Base(file), err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("reading %s: %w", filepath.Base(file), err)
+		}
+		if h.Typeflag == tar.TypeReg && path.Clean(h.Name) == want {
+			return struct {
+				io.Reader
+				io.Closer
+			}{tr, f}, nil
+		}
+	}
+	f.Close()
+	return nil, fmt.Errorf("%s has no %s", filepath.Base(file), want)
+}
+
+func fromZip(file, want string) (io.ReadCloser, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filepath.Base(file), err)
+	}
+	for _, zf := range zr.File {
+		if zf.Mode().IsRegular() && path.Clean(zf.Name) == want {
+			rc, err := zf.Open()
+			if err != nil {
+				zr.Close()
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{rc, closers{rc, zr}}, nil
+		}
+	}
+	zr.Close()
+	return nil, fmt.Errorf("%s has no %s", filepath.Base(file), want)
+}
+
+type closers []io.Closer
+
+func (cs closers) Close() error {
+	var errs []error
+	for _, c := range cs {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// place writes bin to a temporary file beside dest and renames it over dest,
+// so dest is never a partial binary.
+func place(bin io.Reader, dest string) error {
+	dir := filepath.Dir(dest)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(dest)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	if _, err := io.Copy(tmp, bin); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", dest, err)
+	}
+	if err := tmp.Chmod(0o755); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// evict passes the urls fetched for a download that failed its check to
+// Evict. Failing to forget one is only warned about: the install has failed
+// already.
+func (r Release) evict(stderr io.Writer, urls ...string) {
+	if r.Evict == nil {
+		return
+	}
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		if err := r.Evict(url); err != nil {
+			fmt.Fprintf(stderr, "warning: %s: could not forget the cached %s: %v\n", r.Tool, url, err)
+		}
+	}
+}
+
+func (r Release) getter() Getter {
+	if r.Get == nil {
+		return DefaultGetter()
+	}
+	return r.Get
+}
+
+func (r Release) url() string { return r.expand(r.URL) }
+
+// dest is where the binary is installed.
+func (r Release) dest() string {
+	dir := r.Dir
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".local", "bin")
+	}
+	return filepath.Join(dir, r.Bin)
+}
+
+func (r Release) expand(tmpl string) string {
+	goos, goarch := r.goos, r.goarch
+	if goos == "" {
+		goos, goarch = runtime.GOOS, runtime.GOARCH
+	}
+	if name, ok := r.OS[goos]; ok {
+		goos = name
+	}
+	if name, ok := r.Arch[goarch]; ok {
+		goarch = name
+	}
+	return strings.NewReplacer("{version}", r.Version(), "{os}", goos, "{arch}", goarch).Replace(tmpl)
+}
+
+// checksums pins the SHA-256 of built-in releases' assets, one
+// "<sha256>  <source>/<version>/<asset>" line each (see the file's header).
+//
+//go:embed checksums.sha256
+var checksums []byte
+
+// pinned returns the checksums pinned for the built-in release source, by
+// version and asset file name.
+func pinned(source string) map[string]map[string]string {
+	pins := map[string]map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(checksums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			continue
+		}
+		parts := strings.SplitN(fields[1], "/", 3)
+		if len(parts) != 3 || parts[0] != source {
+			continue
+		}
+		if pins[parts[1]] == nil {
+			pins[parts[1]] = map[string]string{}
+		}
+		pins[parts[1]][parts[2]] = fields[0]
+	}
+	return pins
+}
+
+// BuiltIn returns the releases the catalog's download strategy can name as
+// source, keyed by that name, with the checksums pinned for them in
+// checksums.sha256.
+func BuiltIn() map[string]Release {
+	releases := builtIn()
+	for source, r := range releases {
+		r.Checksums = pinned(source)
+		releases[source] = r
+	}
+	return releases
+}
+
+func builtIn() map[string]Release {
+	return map[string]Release{
+		"neovim-appimage": {
+			Tool:    "neovim",
+			Repo:    "neovim/neovim",
+			Default: DefaultNeovimVersion,
+			URL:     "https://github.com/neovim/neovim/releases/download/v{version}/nvim-linux-{arch}.appimage",
+			Sums:    "https://github.com/neovim/neovim/releases/download/v{version}/shasum.txt",
+			Bin:     "nvim",
+			Arch:    map[string]string{"amd64": "x86_64", "arm64": "aarch64"},
+		},
+		"lazygit": {
+			Tool:    "lazygit",
+			Repo:    "jesseduffield/lazygit",
+			Default: "0.44.1",
+			URL:     "https://github.com/jesseduffield/lazygit/releases/download/v{version}/lazygit_{version}_{os}_{arch}.tar.gz",
+			Sums:    "https://github.com/jesseduffield/lazygit/releases/download/v{version}/checksums.txt",
+			Binary:  "lazygit",
+			Bin:     "lazygit",
+			OS:      map[string]string{"linux": "Linux", "darwin": "Darwin"},
+			Arch:    map[string]string{"amd64": "x86_64"},
+		},
+		"k9s": {
+			Tool:        "k9s",
+			Repo:        "derailed/k9s",
+			Default:     "0.32.7",
+			URL:         "https://github.com/derailed/k9s/releases/download/v{version}/k9s_{os}_{arch}.tar.gz",
+			Sums:        "https://github.com/derailed/k9s/releases/download/v{version}/checksums.sha256",
+			Binary:      "k9s",
+			Bin:         "k9s",
+			OS:          map[string]string{"linux": "Linux", "darwin": "Darwin"},
+			VersionArgs: []string{"version", "--short"},
+		},
+		"terraform": {
+			Tool:      "terraform",
+			Repo:      "hashicorp/terraform",
+			Default:   "1.9.8",
+			URL:       "https://releases.hashicorp.com/terraform/{version}/terraform_{version}_{os}_{arch}.zip",
+			Sums:      "https://releases.hashicorp.com/terraform/{version}/terraform_{version}_SHA256SUMS",
+			Signature: "https://releases.hashicorp.com/terraform/{version}/terraform_{version}_SHA256SUMS.sig",
+			Verifier:  verify.HashiCorp,
+			Binary:    "terraform",
+			Bin:       "terraform",
+		},
+	}
+}
+
+// DefaultNeovimVersion is the Neovim release the neovim-appimage download
+// installs unless pinned to another.
+const DefaultNeovimVersion = "0.11.6"
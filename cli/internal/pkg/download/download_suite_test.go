@@ -0,0 +1,13 @@
+package download_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDownloadSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "download Suite")
+}
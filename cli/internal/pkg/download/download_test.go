@@ -0,0 +1,279 @@
+package download_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/download"
+)
+
+const binary = "#!/bin/sh\necho 'lazygit version=0.44.1'\n"
+
+func tarGz(files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg})).To(Succeed())
+		_, err := tw.Write([]byte(body))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(tw.Close()).To(Succeed())
+	Expect(gz.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+func zipped(files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = w.Write([]byte(body))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(zw.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+func sum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// verifierFunc is a verify.Verifier from a function.
+type verifierFunc func(data, sig []byte) error
+
+func (f verifierFunc) Verify(data, sig []byte) error { return f(data, sig) }
+
+// served answers with the documents in docs, and 404s anything else.
+func served(docs map[string][]byte) download.Getter {
+	return func(url string) (io.ReadCloser, error) {
+		if data, ok := docs[url]; ok {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		return nil, fmt.Errorf("GET %s: HTTP 404", url)
+	}
+}
+
+var _ = Describe("Release", func() {
+	const (
+		base  = "https://example.com/v0.44.1/"
+		asset = "lazygit_0.44.1_Linux_x86_64.tar.gz"
+	)
+	var (
+		dir     string
+		archive []byte
+		docs    map[string][]byte
+		release download.Release
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		archive = tarGz(map[string]string{"./lazygit": binary, "LICENSE": "MIT"})
+		docs = map[string][]byte{
+			base + asset:           archive,
+			base + "checksums.txt": []byte(sum([]byte("other")) + "  other.tar.gz\n" + sum(archive) + "  " + asset + "\n"),
+		}
+		release = download.Release{
+			Tool:    "lazygit",
+			Default: "0.44.1",
+			URL:     "https://example.com/v{version}/lazygit_{version}_{os}_{arch}.tar.gz",
+			Sums:    "https://example.com/v{version}/checksums.txt",
+			Binary:  "lazygit",
+			Bin:     "lazygit",
+			Dir:     dir,
+			OS:      map[string]string{"linux": "Linux"},
+			Arch:    map[string]string{"amd64": "x86_64"},
+		}.For("linux", "amd64")
+	})
+
+	install := func(r download.Release) error {
+		r.Get = served(docs)
+		return r.Install(&bytes.Buffer{}, &bytes.Buffer{})
+	}
+
+	It("spells the platform the way the project names its assets", func() {
+		Expect(release.Plan()).To(Equal([]string{
+			"download https://example.com/v0.44.1/" + asset,
+			"check its SHA-256 against https://example.com/v0.44.1/checksums.txt",
+			"extract lazygit",
+			"install it to " + filepath.Join(dir, "lazygit") + " (mode 0755)",
+		}))
+		Expect(release.At("v0.40.0").Plan()[0]).To(Equal("download https://example.com/v0.40.0/lazygit_0.40.0_Linux_x86_64.tar.gz"))
+	})
+
+	It("installs the binary out of a tar.gz the release's checksum file vouches for", func() {
+		Expect(install(release)).To(Succeed())
+
+		data, err := os.ReadFile(filepath.Join(dir, "lazygit"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal(binary))
+		info, err := os.Stat(filepath.Join(dir, "lazygit"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o755)))
+		entries, err := os.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	It("installs out of a zip against a pinned checksum", func() {
+		zipAsset := zipped(map[string]string{"terraform": binary})
+		docs["https://example.com/1.9.8/terraform_1.9.8_linux_amd64.zip"] = zipAsset
+		terraform := download.Release{
+			Tool: "terraform", Default: "1.9.8",
+			URL:       "https://example.com/{version}/terraform_{version}_{os}_{arch}.zip",
+			Checksums: map[string]map[string]string{"1.9.8": {"terraform_1.9.8_linux_amd64.zip": sum(zipAsset)}},
+			Binary:    "terraform", Bin: "terraform", Dir: dir,
+		}.For("linux", "amd64")
+
+		Expect(install(terraform)).To(Succeed())
+		Expect(filepath.Join(dir, "terraform")).To(BeARegularFile())
+	})
+
+	It("installs a bare binary as it is", func() {
+		docs[base+"lazygit"] = []byte(binary)
+		release.URL, release.Binary = "https://example.com/v{version}/lazygit", ""
+		release.Checksums = map[string]map[string]string{"0.44.1": {"lazygit": sum([]byte(binary))}}
+
+		Expect(install(release)).To(Succeed())
+		Expect(os.ReadFile(filepath.Join(dir, "lazygit"))).To(Equal([]byte(binary)))
+	})
+
+	It("applies a pinned checksum only to its own version", func() {
+		docs[base+"lazygit"] = []byte(binary)
+		release.URL, release.Binary = "https://example.com/v{version}/lazygit", ""
+		release.Checksums = map[string]map[string]string{"0.40.0": {"lazygit": sum([]byte(binary))}}
+
+		Expect(install(release)).To(MatchError(ContainSubstring("checksums.txt lists no checksum for lazygit")))
+		Expect(release.At("0.40.0").Plan()).To(ContainElement("check its SHA-256 is " + sum([]byte(binary))))
+	})
+
+	It("writes nothing when the download does not match its checksum", func() {
+		release.Checksums = map[string]map[string]string{"0.44.1": {asset: sum([]byte("tampered"))}}
+
+		err := install(release)
+
+		Expect(err).To(MatchError(ContainSubstring("lazygit: " + asset + " has SHA-256 " + sum(archive) + ", want " + sum([]byte("tampered")))))
+		Expect(filepath.Join(dir, "lazygit")).NotTo(BeAnExistingFile())
+	})
+
+	It("evicts what it fetched for a download that does not match, so it is fetched afresh", func() {
+		release.Checksums = map[string]map[string]string{"0.44.1": {asset: sum([]byte("tampered"))}}
+		var evicted []string
+		release.Evict = func(url string) error {
+			evicted = append(evicted, url)
+			return nil
+		}
+
+		Expect(install(release)).NotTo(Succeed())
+		Expect(evicted).To(Equal([]string{base + asset, base + "checksums.txt"}))
+	})
+
+	It("refuses a download it has no checksum for", func() {
+		release.Sums = ""
+
+		Expect(install(release)).To(MatchError("lazygit: no checksum for " + asset + ": pin one or name the release's checksum file"))
+		Expect(release.Plan()).To(ContainElement("refuse it: no checksum to check it against"))
+	})
+
+	It("refuses an asset the checksum file does not list", func() {
+		docs[base+"checksums.txt"] = []byte(sum([]byte("other")) + "  other.tar.gz\n")
+
+		Expect(install(release)).To(MatchError(ContainSubstring("checksums.txt lists no checksum for " + asset)))
+	})
+
+	It("fails when the archive lacks the binary", func() {
+		release.Binary = "bin/lazygit"
+
+		Expect(install(release)).To(MatchError("lazygit: " + asset + " has no bin/lazygit"))
+		Expect(filepath.Join(dir, "lazygit")).NotTo(BeAnExistingFile())
+	})
+
+	Context("with a signed checksum file", func() {
+		var signed []byte
+
+		BeforeEach(func() {
+			signed = nil
+			docs[base+"checksums.txt.sig"] = []byte("signature")
+			release.Signature = "https://example.com/v{version}/checksums.txt.sig"
+			release.Verifier = verifierFunc(func(data, sig []byte) error {
+				if string(sig) != "signature" {
+					return errors.New("bad signature")
+				}
+				signed = data
+				return nil
+			})
+		})
+
+		It("installs once the signature over the checksum file checks out", func() {
+			Expect(install(release)).To(Succeed())
+
+			Expect(signed).To(Equal(docs[base+"checksums.txt"]))
+			Expect(release.Plan()).To(ContainElement("check that file's signature https://example.com/v0.44.1/checksums.txt.sig"))
+		})
+
+		It("writes nothing when the signature does not verify", func() {
+			docs[base+"checksums.txt.sig"] = []byte("forged")
+
+			err := install(release)
+
+			Expect(err).To(MatchError(ContainSubstring("checksums.txt does not match its signature " + base + "checksums.txt.sig: bad signature")))
+			Expect(filepath.Join(dir, "lazygit")).NotTo(BeAnExistingFile())
+		})
+
+		It("installs unverified with SkipVerify, and says so", func() {
+			delete(docs, base+"checksums.txt.sig")
+			release.SkipVerify = true
+			release.Get = served(docs)
+			var stderr bytes.Buffer
+
+			Expect(release.Install(&bytes.Buffer{}, &stderr)).To(Succeed())
+
+			Expect(signed).To(BeNil())
+			Expect(stderr.String()).To(ContainSubstring("WARNING: NOT verifying the signature of " + base + "checksums.txt"))
+			Expect(release.Plan()).To(ContainElement("do NOT check that file's signature (--insecure-skip-verify)"))
+		})
+	})
+
+	It("detects the installed binary's version", func() {
+		installed, _, err := release.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeFalse())
+
+		Expect(install(release)).To(Succeed())
+
+		installed, version, err := release.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeTrue())
+		Expect(version).To(Equal("0.44.1"))
+	})
+})
+
+var _ = Describe("BuiltIn", func() {
+	neovim := download.BuiltIn()["neovim-appimage"]
+
+	It("downloads the default Neovim release unless pinned", func() {
+		Expect(neovim.Version()).To(Equal(download.DefaultNeovimVersion))
+		Expect(neovim.Plan()[0]).To(ContainSubstring("/download/v" + download.DefaultNeovimVersion + "/"))
+	})
+
+	It("downloads the pinned release tag", func() {
+		pinned := neovim.At("v0.10.4").For("linux", "amd64")
+
+		Expect(pinned.Version()).To(Equal("0.10.4"))
+		Expect(pinned.Plan()[0]).To(Equal("download https://github.com/neovim/neovim/releases/download/v0.10.4/nvim-linux-x86_64.appimage"))
+	})
+})
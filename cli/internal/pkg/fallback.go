@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// step is one way to install a tool, labelled for display ("apt",
+// "download", ...).
+type step struct {
+	Installable
+	via string
+}
+
+// fallback installs a tool by the first of its strategies that succeeds,
+// e.g. apt, then the upstream install script. Detection asks each strategy in
+// turn, so the tool counts as installed whichever one put it there.
+type fallback struct {
+	name  string
+	chain []step
+}
+
+func (f fallback) Name() string { return f.name }
+
+// Install tries each strategy in order, saying on stderr why it moves on.
+func (f fallback) Install(stdout, stderr io.Writer) error {
+	var errs []error
+	for i, s := range f.chain {
+		err := s.Install(stdout, stderr)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("via %s: %w", s.via, err))
+		if i+1 < len(f.chain) {
+			fmt.Fprintf(stderr, "  %s: install via %s failed (%v); trying %s\n", f.name, s.via, err, f.chain[i+1].via)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Plan is the first strategy's plan, then each fallback's under an "if that
+// fails" line.
+func (f fallback) Plan() []string {
+	plan := f.chain[0].Plan()
+	for _, s := range f.chain[1:] {
+		plan = append(plan, "if that fails, via "+s.via+":")
+		for _, line := range s.Plan() {
+			plan = append(plan, "  "+line)
+		}
+	}
+	return plan
+}
+
+// Detect reports the first strategy that finds the tool installed. An error
+// only surfaces when no strategy finds it.
+func (f fallback) Detect() (bool, string, error) {
+	var firstErr error
+	for _, s := range f.chain {
+		installed, version, err := s.Detect()
+		if installed {
+			return true, version, nil
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return false, "", firstErr
+}
+
+// Version is the preferred strategy's: every strategy is built with the same
+// pin, but only some kinds can honour it.
+func (f fallback) Version() string { return f.chain[0].Version() }
+
+// Lock is the preferred strategy's lock, held for the whole chain.
+func (f fallback) Lock() string { return LockOf(f.chain[0].Installable) }
+
+// The Batchable methods forward to the preferred strategy. When a batch
+// fails, its members are retried one by one through Install, which falls
+// back as usual.
+func (f fallback) BatchKey() string { return batchKey(f.chain[0].Installable) }
+func (f fallback) BatchArg() string { return f.chain[0].Installable.(Batchable).BatchArg() }
+func (f fallback) InstallBatch(args []string, stdout, stderr io.Writer) error {
+	return f.chain[0].Installable.(Batchable).InstallBatch(args, stdout, stderr)
+}
+func (f fallback) BatchPlan(args []string) []string {
+	return f.chain[0].Installable.(Batchable).BatchPlan(args)
+}
+
+// chainVia labels a chain for the picker: "apt, else script".
+func chainVia(chain []step) string {
+	labels := make([]string, len(chain))
+	for i, s := range chain {
+		labels[i] = s.via
+	}
+	return strings.Join(labels, ", else ")
+}
@@ -0,0 +1,91 @@
+package pkg_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg"
+	"github.com/cloudwalk/machine-setup/internal/pkg/apt"
+	"github.com/cloudwalk/machine-setup/internal/pkg/script"
+)
+
+var _ = Describe("fallback strategies", func() {
+	var (
+		aptErr  error
+		scripts []string
+		rustup  pkg.Installable
+		reg     *pkg.DevToolRegistry
+	)
+
+	BeforeEach(func() {
+		aptErr, scripts = nil, nil
+		runAPT := func(_ []string, _, _ io.Writer) error { return aptErr }
+		base := script.Script{
+			Trust: script.Trust{Approved: GinkgoT().TempDir(), Approve: func(script.Review) (bool, error) { return true, nil }},
+			Fetch: func(string) ([]byte, error) { return []byte("echo installing\n"), nil },
+			Exec: func(shell, file string, args, _ []string, _, _ io.Writer) error {
+				scripts = append(scripts, strings.Join(append([]string{shell, filepath.Base(file)}, args...), " "))
+				return nil
+			},
+		}
+		var extras []pkg.Installable
+		for _, s := range script.BuiltIn("/home/dev", base) {
+			extras = append(extras, s)
+		}
+		catalog, err := pkg.ParseCatalog("tools.yaml", []byte(`tools:
+  - name: rustup
+    install:
+      linux:
+        - {strategy: apt}
+        - {strategy: script, source: rustup}
+`))
+		Expect(err).NotTo(HaveOccurred())
+		factory, err := pkg.NewRegistryFactory(nil, apt.Runner(runAPT), nil, extras...).WithCatalog(catalog)
+		Expect(err).NotTo(HaveOccurred())
+		reg = factory.For("linux")
+		rustup = reg.Installables()[0]
+	})
+
+	It("offers the tool once, labelled with its strategies", func() {
+		Expect(reg.Names()).To(Equal([]string{"rustup", "ghcup", "k3d", "lazydocker", "golangci-lint"}))
+		Expect(reg.Strategies()).To(HaveKeyWithValue("rustup", "apt, else script"))
+	})
+
+	It("installs with the first strategy when it succeeds", func() {
+		Expect(rustup.Install(&bytes.Buffer{}, &bytes.Buffer{})).To(Succeed())
+		Expect(scripts).To(BeEmpty())
+	})
+
+	It("falls back to the next strategy, saying why", func() {
+		aptErr = errors.New("no such package")
+		stderr := &bytes.Buffer{}
+
+		Expect(rustup.Install(&bytes.Buffer{}, stderr)).To(Succeed())
+
+		Expect(scripts).To(Equal([]string{"sh sh.rustup.rs -y --no-modify-path"}))
+		Expect(stderr.String()).To(Equal("  rustup: install via apt failed (no such package); trying script\n"))
+	})
+
+	It("plans every strategy in order", func() {
+		Expect(rustup.Plan()).To(Equal([]string{
+			"sudo apt-get install -y rustup",
+			"if that fails, via script:",
+			"  download https://sh.rustup.rs",
+			"  not pinned: ask before running it, showing any change from the copy approved before",
+			"  sh sh.rustup.rs -y --no-modify-path",
+		}))
+	})
+
+	It("batches with the first strategy's package manager", func() {
+		Expect(pkg.LockOf(rustup)).To(Equal("dpkg"))
+		b, ok := rustup.(pkg.Batchable)
+		Expect(ok).To(BeTrue())
+		Expect(b.BatchKey()).To(Equal("apt"))
+	})
+})
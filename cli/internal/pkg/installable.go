@@ -1,12 +1,98 @@
 package pkg
 
-import "io"
+import (
+	"io"
+	"strings"
+	"sync"
+)
 
 // Installable is the polymorphic surface for "something the CLI knows how to
 // install on a machine". Each kind (brew formula, brew cask, apt package,
 // AppImage download) is its own type that satisfies this interface — there
 // are no type switches anywhere downstream.
+//
+// Plan describes what Install would do, one line per action, without doing
+// it — typically the exact commands it would run. It backs `setup --dry-run`.
+//
+// Detect reports whether the tool is already on the machine and, when the
+// kind can tell, which version. Version is the version the CLI wants ("" when
+// whatever the package manager ships will do).
 type Installable interface {
 	Name() string
 	Install(stdout, stderr io.Writer) error
+	Plan() []string
+	Detect() (installed bool, version string, err error)
+	Version() string
+}
+
+// Locker is implemented by kinds whose installs must not overlap because
+// they share a lock, e.g. every brew install takes brew's, every apt install
+// dpkg's. Lock names the shared resource.
+type Locker interface {
+	Lock() string
+}
+
+// LockOf returns the lock t's install takes, or "" when it can run alongside
+// anything.
+func LockOf(t Installable) string {
+	if l, ok := t.(Locker); ok {
+		return l.Lock()
+	}
+	return ""
+}
+
+// State is what Detect found on the machine.
+type State struct {
+	Installed bool
+	Version   string // installed version, when the kind can tell
+}
+
+// Satisfies reports whether the installed state meets want: installed, and
+// at that version when want is set. want may name a release series, so
+// "1.22" is met by "1.22.5", and "3.12.3" by the Debian "3.12.3-0ubuntu1" —
+// but "1.2" is not met by "1.22".
+func (s State) Satisfies(want string) bool {
+	if !s.Installed {
+		return false
+	}
+	return want == "" || VersionMatches(s.Version, want)
+}
+
+// VersionMatches reports whether installed is want or a more specific
+// version in want's series.
+func VersionMatches(installed, want string) bool {
+	installed = strings.TrimPrefix(installed, "v")
+	want = strings.TrimPrefix(want, "v")
+	rest, ok := strings.CutPrefix(installed, want)
+	return ok && (rest == "" || strings.ContainsRune(".-_+~", rune(rest[0])))
+}
+
+// Detected pairs an Installable with the outcome of its Detect.
+type Detected struct {
+	Installable
+	State State
+	Err   error
+}
+
+// Current reports whether the tool is installed at the wanted version, so
+// installing it again would be a no-op.
+func (d Detected) Current() bool {
+	return d.Err == nil && d.State.Satisfies(d.Installable.Version())
+}
+
+// DetectAll runs Detect for every installable concurrently — each is a
+// read-only package-manager query — and returns the results in input order.
+func DetectAll(ts []Installable) []Detected {
+	out := make([]Detected, len(ts))
+	var wg sync.WaitGroup
+	for i, t := range ts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			installed, version, err := t.Detect()
+			out[i] = Detected{Installable: t, State: State{Installed: installed, Version: version}, Err: err}
+		}()
+	}
+	wg.Wait()
+	return out
 }
@@ -10,15 +10,25 @@ import (
 	"github.com/cloudwalk/machine-setup/internal/pkg"
 	"github.com/cloudwalk/machine-setup/internal/pkg/apt"
 	"github.com/cloudwalk/machine-setup/internal/pkg/brew"
+	"github.com/cloudwalk/machine-setup/internal/pkg/distro"
 )
 
 // fakeInstallable is a minimal pkg.Installable for exercising DevToolRegistry.
 // Tests should NOT use it to verify behavior of real Installable types — those
 // are tested in their own packages.
-type fakeInstallable struct{ name string }
+type fakeInstallable struct {
+	name      string
+	installed string // installed version; "" means not installed
+	want      string
+}
 
-func (f fakeInstallable) Name() string                    { return f.name }
-func (f fakeInstallable) Install(_, _ io.Writer) error    { return nil }
+func (f fakeInstallable) Name() string                 { return f.name }
+func (f fakeInstallable) Install(_, _ io.Writer) error { return nil }
+func (f fakeInstallable) Plan() []string               { return nil }
+func (f fakeInstallable) Version() string              { return f.want }
+func (f fakeInstallable) Detect() (bool, string, error) {
+	return f.installed != "", f.installed, nil
+}
 
 var _ = Describe("DevToolRegistry", func() {
 	var registry *pkg.DevToolRegistry
@@ -58,6 +68,47 @@ var _ = Describe("DevToolRegistry", func() {
 	})
 })
 
+var _ = Describe("DetectAll", func() {
+	It("returns every state in input order", func() {
+		got := pkg.DetectAll([]pkg.Installable{
+			fakeInstallable{name: "jq", installed: "1.7.1"},
+			fakeInstallable{name: "fzf"},
+		})
+
+		Expect(got).To(HaveLen(2))
+		Expect(got[0].Name()).To(Equal("jq"))
+		Expect(got[0].State).To(Equal(pkg.State{Installed: true, Version: "1.7.1"}))
+		Expect(got[1].State.Installed).To(BeFalse())
+	})
+
+	It("is current only when the installed version is the wanted one", func() {
+		got := pkg.DetectAll([]pkg.Installable{
+			fakeInstallable{name: "any", installed: "1.0"},
+			fakeInstallable{name: "pinned", installed: "0.10.0", want: "0.11.6"},
+			fakeInstallable{name: "missing"},
+		})
+
+		Expect(got[0].Current()).To(BeTrue())
+		Expect(got[1].Current()).To(BeFalse())
+		Expect(got[2].Current()).To(BeFalse())
+	})
+})
+
+var _ = Describe("VersionMatches", func() {
+	DescribeTable("matches a version or a more specific one in its series",
+		func(installed, want string, ok bool) {
+			Expect(pkg.VersionMatches(installed, want)).To(Equal(ok))
+		},
+		Entry("exact", "1.7.1", "1.7.1", true),
+		Entry("series", "1.22.5", "1.22", true),
+		Entry("debian revision", "3.12.3-0ubuntu1", "3.12.3", true),
+		Entry("brew revision", "1.7.1_1", "1.7.1", true),
+		Entry("v prefix", "v0.11.6", "0.11.6", true),
+		Entry("different series", "1.22.5", "1.2", false),
+		Entry("older", "0.10.0", "0.11.6", false),
+	)
+})
+
 // recordingRunner records the args of every brew/apt invocation that flows
 // through a registry-emitted Installable.
 type recordingRunner struct {
@@ -71,6 +122,16 @@ func (r *recordingRunner) Run(args []string, _, _ io.Writer) error {
 	return nil
 }
 
+// scriptSources stands in for the script installers tools.yaml names.
+var scriptSources = []pkg.Installable{
+	fakeInstallable{name: "rustup"},
+	fakeInstallable{name: "ghcup"},
+	fakeInstallable{name: "lazydocker"},
+	fakeInstallable{name: "k3d"},
+	fakeInstallable{name: "golangci-lint"},
+	fakeInstallable{name: "rvm"},
+}
+
 var _ = Describe("RegistryFactory", func() {
 	var (
 		brewSpy *recordingRunner
@@ -81,10 +142,14 @@ var _ = Describe("RegistryFactory", func() {
 	BeforeEach(func() {
 		brewSpy = &recordingRunner{}
 		aptSpy = &recordingRunner{}
-		factory = pkg.NewRegistryFactory(
+		var err error
+		factory, err = pkg.NewRegistryFactory(
 			brew.Runner(brewSpy.Run),
 			apt.Runner(aptSpy.Run),
-		)
+			nil,
+			scriptSources...,
+		).WithCatalog(repoCatalog())
+		Expect(err).NotTo(HaveOccurred())
 	})
 
 	It("returns an empty registry for an unsupported OS", func() {
@@ -115,21 +180,118 @@ var _ = Describe("RegistryFactory", func() {
 		Fail("no installable in the linux registry routed through apt")
 	})
 
+	It("offers the catalog's tools for each OS in catalog order", func() {
+		Expect(factory.For("darwin").Names()).To(HaveLen(26))
+		Expect(factory.For("darwin").Names()[22:]).To(Equal([]string{"terraform", "curl", "gpg", "rvm"}))
+		Expect(factory.For("linux").Names()).To(Equal([]string{
+			"neovim", "byobu", "fzf", "ripgrep", "bat", "eza",
+			"jq", "gh", "go", "node", "python", "yarn",
+			"rustup", "ghcup", "lazygit", "lazydocker", "k9s", "k3d",
+			"ruby", "ansible", "golangci-lint", "terraform", "curl", "gpg", "rvm",
+		}))
+		Expect(factory.For("darwin").Dependencies()).To(HaveKeyWithValue("yarn", []string{"node"}))
+	})
+
+	It("installs a tool under the package name the catalog gives for the OS", func() {
+		for _, tool := range factory.For("linux").Installables() {
+			if tool.Name() == "go" {
+				Expect(tool.Plan()).To(Equal([]string{"sudo apt-get install -y golang"}))
+				Expect(pkg.LockOf(tool)).To(Equal("dpkg"))
+				return
+			}
+		}
+		Fail("go missing from the linux registry")
+	})
+
+	It("rejects a catalog naming a script this build does not have", func() {
+		_, err := pkg.NewRegistryFactory(nil, nil, nil).WithCatalog(repoCatalog())
+		Expect(err).To(MatchError(MatchRegexp(`tools\.yaml:\d+: tool "rvm": (darwin|linux): unknown script source "rvm"`)))
+	})
+
 	It("appends caller-provided extras to every supported-OS registry", func() {
 		extra := fakeInstallable{name: "my-extra"}
-		factoryWithExtra := pkg.NewRegistryFactory(
+		factoryWithExtra, err := pkg.NewRegistryFactory(
 			brew.Runner(brewSpy.Run),
 			apt.Runner(aptSpy.Run),
+			nil,
 			extra,
-		)
+		).WithCatalog(&pkg.Catalog{})
+		Expect(err).NotTo(HaveOccurred())
 
 		Expect(factoryWithExtra.For("darwin").Names()).To(ContainElement("my-extra"))
 		Expect(factoryWithExtra.For("linux").Names()).To(ContainElement("my-extra"))
 	})
 
+	It("pins the versions it is given and reports those it cannot apply", func() {
+		pins := map[string]string{"go": "1.22", "neovim": "0.10.4", "my-extra": "2.0"}
+		extras := append([]pkg.Installable{fakeInstallable{name: "my-extra"}}, scriptSources...)
+		pinned, err := pkg.NewRegistryFactory(brew.Runner(brewSpy.Run), apt.Runner(aptSpy.Run), nil, extras...).
+			WithCatalog(repoCatalog())
+		Expect(err).NotTo(HaveOccurred())
+		pinned = pinned.WithPins(pins)
+
+		darwin := pinned.For("darwin")
+		for _, t := range darwin.Installables() {
+			if t.Name() == "go" {
+				Expect(t.Version()).To(Equal("1.22"))
+				Expect(t.Plan()).To(Equal([]string{"brew install go@1.22"}))
+			}
+		}
+		Expect(pkg.UnappliedPins(darwin, pins)).To(Equal([]string{"my-extra"}))
+		Expect(pinned.For("linux").Installables()[0].Version()).To(Equal("0.10.4"))
+	})
+
+	Describe("on other Linux distributions", func() {
+		planOf := func(r *pkg.DevToolRegistry, name string) []string {
+			for _, t := range r.Installables() {
+				if t.Name() == name {
+					return t.Plan()
+				}
+			}
+			return nil
+		}
+
+		It("installs system packages through dnf on Fedora, by dnf's names", func() {
+			fedora := factory.WithDistro(distro.Distro{ID: "fedora"})
+
+			Expect(fedora.SystemManager()).To(Equal("dnf"))
+			Expect(planOf(fedora.For("linux"), "go")).To(Equal([]string{"sudo dnf --assumeyes install golang"}))
+			Expect(fedora.Unmapped("linux")).To(BeEmpty())
+			Expect(fedora.For("linux").Strategies()).To(HaveKeyWithValue("lazygit", "download"))
+		})
+
+		It("installs through pacman on Arch, leaving out and reporting what it has no name for", func() {
+			arch := factory.WithDistro(distro.Distro{ID: "endeavouros", Like: []string{"arch"}})
+
+			registry := arch.For("linux")
+
+			Expect(planOf(registry, "gh")).To(Equal([]string{"sudo pacman -S --needed --noconfirm github-cli"}))
+			Expect(registry.Names()).NotTo(ContainElement("byobu"))
+			Expect(arch.Unmapped("linux")).To(Equal([]string{"byobu"}))
+		})
+
+		It("offers only downloads and scripts on a distribution of no known family", func() {
+			unknown := factory.WithDistro(distro.Distro{ID: "nixos"})
+
+			Expect(unknown.SystemManager()).To(BeEmpty())
+			Expect(unknown.For("linux").Names()).To(Equal([]string{
+				"neovim", "rustup", "ghcup", "lazygit", "lazydocker", "k9s", "k3d",
+				"golangci-lint", "terraform", "rvm",
+			}))
+			Expect(unknown.Unmapped("linux")).To(HaveLen(15))
+			Expect(unknown.For("linux").Strategies()).To(HaveKeyWithValue("rustup", "script"))
+		})
+
+		It("keeps apt on the Debian family", func() {
+			mint := factory.WithDistro(distro.Distro{ID: "linuxmint", Like: []string{"ubuntu", "debian"}})
+
+			Expect(planOf(mint.For("linux"), "go")).To(Equal([]string{"sudo apt-get install -y golang"}))
+		})
+	})
+
 	It("does NOT include extras when the OS is unsupported", func() {
 		extra := fakeInstallable{name: "my-extra"}
-		factoryWithExtra := pkg.NewRegistryFactory(nil, nil, extra)
+		factoryWithExtra := pkg.NewRegistryFactory(nil, nil, nil, extra)
 
 		Expect(factoryWithExtra.For("plan9").Installables()).To(BeEmpty())
 	})
@@ -0,0 +1,123 @@
+// Package pacman provides the Installable kind for Arch Linux and its
+// relatives, installing packages with pacman.
+package pacman
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Runner runs a pacman operation. Production wiring shells out to
+// `sudo pacman …`; tests inject a recorder.
+type Runner func(args []string, stdout, stderr io.Writer) error
+
+// DefaultRunner returns the production Runner.
+func DefaultRunner() Runner {
+	return func(args []string, stdout, stderr io.Writer) error {
+		cmd := exec.Command("sudo", append([]string{"pacman"}, args...)...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		return cmd.Run()
+	}
+}
+
+// DefaultQueryRunner returns the production Runner for read-only queries
+// (`pacman -Q`), which need no sudo.
+func DefaultQueryRunner() Runner {
+	return func(args []string, stdout, stderr io.Writer) error {
+		cmd := exec.Command("pacman", args...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		return cmd.Run()
+	}
+}
+
+// Names maps catalog tool names to Arch package names. A tool missing here
+// has no package in the official repositories (byobu is AUR-only) and is not
+// offered on Arch.
+var Names = map[string]string{
+	"fzf":           "fzf",
+	"ripgrep":       "ripgrep",
+	"bat":           "bat",
+	"eza":           "eza",
+	"jq":            "jq",
+	"gh":            "github-cli",
+	"go":            "go",
+	"node":          "nodejs",
+	"python":        "python",
+	"yarn":          "yarn",
+	"rustup":        "rustup",
+	"lazygit":       "lazygit",
+	"ruby":          "ruby",
+	"ansible":       "ansible",
+	"golangci-lint": "golangci-lint",
+	"curl":          "curl",
+	"gpg":           "gnupg",
+}
+
+// Package is a pacman package. Arch is rolling, so packages install at the
+// repositories' version and cannot be pinned.
+type Package struct {
+	name  string
+	run   Runner
+	query Runner
+}
+
+// NewPackage returns the package called name (an Arch name, see Names) bound
+// to an install runner and a query runner.
+func NewPackage(name string, run, query Runner) Package {
+	return Package{name: name, run: run, query: query}
+}
+
+// Name returns the Arch package name.
+func (p Package) Name() string { return p.name }
+
+// Install runs `pacman -S --needed --noconfirm <name>`.
+func (p Package) Install(stdout, stderr io.Writer) error {
+	return p.InstallBatch([]string{p.name}, stdout, stderr)
+}
+
+// Plan returns the pacman command Install runs.
+func (p Package) Plan() []string { return p.BatchPlan([]string{p.name}) }
+
+// Detect runs `pacman -Q <name>`, which prints "<name> <version>" for an
+// installed package and exits non-zero otherwise.
+func (p Package) Detect() (bool, string, error) {
+	var out bytes.Buffer
+	if err := p.query([]string{"-Q", p.name}, &out, io.Discard); err != nil {
+		return false, "", nil
+	}
+	fields := strings.Fields(out.String())
+	if len(fields) < 2 {
+		return false, "", nil
+	}
+	return true, fields[1], nil
+}
+
+// Version is empty: pacman installs whatever the repositories carry.
+func (Package) Version() string { return "" }
+
+// Lock serializes pacman runs, which refuse to share its database lock.
+func (Package) Lock() string { return "pacman" }
+
+// BatchKey lets packages share one `pacman -S a b c`.
+func (Package) BatchKey() string { return "pacman" }
+
+// BatchArg is the package's argument to pacman -S.
+func (p Package) BatchArg() string { return p.name }
+
+// InstallBatch runs `pacman -S --needed --noconfirm <args...>`.
+func (p Package) InstallBatch(args []string, stdout, stderr io.Writer) error {
+	return p.run(syncArgs(args), stdout, stderr)
+}
+
+// BatchPlan returns the pacman command InstallBatch runs.
+func (Package) BatchPlan(args []string) []string {
+	return []string{"sudo pacman " + strings.Join(syncArgs(args), " ")}
+}
+
+func syncArgs(names []string) []string {
+	return append([]string{"-S", "--needed", "--noconfirm"}, names...)
+}
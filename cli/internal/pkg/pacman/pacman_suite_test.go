@@ -0,0 +1,13 @@
+package pacman_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestPacmanSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "pacman Suite")
+}
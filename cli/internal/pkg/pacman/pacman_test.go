@@ -0,0 +1,55 @@
+package pacman_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/pacman"
+)
+
+var _ = Describe("Package", func() {
+	It("installs with pacman -S, skipping what is already up to date", func() {
+		var gotArgs []string
+		spy := func(args []string, _, _ io.Writer) error {
+			gotArgs = args
+			return nil
+		}
+
+		Expect(pacman.NewPackage("github-cli", spy, nil).Install(&bytes.Buffer{}, &bytes.Buffer{})).To(Succeed())
+
+		Expect(gotArgs).To(Equal([]string{"-S", "--needed", "--noconfirm", "github-cli"}))
+	})
+
+	It("plans the exact command", func() {
+		Expect(pacman.NewPackage("jq", nil, nil).BatchPlan([]string{"jq", "fzf"})).To(Equal([]string{"sudo pacman -S --needed --noconfirm jq fzf"}))
+	})
+
+	Describe("Detect", func() {
+		query := func(out string, err error) pacman.Runner {
+			return func(args []string, stdout, _ io.Writer) error {
+				Expect(args).To(Equal([]string{"-Q", "jq"}))
+				_, _ = io.WriteString(stdout, out)
+				return err
+			}
+		}
+
+		It("reports the installed version", func() {
+			installed, version, err := pacman.NewPackage("jq", nil, query("jq 1.7.1-2\n", nil)).Detect()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installed).To(BeTrue())
+			Expect(version).To(Equal("1.7.1-2"))
+		})
+
+		It("treats pacman's non-zero exit as not installed", func() {
+			installed, _, err := pacman.NewPackage("jq", nil, query("", errors.New("exit status 1"))).Detect()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installed).To(BeFalse())
+		})
+	})
+})
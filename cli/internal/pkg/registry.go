@@ -1,8 +1,19 @@
 package pkg
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
 	"github.com/cloudwalk/machine-setup/internal/pkg/apt"
 	"github.com/cloudwalk/machine-setup/internal/pkg/brew"
+	"github.com/cloudwalk/machine-setup/internal/pkg/distro"
+	"github.com/cloudwalk/machine-setup/internal/pkg/dnf"
+	"github.com/cloudwalk/machine-setup/internal/pkg/download"
+	"github.com/cloudwalk/machine-setup/internal/pkg/pacman"
+	"github.com/cloudwalk/machine-setup/internal/pkg/verify"
 )
 
 // DevToolRegistry owns the list of installables the CLI knows about. It is the
@@ -11,6 +22,8 @@ import (
 // being append-only after construction.
 type DevToolRegistry struct {
 	tools []Installable
+	deps  map[string][]string
+	via   map[string]string
 }
 
 // NewDevToolRegistry returns an empty registry.
@@ -35,6 +48,37 @@ func (r *DevToolRegistry) AddAll(ts []Installable) *DevToolRegistry {
 	return r
 }
 
+// Depend records that name must be installed after each of on; returns the
+// receiver.
+func (r *DevToolRegistry) Depend(name string, on ...string) *DevToolRegistry {
+	if r.deps == nil {
+		r.deps = map[string][]string{}
+	}
+	r.deps[name] = append(r.deps[name], on...)
+	return r
+}
+
+// Via records how name installs, e.g. "apt" or "apt, else script"; returns
+// the receiver.
+func (r *DevToolRegistry) Via(name, strategy string) *DevToolRegistry {
+	if r.via == nil {
+		r.via = map[string]string{}
+	}
+	r.via[name] = strategy
+	return r
+}
+
+// Strategies maps each installable's name to how it installs, as recorded
+// by Via.
+func (r *DevToolRegistry) Strategies() map[string]string {
+	return r.via
+}
+
+// Dependencies maps each installable's name to the names it depends on.
+func (r *DevToolRegistry) Dependencies() map[string][]string {
+	return r.deps
+}
+
 // Names projects the name of each installable.
 func (r *DevToolRegistry) Names() []string {
 	names := make([]string, len(r.tools))
@@ -44,77 +88,365 @@ func (r *DevToolRegistry) Names() []string {
 	return names
 }
 
-// RegistryFactory assembles a DevToolRegistry wired for a given OS. Platform
-// runners are captured at construction, plus an optional set of *extras* — any
-// Installable the caller wants appended to every supported-OS registry (e.g.
-// the RVM curl-pipe installer, which isn't a brew/apt entry).
+// RegistryFactory assembles a DevToolRegistry wired for a given OS from the
+// tool Catalog. Platform runners are captured at construction, plus an
+// optional set of *extras* — Installables that are not brew/apt entries (e.g.
+// the RVM curl-pipe installer). The catalog places an extra with the script
+// strategy, naming it as source; extras it never mentions are appended to
+// every supported-OS registry.
 type RegistryFactory struct {
-	brewRun brew.Runner
-	aptRun  apt.Runner
-	extras  []Installable
+	brewRun     brew.Runner
+	aptRun      apt.Runner
+	aptQuery    apt.Runner
+	dnfRun      dnf.Runner
+	dnfQuery    dnf.Runner
+	pacmanRun   pacman.Runner
+	pacmanQuery pacman.Runner
+	extras      []Installable
+	catalog     *Catalog
+	pins        map[string]string
+	distro      *distro.Distro
+	skipVerify  bool
+	get         download.Getter
+	evict       func(url string) error
+	fetch       verify.Fetcher
 }
 
 // NewRegistryFactory captures the platform runners and any cross-platform
-// extras. The extras are appended to every recognized-OS registry.
-func NewRegistryFactory(brewRun brew.Runner, aptRun apt.Runner, extras ...Installable) RegistryFactory {
-	return RegistryFactory{brewRun: brewRun, aptRun: aptRun, extras: extras}
+// extras. aptQuery runs the read-only dpkg queries behind apt.Package.Detect.
+func NewRegistryFactory(brewRun brew.Runner, aptRun, aptQuery apt.Runner, extras ...Installable) RegistryFactory {
+	return RegistryFactory{brewRun: brewRun, aptRun: aptRun, aptQuery: aptQuery, extras: extras}
 }
 
-// For returns the curated registry for the given OS. Unsupported OS → empty
-// (extras are NOT added when no platform is recognized).
+// downloads are the built-in release downloads the catalog's download
+// strategy can name as source.
+var downloads = download.BuiltIn()
+
+// WithCatalog returns a factory whose registries offer c's tools. It fails
+// when an entry names a download or script source this build does not have.
+func (f RegistryFactory) WithCatalog(c *Catalog) (RegistryFactory, error) {
+	var errs []error
+	for _, t := range c.Tools {
+		for goos, s := range allStrategies(t) {
+			var err error
+			switch s.Kind {
+			case StrategyDownload:
+				if _, ok := downloads[s.Source]; !ok {
+					err = fmt.Errorf("unknown download source %q (built in: %s)", s.Source, strings.Join(sortedKeys(downloads), ", "))
+				}
+			case StrategyScript:
+				if f.extra(s.Source) == nil {
+					err = fmt.Errorf("unknown script source %q (built in: %s)", s.Source, strings.Join(f.extraNames(), ", "))
+				}
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s:%d: tool %q: %s: %w", c.File, t.Line, t.Name, goos, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return f, err
+	}
+	f.catalog = c
+	return f, nil
+}
+
+// WithDistro returns a factory whose Linux registries install the catalog's
+// system packages (the apt strategy) through d's package manager: apt on the
+// Debian family, dnf on Fedora's, pacman on Arch's. dnf and pacman look the
+// tool up in their Names table rather than using the catalog's apt package
+// name; a tool missing there is left out (see Unmapped), as is every system
+// package on a distribution of no known family. Without WithDistro, Linux is
+// taken to be Debian.
+func (f RegistryFactory) WithDistro(d distro.Distro) RegistryFactory {
+	f.distro = &d
+	return f
+}
+
+// WithDnf returns a factory installing Fedora's packages through run, and
+// querying them through query.
+func (f RegistryFactory) WithDnf(run, query dnf.Runner) RegistryFactory {
+	f.dnfRun, f.dnfQuery = run, query
+	return f
+}
+
+// WithPacman returns a factory installing Arch's packages through run, and
+// querying them through query.
+func (f RegistryFactory) WithPacman(run, query pacman.Runner) RegistryFactory {
+	f.pacmanRun, f.pacmanQuery = run, query
+	return f
+}
+
+// SystemManager names the package manager Linux registries install system
+// packages with: "apt", "dnf", "pacman", or "" on a distribution of no known
+// family.
+func (f RegistryFactory) SystemManager() string {
+	if f.distro == nil {
+		return "apt"
+	}
+	switch f.distro.Family() {
+	case distro.Debian:
+		return "apt"
+	case distro.Fedora:
+		return "dnf"
+	case distro.Arch:
+		return "pacman"
+	}
+	return ""
+}
+
+// Unmapped returns the catalog tools For(goos) leaves out because none of
+// their strategies can install on this machine — a system package the
+// package manager has no name for, with nothing to fall back to — in catalog
+// order.
+func (f RegistryFactory) Unmapped(goos string) []string {
+	if f.catalog == nil {
+		return nil
+	}
+	var out []string
+	for _, t := range f.catalog.Tools {
+		if len(t.Install[goos]) > 0 && len(f.chain(t.Name, t.Install[goos])) == 0 {
+			out = append(out, t.Name)
+		}
+	}
+	return out
+}
+
+// ReleaseRepos maps the catalog tools that install on goos from a release
+// download to the download's GitHub repository, against which a version
+// spec such as "latest" is resolved.
+func (f RegistryFactory) ReleaseRepos(goos string) map[string]string {
+	repos := map[string]string{}
+	if f.catalog == nil {
+		return repos
+	}
+	for _, t := range f.catalog.Tools {
+		for _, s := range t.Install[goos] {
+			if s.Kind == StrategyDownload && downloads[s.Source].Repo != "" {
+				repos[t.Name] = downloads[s.Source].Repo
+			}
+		}
+	}
+	return repos
+}
+
+// WithPins returns a factory whose registries install the versions in pins
+// (tool name → version), as set by `version:` in the config's packages.
+// Only brew formulas and taps, apt and dnf packages and downloads can be
+// pinned; see UnappliedPins.
+func (f RegistryFactory) WithPins(pins map[string]string) RegistryFactory {
+	f.pins = pins
+	return f
+}
+
+// WithDownloads returns a factory whose release downloads fetch with get,
+// and any signing keys not built in with fetch, e.g. through the download
+// cache. A download that fails its check is passed to evict, which drops any
+// copy get keeps of it.
+func (f RegistryFactory) WithDownloads(get download.Getter, evict func(url string) error, fetch verify.Fetcher) RegistryFactory {
+	f.get, f.evict, f.fetch = get, evict, fetch
+	return f
+}
+
+// WithInsecureSkipVerify returns a factory whose release downloads install
+// without checking their signatures, as --insecure-skip-verify asks.
+func (f RegistryFactory) WithInsecureSkipVerify(skip bool) RegistryFactory {
+	f.skipVerify = skip
+	return f
+}
+
+// UnappliedPins returns the names in pins that r offers but could not pin,
+// in registry order — e.g. casks or the rvm installer.
+func UnappliedPins(r *DevToolRegistry, pins map[string]string) []string {
+	var out []string
+	for _, t := range r.Installables() {
+		if want, ok := pins[t.Name()]; ok && want != "" && !VersionMatches(t.Version(), want) {
+			out = append(out, t.Name())
+		}
+	}
+	return out
+}
+
+// For returns the registry for the given OS: every catalog tool with a
+// strategy for goos, in catalog order, then the unplaced extras.
+// Unsupported OS → empty (extras are NOT added when no platform is
+// recognized).
 func (f RegistryFactory) For(goos string) *DevToolRegistry {
 	r := NewDevToolRegistry()
-	switch goos {
-	case "darwin":
-		f.wireDarwin(r)
-	case "linux":
-		f.wireLinux(r)
-	default:
+	if !slices.Contains(catalogOSes, goos) {
 		return r
 	}
-	r.AddAll(f.extras)
+	placed := map[string]bool{}
+	if f.catalog != nil {
+		for _, t := range f.catalog.Tools {
+			for _, s := range allStrategies(t) {
+				if s.Kind == StrategyScript {
+					placed[s.Source] = true
+				}
+			}
+			chain := f.chain(t.Name, t.Install[goos])
+			if len(chain) == 0 {
+				continue // not for goos, or reported by Unmapped
+			}
+			inst, via := chain[0].Installable, chain[0].via
+			if len(chain) > 1 {
+				inst, via = fallback{name: t.Name, chain: chain}, chainVia(chain)
+			}
+			r.Add(inst).Via(t.Name, via)
+			if len(t.Depends) > 0 {
+				r.Depend(t.Name, t.Depends...)
+			}
+		}
+	}
+	for _, e := range f.extras {
+		if !placed[e.Name()] {
+			r.Add(e)
+		}
+	}
 	return r
 }
 
-// darwinFormulas is the curated list of brew formulas installed on macOS.
-// This is configuration data — adding a tool means adding a name here.
-// Tapped formulas (those that need `brew tap` first, like terraform under
-// hashicorp/tap) go in darwinTappedFormulas instead.
-var darwinFormulas = []string{
-	"neovim", "byobu", "fzf", "ripgrep", "bat", "eza",
-	"jq", "gh", "go", "node", "python",
-	"yarn", "n",
-	"rustup", "ghcup",
-	"lazygit", "lazydocker", "k9s", "k3d",
-	"ruby", "ansible", "golangci-lint",
+// chain builds the strategies that can install name here, in order, each
+// labelled with how it installs.
+func (f RegistryFactory) chain(name string, strategies []Strategy) []step {
+	var out []step
+	for _, s := range strategies {
+		if inst := f.build(name, s); inst != nil {
+			out = append(out, step{Installable: inst, via: f.via(s)})
+		}
+	}
+	return out
+}
+
+// via labels a strategy for the picker: the package manager it runs, or
+// "download"/"script".
+func (f RegistryFactory) via(s Strategy) string {
+	switch s.Kind {
+	case StrategyFormula:
+		return "brew"
+	case StrategyCask:
+		return "brew cask"
+	case StrategyTap:
+		return "brew tap " + s.Tap
+	case StrategyApt:
+		return f.SystemManager()
+	}
+	return s.Kind
 }
 
-// darwinTappedFormulas pairs each name with its required tap. The TappedFormula
-// installer runs `brew tap <tap>` and then `brew install <tap>/<name>`.
-var darwinTappedFormulas = map[string]string{
-	"terraform": "hashicorp/tap",
+// allStrategies lists t's strategies across every OS, keyed for error
+// messages the way the catalog's are: "linux", or "linux[2]" in a list.
+func allStrategies(t Tool) map[string]Strategy {
+	out := map[string]Strategy{}
+	for goos, list := range t.Install {
+		for i, s := range list {
+			key := goos
+			if len(list) > 1 {
+				key = fmt.Sprintf("%s[%d]", goos, i+1)
+			}
+			out[key] = s
+		}
+	}
+	return out
 }
 
-func (f RegistryFactory) wireDarwin(r *DevToolRegistry) {
-	builder := brew.NewBuilder(f.brewRun)
-	for _, formula := range builder.Formulas(darwinFormulas...) {
-		r.Add(formula)
+// build turns one catalog strategy into its Installable, pinned when the
+// config pins the tool. It is nil for a system package the distribution's
+// package manager has no name for.
+func (f RegistryFactory) build(name string, s Strategy) Installable {
+	pin := f.pins[name]
+	var inst Installable
+	switch s.Kind {
+	case StrategyFormula:
+		inst = brew.NewFormula(s.packageOr(name), f.brewRun).At(pin)
+	case StrategyCask:
+		inst = brew.NewCask(s.packageOr(name), f.brewRun)
+	case StrategyTap:
+		inst = brew.NewTappedFormula(s.packageOr(name), s.Tap, f.brewRun).At(pin)
+	case StrategyApt:
+		var ok bool
+		if inst, ok = f.systemPackage(name, s); !ok {
+			return nil
+		}
+	case StrategyDownload:
+		r := downloads[s.Source].At(pin)
+		r.SkipVerify = f.skipVerify
+		if f.get != nil {
+			r.Get, r.Evict = f.get, f.evict
+		}
+		r.Verifier = verify.WithFetcher(r.Verifier, f.fetch)
+		inst = r
+	case StrategyScript:
+		inst = f.extra(s.Source)
+	}
+	if inst.Name() != name {
+		inst = renamed{Installable: inst, name: name}
 	}
-	for name, tap := range darwinTappedFormulas {
-		r.Add(brew.NewTappedFormula(name, tap, f.brewRun))
+	return inst
+}
+
+// systemPackage builds the apt-strategy tool name for the distribution's
+// package manager, reporting false when that manager has no name for it.
+func (f RegistryFactory) systemPackage(name string, s Strategy) (Installable, bool) {
+	pin := f.pins[name]
+	switch f.SystemManager() {
+	case "apt":
+		return apt.NewPackage(s.packageOr(name), f.aptRun, f.aptQuery).At(pin), true
+	case "dnf":
+		if pkg, ok := dnf.Names[name]; ok {
+			return dnf.NewPackage(pkg, f.dnfRun, f.dnfQuery).At(pin), true
+		}
+	case "pacman":
+		if pkg, ok := pacman.Names[name]; ok {
+			return pacman.NewPackage(pkg, f.pacmanRun, f.pacmanQuery), true
+		}
 	}
+	return nil, false
 }
 
-// linuxAptPackages is the curated list of apt packages installed on Linux.
-var linuxAptPackages = []string{
-	"byobu", "fzf", "ripgrep", "bat",
-	"jq", "gh", "go", "node", "python",
+func (f RegistryFactory) extra(name string) Installable {
+	for _, e := range f.extras {
+		if e.Name() == name {
+			return e
+		}
+	}
+	return nil
 }
 
-func (f RegistryFactory) wireLinux(r *DevToolRegistry) {
-	r.Add(apt.NeovimAppImage{})
-	for _, name := range linuxAptPackages {
-		r.Add(apt.NewPackage(name, f.aptRun))
+func (f RegistryFactory) extraNames() []string {
+	names := make([]string, len(f.extras))
+	for i, e := range f.extras {
+		names[i] = e.Name()
 	}
+	return names
 }
 
+// renamed shows an Installable under its catalog name, e.g. the apt package
+// golang as "go".
+type renamed struct {
+	Installable
+	name string
+}
+
+func (r renamed) Name() string { return r.name }
+func (r renamed) Lock() string { return LockOf(r.Installable) }
+
+// The Batchable methods forward to the wrapped installable; BatchKey is
+// empty when it does not batch, so the rest are never called.
+func (r renamed) BatchKey() string { return batchKey(r.Installable) }
+func (r renamed) BatchArg() string { return r.Installable.(Batchable).BatchArg() }
+func (r renamed) InstallBatch(args []string, stdout, stderr io.Writer) error {
+	return r.Installable.(Batchable).InstallBatch(args, stdout, stderr)
+}
+func (r renamed) BatchPlan(args []string) []string {
+	return r.Installable.(Batchable).BatchPlan(args)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,253 @@
+// Package release resolves which release of a downloaded tool to install
+// from the project's GitHub releases: the latest, an exact tag, or the
+// newest within a range such as ">=0.10 <0.12".
+package release
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/download"
+)
+
+// DefaultAPI is the releases API Resolver queries unless APIEnv names
+// another, e.g. a local stand-in for tests or an air-gapped mirror.
+const (
+	DefaultAPI = "https://api.github.com"
+	APIEnv     = "MACHINE_SETUP_RELEASES_API"
+)
+
+// CacheTTL is how long a repository's list of releases is reused before it
+// is fetched again.
+const CacheTTL = time.Hour
+
+// Resolver picks release versions from the tags listed by API's
+// /repos/{owner}/{repo}/releases (the most recent hundred). Drafts,
+// prereleases and tags that are not versions, such as Neovim's "nightly",
+// are ignored. Each repository's list is cached as JSON in Cache, when set,
+// for TTL. Offline, the cached list is used however old, and never fetched.
+type Resolver struct {
+	API     string
+	Cache   string
+	TTL     time.Duration
+	Get     download.Getter
+	Now     func() time.Time
+	Offline bool
+}
+
+// NewResolver returns the production Resolver, caching in dir.
+func NewResolver(dir string) Resolver {
+	api := os.Getenv(APIEnv)
+	if api == "" {
+		api = DefaultAPI
+	}
+	return Resolver{API: api, Cache: dir, TTL: CacheTTL, Get: download.DefaultGetter(), Now: time.Now}
+}
+
+// Resolve returns the newest release of repo ("owner/name") that spec
+// allows, as a version without the tag's "v".
+func (r Resolver) Resolve(repo, spec string) (string, error) {
+	s, err := ParseSpec(spec)
+	if err != nil {
+		return "", err
+	}
+	tags, err := r.tags(repo)
+	if err != nil {
+		return "", fmt.Errorf("listing %s releases: %w", repo, err)
+	}
+	var best version
+	var found bool
+	for _, tag := range tags {
+		v, ok := parseVersion(tag)
+		if !ok || v.pre || !s.allows(v) {
+			continue
+		}
+		if !found || v.compare(best) > 0 {
+			best, found = v, true
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("no %s release matches %q", repo, spec)
+	}
+	return best.String(), nil
+}
+
+// cached is a repository's release tags as stored in the cache.
+type cached struct {
+	Fetched time.Time `json:"fetched"`
+	Tags    []string  `json:"tags"`
+}
+
+// tags lists repo's published release tags, from the cache when fresh.
+func (r Resolver) tags(repo string) ([]string, error) {
+	file := filepath.Join(r.Cache, strings.ReplaceAll(repo, "/", "_")+".json")
+	if r.Cache != "" {
+		var c cached
+		if data, err := os.ReadFile(file); err == nil && json.Unmarshal(data, &c) == nil && (r.Offline || r.now().Sub(c.Fetched) < r.TTL) {
+			return c.Tags, nil
+		}
+	}
+	if r.Offline {
+		return nil, errors.New("offline, and no list of releases is cached")
+	}
+	body, err := r.Get(strings.TrimSuffix(r.API, "/") + "/repos/" + repo + "/releases?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var releases []struct {
+		Tag        string `json:"tag_name"`
+		Draft      bool   `json:"draft"`
+		Prerelease bool   `json:"prerelease"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, 8<<20)).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("decoding releases: %w", err)
+	}
+	var tags []string
+	for _, rel := range releases {
+		if !rel.Draft && !rel.Prerelease {
+			tags = append(tags, rel.Tag)
+		}
+	}
+	if r.Cache != "" {
+		// A cache that cannot be written only costs a fetch next time.
+		if data, err := json.Marshal(cached{Fetched: r.now(), Tags: tags}); err == nil && os.MkdirAll(r.Cache, 0o755) == nil {
+			_ = os.WriteFile(file, data, 0o644)
+		}
+	}
+	return tags, nil
+}
+
+func (r Resolver) now() time.Time {
+	if r.Now == nil {
+		return time.Now()
+	}
+	return r.Now()
+}
+
+// IsSpec reports whether a configured version needs resolving: "latest" or
+// a range. An exact version such as "0.11.6" or "v0.11.6" does not.
+func IsSpec(v string) bool {
+	v = strings.TrimSpace(v)
+	return v == "latest" || strings.ContainsAny(v, "<>=")
+}
+
+// Spec is a parsed version spec: "latest", an exact version, or
+// space-separated constraints that must all hold, each an operator (>=, >,
+// <=, < or =) and a version of up to three parts.
+type Spec struct {
+	constraints []constraint
+}
+
+type constraint struct {
+	op string
+	v  version
+}
+
+// ParseSpec parses s.
+func ParseSpec(s string) (Spec, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return Spec{}, errors.New("empty version spec")
+	}
+	if len(fields) == 1 && fields[0] == "latest" {
+		return Spec{}, nil
+	}
+	var spec Spec
+	for _, f := range fields {
+		op := "="
+		for _, o := range []string{">=", "<=", ">", "<", "="} {
+			if rest, ok := strings.CutPrefix(f, o); ok {
+				op, f = o, rest
+				break
+			}
+		}
+		v, ok := parseVersion(f)
+		if !ok {
+			return Spec{}, fmt.Errorf("version spec %q: %q is not a version", s, f)
+		}
+		spec.constraints = append(spec.constraints, constraint{op, v})
+	}
+	return spec, nil
+}
+
+// Allows reports whether version v satisfies the spec.
+func (s Spec) Allows(v string) bool {
+	parsed, ok := parseVersion(v)
+	return ok && s.allows(parsed)
+}
+
+func (s Spec) allows(v version) bool {
+	for _, c := range s.constraints {
+		cmp := v.compare(c.v)
+		var ok bool
+		switch c.op {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "=":
+			ok = cmp == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// version is a release version: up to three numeric parts, missing ones
+// zero, and whether it carries a prerelease suffix such as "-rc1". raw is
+// how the tag spells it, without the "v".
+type version struct {
+	parts [3]int
+	pre   bool
+	raw   string
+}
+
+// parseVersion reads "v1.2.3", "1.2" or "1.2.3-rc1"; build metadata after
+// "+" is ignored.
+func parseVersion(s string) (version, bool) {
+	raw := strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(raw, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	nums := strings.Split(s, ".")
+	if len(nums) > 3 {
+		return version{}, false
+	}
+	v := version{raw: raw}
+	for i, n := range nums {
+		p, err := strconv.Atoi(n)
+		if err != nil || p < 0 {
+			return version{}, false
+		}
+		v.parts[i] = p
+	}
+	v.pre = hasPre && pre != ""
+	return v, true
+}
+
+func (v version) compare(o version) int {
+	for i := range v.parts {
+		if v.parts[i] != o.parts[i] {
+			if v.parts[i] < o.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v version) String() string { return v.raw }
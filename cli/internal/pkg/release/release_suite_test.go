@@ -0,0 +1,13 @@
+package release_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestReleaseSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "release Suite")
+}
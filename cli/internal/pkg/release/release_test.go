@@ -0,0 +1,123 @@
+package release_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/release"
+)
+
+const releases = `[
+  {"tag_name": "nightly", "prerelease": true},
+  {"tag_name": "stable"},
+  {"tag_name": "v0.12.0-rc1"},
+  {"tag_name": "v0.12.0", "draft": true},
+  {"tag_name": "v0.11.6"},
+  {"tag_name": "v0.11.5"},
+  {"tag_name": "v0.10.4"},
+  {"tag_name": "v0.9.5"}
+]`
+
+var _ = Describe("Resolver", func() {
+	var (
+		requested []string
+		fail      bool
+		now       time.Time
+		resolver  release.Resolver
+	)
+
+	BeforeEach(func() {
+		requested, fail, now = nil, false, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		resolver = release.Resolver{
+			API:   "http://127.0.0.1:8080/",
+			Cache: GinkgoT().TempDir(),
+			TTL:   time.Hour,
+			Get: func(url string) (io.ReadCloser, error) {
+				requested = append(requested, url)
+				if fail {
+					return nil, errors.New("connection refused")
+				}
+				return io.NopCloser(bytes.NewReader([]byte(releases))), nil
+			},
+			Now: func() time.Time { return now },
+		}
+	})
+
+	DescribeTable("picks the newest published release the spec allows",
+		func(spec, want string) {
+			Expect(resolver.Resolve("neovim/neovim", spec)).To(Equal(want))
+		},
+		Entry("latest", "latest", "0.11.6"),
+		Entry("range", ">=0.10 <0.11", "0.10.4"),
+		Entry("range ending at a release", ">0.9 <=0.11.5", "0.11.5"),
+		Entry("exact tag", "v0.9.5", "0.9.5"),
+		Entry("exact version", "0.10.4", "0.10.4"),
+	)
+
+	It("asks the configured API", func() {
+		_, err := resolver.Resolve("neovim/neovim", "latest")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requested).To(Equal([]string{"http://127.0.0.1:8080/repos/neovim/neovim/releases?per_page=100"}))
+	})
+
+	It("fails when no release matches", func() {
+		_, err := resolver.Resolve("neovim/neovim", ">=0.12")
+
+		Expect(err).To(MatchError(`no neovim/neovim release matches ">=0.12"`))
+	})
+
+	It("reuses the cached list until it is older than the TTL", func() {
+		Expect(resolver.Resolve("neovim/neovim", "latest")).To(Equal("0.11.6"))
+		fail = true
+		now = now.Add(30 * time.Minute)
+
+		Expect(resolver.Resolve("neovim/neovim", "<0.11")).To(Equal("0.10.4"))
+		Expect(requested).To(HaveLen(1))
+
+		now = now.Add(time.Hour)
+		_, err := resolver.Resolve("neovim/neovim", "latest")
+		Expect(err).To(MatchError("listing neovim/neovim releases: connection refused"))
+	})
+
+	It("uses the cached list however old when offline, and never fetches", func() {
+		Expect(resolver.Resolve("neovim/neovim", "latest")).To(Equal("0.11.6"))
+		resolver.Offline, now = true, now.Add(30*24*time.Hour)
+
+		Expect(resolver.Resolve("neovim/neovim", "<0.11")).To(Equal("0.10.4"))
+		_, err := resolver.Resolve("jesseduffield/lazygit", "latest")
+
+		Expect(err).To(MatchError("listing jesseduffield/lazygit releases: offline, and no list of releases is cached"))
+		Expect(requested).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("ParseSpec", func() {
+	It("rejects a constraint that is not a version", func() {
+		_, err := release.ParseSpec(">=0.10 <next")
+
+		Expect(err).To(MatchError(`version spec ">=0.10 <next": "next" is not a version`))
+	})
+
+	It("checks every constraint", func() {
+		spec, err := release.ParseSpec(">=0.10 <0.12")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec.Allows("0.11.6")).To(BeTrue())
+		Expect(spec.Allows("v0.10.0")).To(BeTrue())
+		Expect(spec.Allows("0.12.0")).To(BeFalse())
+		Expect(spec.Allows("0.9.5")).To(BeFalse())
+	})
+
+	It("tells specs from exact versions", func() {
+		Expect(release.IsSpec("latest")).To(BeTrue())
+		Expect(release.IsSpec(">=0.10 <0.12")).To(BeTrue())
+		Expect(release.IsSpec("0.11.6")).To(BeFalse())
+		Expect(release.IsSpec("1.22")).To(BeFalse())
+	})
+})
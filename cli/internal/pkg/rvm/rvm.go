@@ -1,20 +1,41 @@
 // Package rvm provides the Ruby Version Manager installer. RVM is not in
-// brew; its canonical install is the official curl-pipe script at get.rvm.io.
-// This package wraps that install so it composes alongside brew/apt entries
-// in the dev-tool registry via the pkg.Installable interface.
+// brew; its canonical install is the official rvm-installer script (what
+// get.rvm.io serves), which its maintainers sign. This package wraps that
+// install so it composes alongside brew/apt entries in the dev-tool registry
+// via the pkg.Installable interface.
 package rvm
 
 import (
+	"fmt"
 	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/script"
+	"github.com/cloudwalk/machine-setup/internal/pkg/verify"
 )
 
-// installScript is the official RVM bootstrap command. Piped through bash with
-// the `stable` channel — same as the canonical instructions on rvm.io.
-const installScript = `\curl -sSL https://get.rvm.io | bash -s stable`
+// installerURL is the official RVM bootstrap script, run with the `stable`
+// channel as in the canonical instructions on rvm.io. Its detached signature
+// is at installerURL + ".asc".
+const installerURL = "https://raw.githubusercontent.com/rvm/rvm/master/binscripts/rvm-installer"
+
+// bootstrap is the RVM bootstrap as a checked script: it runs only once its
+// signature verifies against RVM's pinned keys.
+func bootstrap(trust script.Trust) script.Script {
+	return script.Script{
+		Name:      "rvm",
+		URL:       installerURL,
+		Shell:     "bash",
+		Args:      []string{"stable"},
+		Signature: installerURL + ".asc",
+		Verifier:  verify.RVM,
+		Trust:     trust,
+	}
+}
 
-// Installer installs RVM by running its official curl-pipe bootstrap.
+// Installer installs RVM by running its official bootstrap script.
 type Installer struct {
 	// Dir is the path that signals "already installed" (typically ~/.rvm).
 	Dir string
@@ -38,13 +59,37 @@ func (i Installer) Install(stdout, stderr io.Writer) error {
 	return i.Runner(stdout, stderr)
 }
 
-// DefaultRunner returns the production Runner: a bash pipe of the official
-// RVM install script with the `stable` channel.
-func DefaultRunner() func(stdout, stderr io.Writer) error {
-	return func(stdout, stderr io.Writer) error {
-		cmd := exec.Command("bash", "-c", installScript)
-		cmd.Stdout = stdout
-		cmd.Stderr = stderr
-		return cmd.Run()
+// Plan reports what DefaultRunner does: fetch the bootstrap, check its
+// signature, run it. Or that it is skipped because Dir exists.
+func (i Installer) Plan() []string {
+	if _, err := os.Stat(i.Dir); err == nil {
+		return []string{fmt.Sprintf("skip: %s already exists", i.Dir)}
+	}
+	return bootstrap(script.Trust{}).Plan()
+}
+
+// Detect reports RVM installed when Dir exists, with the version from the
+// VERSION file RVM keeps at its root.
+func (i Installer) Detect() (bool, string, error) {
+	if _, err := os.Stat(i.Dir); err != nil {
+		return false, "", nil
+	}
+	version, err := os.ReadFile(filepath.Join(i.Dir, "VERSION"))
+	if err != nil {
+		return true, "", nil
 	}
+	return true, strings.TrimSpace(string(version)), nil
+}
+
+// Version is empty: the stable channel is wanted, whatever it currently is.
+func (Installer) Version() string { return "" }
+
+// DefaultRunner returns the production Runner, which downloads the RVM
+// bootstrap and its signature with fetch, refuses to run it unless the
+// signature checks out (or trust skips the check), and runs it with the
+// `stable` channel.
+func DefaultRunner(trust script.Trust, fetch verify.Fetcher) func(stdout, stderr io.Writer) error {
+	s := bootstrap(trust)
+	s.Fetch = fetch
+	return s.Run
 }
@@ -55,3 +55,41 @@ var _ = Describe("rvm.Installer.Install", func() {
 		Expect(gotStderr).To(BeIdenticalTo(io.Writer(stderr)))
 	})
 })
+
+var _ = Describe("rvm.Installer.Detect", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = filepath.Join(GinkgoT().TempDir(), ".rvm")
+	})
+
+	It("reports not installed when Dir is missing", func() {
+		installed, _, err := rvm.NewInstaller(dir, nil).Detect()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeFalse())
+	})
+
+	It("reads the version from Dir/VERSION", func() {
+		Expect(os.MkdirAll(dir, 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.29.12\n"), 0o644)).To(Succeed())
+
+		installed, version, err := rvm.NewInstaller(dir, nil).Detect()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeTrue())
+		Expect(version).To(Equal("1.29.12"))
+	})
+})
+
+var _ = Describe("rvm.Installer.Plan", func() {
+	It("fetches the bootstrap and checks its signature before running it", func() {
+		dir := filepath.Join(GinkgoT().TempDir(), ".rvm")
+
+		Expect(rvm.NewInstaller(dir, nil).Plan()).To(Equal([]string{
+			"download https://raw.githubusercontent.com/rvm/rvm/master/binscripts/rvm-installer",
+			"check its signature https://raw.githubusercontent.com/rvm/rvm/master/binscripts/rvm-installer.asc",
+			"bash rvm-installer stable",
+		}))
+	})
+})
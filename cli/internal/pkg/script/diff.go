@@ -0,0 +1,79 @@
+package script
+
+import (
+	"strings"
+)
+
+// context is how many unchanged lines Diff shows around each change.
+const context = 3
+
+// Diff returns the changes from old to new, line by line: removed lines
+// marked "-", added ones "+", each run of changes with up to three unchanged
+// lines around it, and "..." where unchanged lines are left out.
+func Diff(old, new []byte) string {
+	a, b := lines(old), lines(new)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, "  "+a[i])
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, "- "+a[i])
+			i++
+		default:
+			ops = append(ops, "+ "+b[j])
+			j++
+		}
+	}
+
+	// Keep each change and the context around it.
+	keep := make([]bool, len(ops))
+	for k, op := range ops {
+		if op[0] != ' ' {
+			for c := max(0, k-context); c <= min(len(ops)-1, k+context); c++ {
+				keep[c] = true
+			}
+		}
+	}
+	var out strings.Builder
+	skipped := false
+	for k, op := range ops {
+		if !keep[k] {
+			skipped = true
+			continue
+		}
+		if skipped && out.Len() > 0 {
+			out.WriteString("...\n")
+		}
+		skipped = false
+		out.WriteString(op + "\n")
+	}
+	return out.String()
+}
+
+func lines(data []byte) []string {
+	s := strings.TrimSuffix(string(data), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,223 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/verify"
+)
+
+// Script is an install script served over HTTPS, checked against SHA256,
+// Signature or Trust before Run runs it from a file.
+type Script struct {
+	// Name identifies the script's approved copy, e.g. "ohmyzsh".
+	Name  string
+	URL   string
+	Shell string // sh or bash
+	Args  []string
+	// Env is added to the controlled environment the script runs in (see
+	// Environ).
+	Env       []string
+	SHA256    string
+	Signature string
+	Verifier  verify.Verifier
+	Trust     Trust
+	// Fetch defaults to verify.DefaultFetcher, Exec to DefaultExecutor.
+	Fetch verify.Fetcher
+	Exec  Executor
+}
+
+// Trust approves scripts that are neither pinned nor signed.
+type Trust struct {
+	Approved   string                     // directory of the copies last run, as <Name>.sh
+	Approve    func(Review) (bool, error) // nil approves nothing
+	SkipVerify bool                       // --insecure-skip-verify
+}
+
+// Review is what Approve is shown of a script that is not pinned: the Diff
+// from the copy approved before, or, the First time, from nothing.
+type Review struct {
+	Name   string
+	URL    string
+	SHA256 string
+	Diff   string
+	First  bool
+}
+
+// Executor runs the script saved at file with shell, args and the complete
+// environment env.
+type Executor func(shell, file string, args, env []string, stdout, stderr io.Writer) error
+
+// DefaultExecutor returns the production Executor.
+func DefaultExecutor() Executor {
+	return func(shell, file string, args, env []string, stdout, stderr io.Writer) error {
+		cmd := exec.Command(shell, append([]string{file}, args...)...)
+		cmd.Env = env
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		return cmd.Run()
+	}
+}
+
+// passed are the variables Environ keeps from setup's own environment:
+// enough for a script to find its tools, the user's home and a proxy, and
+// nothing that holds credentials, such as AWS_* or GITHUB_TOKEN.
+var passed = []string{
+	"HOME", "USER", "LOGNAME", "PATH", "SHELL", "TERM", "LANG", "TMPDIR",
+	"http_proxy", "https_proxy", "no_proxy", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+}
+
+// Environ returns the environment scripts run in: the passed variables and
+// any LC_* locale settings from os.Environ, then extra.
+func Environ(extra []string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if slices.Contains(passed, name) || strings.HasPrefix(name, "LC_") {
+			env = append(env, kv)
+		}
+	}
+	return append(env, extra...)
+}
+
+// Run downloads, checks and runs the script.
+func (s Script) Run(stdout, stderr io.Writer) error {
+	fetch := s.Fetch
+	if fetch == nil {
+		fetch = verify.DefaultFetcher()
+	}
+	data, err := fetch(s.URL)
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", s.URL, err)
+	}
+	if err := s.check(data, fetch, stdout, stderr); err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp("", "machine-setup-"+s.Name+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	file := filepath.Join(tmp, s.file())
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return err
+	}
+	run := s.Exec
+	if run == nil {
+		run = DefaultExecutor()
+	}
+	return run(s.Shell, file, s.Args, Environ(s.Env), stdout, stderr)
+}
+
+// check decides whether data, as downloaded from URL, may run.
+func (s Script) check(data []byte, fetch verify.Fetcher, stdout, stderr io.Writer) error {
+	if s.Trust.SkipVerify {
+		verify.Skipped(stderr, s.URL)
+		return nil
+	}
+	switch {
+	case s.SHA256 != "":
+		if got := sha256Hex(data); !strings.EqualFold(got, s.SHA256) {
+			return fmt.Errorf("%s has SHA-256 %s, want %s; not running it", s.URL, got, s.SHA256)
+		}
+		// Kept so that bumping the pin shows what changed since.
+		if s.Trust.Approved != "" {
+			_ = s.keep(data)
+		}
+		return nil
+	case s.Signature != "":
+		if s.Verifier == nil {
+			return fmt.Errorf("no key to check the signature of %s", s.URL)
+		}
+		sig, err := fetch(s.Signature)
+		if err != nil {
+			return fmt.Errorf("downloading %s: %w", s.Signature, err)
+		}
+		if err := verify.WithFetcher(s.Verifier, fetch).Verify(data, sig); err != nil {
+			return fmt.Errorf("%s does not match its signature %s; not running it: %w", s.URL, s.Signature, err)
+		}
+		return nil
+	default:
+		return s.approve(data, stdout)
+	}
+}
+
+// approve checks data against the approved copy, asking Approve about a
+// first download or a change, and records data once approved.
+func (s Script) approve(data []byte, stdout io.Writer) error {
+	if s.Trust.Approved == "" {
+		return fmt.Errorf("%s is neither pinned nor signed, and there is nowhere to keep an approved copy", s.URL)
+	}
+	old, err := os.ReadFile(filepath.Join(s.Trust.Approved, s.Name+".sh"))
+	switch {
+	case err == nil && bytes.Equal(old, data):
+		return nil
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("reading the approved %s script: %w", s.Name, err)
+	}
+	review := Review{Name: s.Name, URL: s.URL, SHA256: sha256Hex(data), Diff: Diff(old, data), First: err != nil}
+	ok := false
+	if s.Trust.Approve != nil {
+		if ok, err = s.Trust.Approve(review); err != nil {
+			return err
+		}
+	}
+	switch {
+	case !ok && review.First:
+		return fmt.Errorf("%s is not pinned and has not been approved; not running it (review it in a terminal, or pass --yes, to approve it)", s.URL)
+	case !ok:
+		return fmt.Errorf("%s has changed since it was approved; not running it (review the change in a terminal to approve it)", s.URL)
+	}
+	fmt.Fprintf(stdout, "Approved %s (SHA-256 %s); pin it in internal/pkg/script/pins.sha256 to stop being asked\n", s.URL, review.SHA256)
+	return s.keep(data)
+}
+
+// keep records data as the script's approved copy.
+func (s Script) keep(data []byte) error {
+	if err := os.MkdirAll(s.Trust.Approved, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.Trust.Approved, s.Name+".sh"), data, 0o644)
+}
+
+// Plan describes Run: the download, its check, and the command.
+func (s Script) Plan() []string {
+	plan := []string{"download " + s.URL}
+	switch {
+	case s.Trust.SkipVerify:
+		plan = append(plan, "do NOT check it (--insecure-skip-verify)")
+	case s.SHA256 != "":
+		plan = append(plan, "check its SHA-256 is "+s.SHA256)
+	case s.Signature != "":
+		plan = append(plan, "check its signature "+s.Signature)
+	default:
+		plan = append(plan, "not pinned: ask before running it, showing any change from the copy approved before")
+	}
+	cmd := append(slices.Clone(s.Env), s.Shell, s.file())
+	return append(plan, strings.Join(append(cmd, s.Args...), " "))
+}
+
+// file is the script's file name: the URL's last element.
+func (s Script) file() string {
+	name := path.Base(s.URL)
+	if name == "/" || name == "." {
+		return s.Name + ".sh"
+	}
+	return name
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,232 @@
+package script_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/script"
+)
+
+// verifierFunc is a verify.Verifier from a function.
+type verifierFunc func(data, sig []byte) error
+
+func (f verifierFunc) Verify(data, sig []byte) error { return f(data, sig) }
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+var _ = Describe("Script", func() {
+	const url = "https://example.com/install.sh"
+	var (
+		served   map[string]string
+		ran      []string
+		env      []string
+		approved string
+		reviews  []script.Review
+		s        script.Script
+		stdout   *bytes.Buffer
+		stderr   *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		served = map[string]string{url: "echo v1\n"}
+		ran, env, reviews = nil, nil, nil
+		approved = GinkgoT().TempDir()
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		s = script.Script{
+			Name:  "tool",
+			URL:   url,
+			Shell: "sh",
+			Args:  []string{"-y"},
+			Env:   []string{"TOOL_QUIET=1"},
+			Trust: script.Trust{Approved: approved, Approve: func(r script.Review) (bool, error) {
+				reviews = append(reviews, r)
+				return true, nil
+			}},
+			Fetch: func(u string) ([]byte, error) {
+				if body, ok := served[u]; ok {
+					return []byte(body), nil
+				}
+				return nil, fmt.Errorf("GET %s: HTTP 404", u)
+			},
+			Exec: func(shell, file string, args, e []string, _, _ io.Writer) error {
+				data, err := os.ReadFile(file)
+				Expect(err).NotTo(HaveOccurred())
+				ran = append(ran, fmt.Sprintf("%s %s %v: %s", shell, filepath.Base(file), args, data))
+				env = e
+				return nil
+			},
+		}
+	})
+
+	approvedCopy := func() string {
+		data, err := os.ReadFile(filepath.Join(approved, "tool.sh"))
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("shows a first download whole and runs the file once approved", func() {
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+
+		Expect(reviews).To(Equal([]script.Review{{
+			Name: "tool", URL: url, SHA256: sha256Hex("echo v1\n"), Diff: "+ echo v1\n", First: true,
+		}}))
+		Expect(ran).To(Equal([]string{"sh install.sh [-y]: echo v1\n"}))
+		Expect(approvedCopy()).To(Equal("echo v1\n"))
+		Expect(stdout.String()).To(ContainSubstring("Approved " + url))
+	})
+
+	It("refuses a first download nobody approves, as without a terminal", func() {
+		s.Trust.Approve = nil
+
+		err := s.Run(stdout, stderr)
+
+		Expect(err).To(MatchError(ContainSubstring(url + " is not pinned and has not been approved; not running it")))
+		Expect(ran).To(BeEmpty())
+		Expect(filepath.Join(approved, "tool.sh")).NotTo(BeAnExistingFile())
+	})
+
+	It("runs an unchanged script without asking", func() {
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+		s.Trust.Approve = func(script.Review) (bool, error) { panic("must not ask about an unchanged script") }
+
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+		Expect(ran).To(HaveLen(2))
+	})
+
+	It("refuses a changed script nobody approves", func() {
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+		served[url] = "echo v2\n"
+		s.Trust.Approve = nil
+
+		err := s.Run(stdout, stderr)
+
+		Expect(err).To(MatchError(ContainSubstring(url + " has changed since it was approved; not running it")))
+		Expect(ran).To(HaveLen(1))
+		Expect(approvedCopy()).To(Equal("echo v1\n"))
+	})
+
+	It("shows the change and runs it once approved", func() {
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+		served[url] = "echo v2\n"
+
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+
+		Expect(reviews).To(HaveLen(2))
+		Expect(reviews[1].First).To(BeFalse())
+		Expect(reviews[1].Diff).To(Equal("- echo v1\n+ echo v2\n"))
+		Expect(ran).To(HaveLen(2))
+		Expect(approvedCopy()).To(Equal("echo v2\n"))
+	})
+
+	It("checks a pinned SHA-256 without asking, and never runs anything else", func() {
+		s.SHA256 = sha256Hex("echo v1\n")
+		s.Trust.Approve = nil
+
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+
+		served[url] = "echo tampered\n"
+		Expect(s.Run(stdout, stderr)).To(MatchError(ContainSubstring("want " + s.SHA256 + "; not running it")))
+		Expect(ran).To(HaveLen(1))
+	})
+
+	It("shows the change from the pinned copy when the pin is bumped", func() {
+		s.SHA256 = sha256Hex("echo v1\n")
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+		Expect(reviews).To(BeEmpty())
+
+		s.SHA256 = ""
+		served[url] = "echo v2\n"
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+
+		Expect(reviews).To(HaveLen(1))
+		Expect(reviews[0].Diff).To(Equal("- echo v1\n+ echo v2\n"))
+		Expect(stdout.String()).To(ContainSubstring("SHA-256 " + sha256Hex("echo v2\n") + "); pin it in internal/pkg/script/pins.sha256"))
+	})
+
+	It("checks a signature when the script is signed", func() {
+		served[url+".asc"] = "signature"
+		s.Signature = url + ".asc"
+		s.Verifier = verifierFunc(func(data, sig []byte) error {
+			if string(data) != "echo v1\n" || string(sig) != "signature" {
+				return errors.New("bad signature")
+			}
+			return nil
+		})
+
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+
+		served[url] = "echo tampered\n"
+		Expect(s.Run(stdout, stderr)).To(MatchError(ContainSubstring("does not match its signature " + url + ".asc; not running it: bad signature")))
+		Expect(ran).To(HaveLen(1))
+	})
+
+	It("runs anything unchecked with SkipVerify, warning that it does", func() {
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+		served[url] = "echo v2\n"
+		s.Trust.SkipVerify = true
+
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+
+		Expect(ran).To(HaveLen(2))
+		Expect(stderr.String()).To(ContainSubstring("WARNING: NOT verifying the signature of " + url))
+		Expect(approvedCopy()).To(Equal("echo v1\n"))
+	})
+
+	It("runs the script without setup's credentials in its environment", func() {
+		GinkgoT().Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+		GinkgoT().Setenv("HOME", "/home/dev")
+
+		Expect(s.Run(stdout, stderr)).To(Succeed())
+
+		Expect(env).To(ContainElements("HOME=/home/dev", "TOOL_QUIET=1"))
+		Expect(env).NotTo(ContainElement(HavePrefix("AWS_SECRET_ACCESS_KEY=")))
+	})
+
+	It("plans the download, its check and the command", func() {
+		Expect(s.Plan()).To(Equal([]string{
+			"download " + url,
+			"not pinned: ask before running it, showing any change from the copy approved before",
+			"TOOL_QUIET=1 sh install.sh -y",
+		}))
+	})
+})
+
+var _ = Describe("BuiltIn", func() {
+	It("pins a script only at a commit, never at a branch", func() {
+		for _, i := range script.BuiltIn("/home/dev", script.Script{}) {
+			if i.Script.SHA256 != "" {
+				Expect(i.Script.URL).NotTo(MatchRegexp(`/(main|master|HEAD)/`), i.Tool)
+			}
+		}
+	})
+
+	It("runs golangci-lint's installer at its pinned commit", func() {
+		for _, i := range script.BuiltIn("/home/dev", script.Script{}) {
+			if i.Tool == "golangci-lint" {
+				Expect(i.Script.URL).To(Equal("https://raw.githubusercontent.com/golangci/golangci-lint/114493f9b3e7257d29e4130f2b4a4aadefbb6845/install.sh"))
+				Expect(i.Script.SHA256).To(Equal("1022ddb4d87ed252350ed03fc9677e250a4ae95cc6bcd4658c2a20a8a23d390f"))
+			}
+		}
+	})
+})
+
+var _ = Describe("Diff", func() {
+	It("shows changed lines with the unchanged ones around them", func() {
+		old := []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n")
+		new := []byte("a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n")
+
+		Expect(script.Diff(old, new)).To(Equal("  a\n- b\n+ B\n  c\n  d\n  e\n...\n  h\n  i\n  j\n+ k\n"))
+	})
+})
@@ -0,0 +1,133 @@
+// Package script installs tools whose upstream install is a shell script
+// served over HTTPS, such as rustup's sh.rustup.rs or ghcup's bootstrap. The
+// catalog names them with the script strategy, typically as the fallback
+// after a package manager. Scripts are downloaded and checked before they
+// run (see Script); oh-my-zsh's and RVM's installers go through the same.
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Installer runs Script to install Tool. The tool counts as installed when
+// Bin is on PATH or in one of Dirs, where scripts that install into the home
+// directory put it before the shell picks it up.
+type Installer struct {
+	Tool   string
+	Script Script
+	Bin    string
+	Dirs   []string
+}
+
+// Name is the tool's name, which is also the catalog's script source.
+func (i Installer) Name() string { return i.Tool }
+
+// Install downloads, checks and runs the script.
+func (i Installer) Install(stdout, stderr io.Writer) error {
+	return i.Script.Run(stdout, stderr)
+}
+
+// Plan is the script's plan.
+func (i Installer) Plan() []string { return i.Script.Plan() }
+
+// Detect finds Bin and asks it for --version, reporting the first version
+// number in the output.
+func (i Installer) Detect() (bool, string, error) {
+	path := i.find()
+	if path == "" {
+		return false, "", nil
+	}
+	out, err := exec.Command(path, "--version").CombinedOutput()
+	if err != nil {
+		return true, "", nil
+	}
+	return true, versionPattern.FindString(string(out)), nil
+}
+
+// Version is empty: scripts install the current release.
+func (Installer) Version() string { return "" }
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+func (i Installer) find() string {
+	if path, err := exec.LookPath(i.Bin); err == nil {
+		return path
+	}
+	for _, dir := range i.Dirs {
+		path := filepath.Join(dir, i.Bin)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// pins pins scripts at a commit, one "<sha256>  <name> <url>" line each
+// (see the file's header).
+//
+//go:embed pins.sha256
+var pins []byte
+
+// Pinned returns s at the URL and SHA-256 pins.sha256 has for s.Name: the
+// script at a commit rather than a branch, checked against its hash. A
+// script with no pin is returned as it is, to be approved when it runs.
+func (s Script) Pinned() Script {
+	sc := bufio.NewScanner(bytes.NewReader(pins))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 3 && len(fields[0]) == sha256.Size*2 && fields[1] == s.Name {
+			s.SHA256, s.URL = fields[0], fields[2]
+		}
+	}
+	return s
+}
+
+// BuiltIn returns the install scripts the catalog can name, for a user
+// whose home directory is home. Each script is base (its Trust, Fetch and
+// Exec) with the script's own shell, arguments and environment, pinned
+// (see Pinned). The URLs here are where each script is published, used only
+// until it is pinned.
+func BuiltIn(home string, base Script) []Installer {
+	localBin := filepath.Join(home, ".local", "bin")
+	script := func(name, url, shell string, args, env []string) Script {
+		s := base
+		s.Name, s.URL, s.Shell, s.Args, s.Env = name, url, shell, args, env
+		return s.Pinned()
+	}
+	return []Installer{
+		{
+			Tool:   "rustup",
+			Script: script("rustup", "https://sh.rustup.rs", "sh", []string{"-y", "--no-modify-path"}, nil),
+			Bin:    "rustup", Dirs: []string{filepath.Join(home, ".cargo", "bin")},
+		},
+		{
+			Tool:   "ghcup",
+			Script: script("ghcup", "https://get-ghcup.haskell.org", "sh", nil, []string{"BOOTSTRAP_HASKELL_NONINTERACTIVE=1"}),
+			Bin:    "ghcup", Dirs: []string{filepath.Join(home, ".ghcup", "bin")},
+		},
+		{
+			Tool:   "k3d",
+			Script: script("k3d", "https://raw.githubusercontent.com/k3d-io/k3d/main/install.sh", "bash", nil, nil),
+			Bin:    "k3d",
+		},
+		{
+			Tool:   "lazydocker",
+			Script: script("lazydocker", "https://raw.githubusercontent.com/jesseduffield/lazydocker/master/scripts/install_update_linux.sh", "bash", nil, []string{"DIR=" + localBin}),
+			Bin:    "lazydocker", Dirs: []string{localBin},
+		},
+		{
+			Tool:   "golangci-lint",
+			Script: script("golangci-lint", "https://raw.githubusercontent.com/golangci/golangci-lint/HEAD/install.sh", "sh", []string{"-b", localBin}, nil),
+			Bin:    "golangci-lint", Dirs: []string{localBin},
+		},
+	}
+}
@@ -0,0 +1,13 @@
+package script_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestScriptSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "script Suite")
+}
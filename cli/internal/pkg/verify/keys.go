@@ -0,0 +1,28 @@
+package verify
+
+// The signing keys setup trusts, pinned by fingerprint. Each key is a file
+// in keys/ (see keys/README.md for where each came from) or, until it is
+// committed, fetched from its publisher, and refused unless it has the
+// fingerprint here.
+var (
+	// HashiCorp signs the SHA256SUMS file of every release on
+	// releases.hashicorp.com.
+	HashiCorp = GPG{
+		Keys:         []string{"hashicorp.asc"},
+		Fingerprints: []string{"C874011F0AB405110D02105534365D9472D7468F"},
+	}
+
+	// RVM's maintainers, Michal Papis and Piotr Kuczynski, sign the
+	// rvm-installer script.
+	RVM = GPG{
+		Keys: []string{"mpapis.asc", "pkuczynski.asc"},
+		URLs: map[string]string{
+			"mpapis.asc":     "https://rvm.io/mpapis.asc",
+			"pkuczynski.asc": "https://rvm.io/pkuczynski.asc",
+		},
+		Fingerprints: []string{
+			"409B6B1796C275462A1703113804BB82D39DC0E3",
+			"7D2BAF1CF37B13E2069D6956105BD0E739499BDB",
+		},
+	}
+)
@@ -0,0 +1,129 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseKey reads an OpenPGP public key block, ASCII-armored or binary, and
+// returns it in binary form — what apt and gpgv want in a keyring — along with
+// the fingerprint of each primary key in it (subkeys are left out: they are
+// vouched for by their primary key).
+func ParseKey(data []byte) ([]byte, []string, error) {
+	raw := data
+	if bytes.Contains(data, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+		var err error
+		if raw, err = dearmor(data); err != nil {
+			return nil, nil, err
+		}
+	}
+	var fprs []string
+	for rest := raw; len(rest) > 0; {
+		tag, body, next, err := packet(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		if tag == 6 { // public key
+			fpr, err := fingerprint(body)
+			if err != nil {
+				return nil, nil, err
+			}
+			fprs = append(fprs, fpr)
+		}
+		rest = next
+	}
+	if len(fprs) == 0 {
+		return nil, nil, errors.New("no OpenPGP public key found")
+	}
+	return raw, fprs, nil
+}
+
+// dearmor decodes the first armored block in data.
+func dearmor(data []byte) ([]byte, error) {
+	var b64 strings.Builder
+	inBlock, inBody := false, false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "-----BEGIN PGP PUBLIC KEY BLOCK-----":
+			inBlock = true
+		case !inBlock:
+		case strings.HasPrefix(line, "-----END"):
+			return base64.StdEncoding.DecodeString(b64.String())
+		case !inBody:
+			inBody = line == "" // armor headers end at a blank line
+		case strings.HasPrefix(line, "="): // CRC24 checksum
+		default:
+			b64.WriteString(line)
+		}
+	}
+	return nil, errors.New("unterminated PGP armor")
+}
+
+// packet splits the first OpenPGP packet off b (RFC 9580 section 4.2).
+func packet(b []byte) (tag int, body, rest []byte, err error) {
+	if b[0]&0x80 == 0 {
+		return 0, nil, nil, errors.New("malformed OpenPGP packet")
+	}
+	var n, hdr int
+	if b[0]&0x40 != 0 { // new format
+		tag = int(b[0] & 0x3f)
+		switch {
+		case len(b) < 2:
+		case b[1] < 192:
+			n, hdr = int(b[1]), 2
+		case b[1] < 224 && len(b) >= 3:
+			n, hdr = (int(b[1])-192)<<8+int(b[2])+192, 3
+		case b[1] == 255 && len(b) >= 6:
+			n, hdr = int(binary.BigEndian.Uint32(b[2:6])), 6
+		}
+	} else {
+		tag = int(b[0]>>2) & 0x0f
+		switch lt := b[0] & 3; {
+		case lt == 0 && len(b) >= 2:
+			n, hdr = int(b[1]), 2
+		case lt == 1 && len(b) >= 3:
+			n, hdr = int(binary.BigEndian.Uint16(b[1:3])), 3
+		case lt == 2 && len(b) >= 5:
+			n, hdr = int(binary.BigEndian.Uint32(b[1:5])), 5
+		}
+	}
+	if hdr == 0 || n < 0 || hdr+n > len(b) {
+		return 0, nil, nil, errors.New("truncated or unsupported OpenPGP packet")
+	}
+	return tag, b[hdr : hdr+n], b[hdr+n:], nil
+}
+
+// fingerprint computes a public key packet's fingerprint: SHA-1 for v4 keys,
+// SHA-256 for v6 ones.
+func fingerprint(body []byte) (string, error) {
+	if len(body) == 0 {
+		return "", errors.New("empty OpenPGP key packet")
+	}
+	switch body[0] {
+	case 4:
+		h := sha1.New()
+		h.Write([]byte{0x99, byte(len(body) >> 8), byte(len(body))})
+		h.Write(body)
+		return strings.ToUpper(hex.EncodeToString(h.Sum(nil))), nil
+	case 6:
+		h := sha256.New()
+		h.Write([]byte{0x9b})
+		_ = binary.Write(h, binary.BigEndian, uint32(len(body)))
+		h.Write(body)
+		return strings.ToUpper(hex.EncodeToString(h.Sum(nil))), nil
+	}
+	return "", fmt.Errorf("unsupported OpenPGP key version %d", body[0])
+}
+
+// NormalizeFingerprint uppercases fpr and drops the spaces gpg prints it with.
+func NormalizeFingerprint(fpr string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(fpr), ""))
+}
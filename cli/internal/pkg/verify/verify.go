@@ -0,0 +1,187 @@
+// Package verify checks detached signatures on what setup downloads and runs
+// — release checksum files, installer scripts — against signing keys
+// committed to this repository (keys/, embedded in the binary), or fetched
+// from their publisher until they are, and pinned by fingerprint (keys.go). A signature that does not verify aborts that
+// install; --insecure-skip-verify turns the checks off, loudly.
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Verifier checks a detached signature over data.
+type Verifier interface {
+	Verify(data, sig []byte) error
+}
+
+// Fetcher downloads the document at url.
+type Fetcher func(url string) ([]byte, error)
+
+// DefaultFetcher returns the production Fetcher, for keys, signatures and
+// scripts: small documents, so anything over 1 MiB is refused.
+func DefaultFetcher() Fetcher {
+	client := &http.Client{Timeout: 30 * time.Second}
+	return func(url string) ([]byte, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: HTTP %d", url, resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > 1<<20 {
+			return nil, fmt.Errorf("GET %s: larger than 1 MiB", url)
+		}
+		return data, nil
+	}
+}
+
+// embedded holds the armored public keys in keys/, which are never fetched.
+//
+//go:embed keys
+var embedded embed.FS
+
+// GPG verifies OpenPGP signatures with gpgv. The signers' public keys are
+// the armored files Keys names in KeyFS or, for one not there, fetched from
+// its entry in URLs, and each must have one of Fingerprints: a key replaced
+// by one of another key is refused. KeyFS defaults to the keys embedded from
+// keys/, Fetch to DefaultFetcher, Gpgv to gpgv on PATH.
+type GPG struct {
+	Keys         []string
+	URLs         map[string]string
+	Fingerprints []string
+	KeyFS        fs.FS
+	Fetch        Fetcher
+	Gpgv         string
+}
+
+// Verify checks sig over data, requiring the signing key's primary key to be
+// one of the pinned ones.
+func (g GPG) Verify(data, sig []byte) error {
+	tmp, err := os.MkdirTemp("", "machine-setup-verify-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	keyring, err := g.keyring()
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{"keyring.gpg": keyring, "data": data, "data.sig": sig}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmp, name), content, 0o600); err != nil {
+			return err
+		}
+	}
+	gpgv := g.Gpgv
+	if gpgv == "" {
+		gpgv = "gpgv"
+	}
+	var status, stderr bytes.Buffer
+	cmd := exec.Command(gpgv, "--status-fd", "1", "--keyring", filepath.Join(tmp, "keyring.gpg"),
+		filepath.Join(tmp, "data.sig"), filepath.Join(tmp, "data"))
+	cmd.Stdout, cmd.Stderr = &status, &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gpgv: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	// VALIDSIG <signing key> ... <primary key>: the last field names the
+	// primary key, which is what the fingerprints pin.
+	sc := bufio.NewScanner(&status)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) > 2 && fields[0] == "[GNUPG:]" && fields[1] == "VALIDSIG" && g.pinned(fields[len(fields)-1]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("gpgv: no valid signature by a pinned key (%s)", strings.Join(g.Fingerprints, ", "))
+}
+
+// keyring reads Keys into one binary keyring, refusing any key that is not
+// pinned.
+func (g GPG) keyring() ([]byte, error) {
+	keys := g.KeyFS
+	if keys == nil {
+		keys, _ = fs.Sub(embedded, "keys")
+	}
+	var keyring []byte
+	for _, name := range g.Keys {
+		data, err := fs.ReadFile(keys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			data, err = g.fetch(name)
+		} else if err != nil {
+			err = fmt.Errorf("reading signing key %s: %w", name, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		key, fprs, err := ParseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", name, err)
+		}
+		for _, fpr := range fprs {
+			if !g.pinned(fpr) {
+				return nil, fmt.Errorf("signing key %s has fingerprint %s, which is not pinned", name, fpr)
+			}
+		}
+		keyring = append(keyring, key...)
+	}
+	return keyring, nil
+}
+
+// fetch downloads the key name, which is not in keys/, from its URL.
+func (g GPG) fetch(name string) ([]byte, error) {
+	url, ok := g.URLs[name]
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not in this build; commit it to internal/pkg/verify/keys", name)
+	}
+	fetch := g.Fetch
+	if fetch == nil {
+		fetch = DefaultFetcher()
+	}
+	data, err := fetch(url)
+	if err != nil {
+		return nil, fmt.Errorf("fetching signing key %s: %w", name, err)
+	}
+	return data, nil
+}
+
+func (g GPG) pinned(fpr string) bool {
+	fpr = NormalizeFingerprint(fpr)
+	return slices.ContainsFunc(g.Fingerprints, func(p string) bool { return NormalizeFingerprint(p) == fpr })
+}
+
+// WithFetcher returns v fetching any keys it needs with fetch, e.g.
+// through the download cache. Verifiers that fetch nothing are returned as
+// they are.
+func WithFetcher(v Verifier, fetch Fetcher) Verifier {
+	if g, ok := v.(GPG); ok && fetch != nil {
+		g.Fetch = fetch
+		return g
+	}
+	return v
+}
+
+// Skipped warns on w that what was not verified because of
+// --insecure-skip-verify.
+func Skipped(w io.Writer, what string) {
+	fmt.Fprintf(w, "WARNING: NOT verifying the signature of %s (--insecure-skip-verify)\n", what)
+}
@@ -0,0 +1,13 @@
+package verify_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestVerifySuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "verify Suite")
+}
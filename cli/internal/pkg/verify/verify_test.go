@@ -0,0 +1,124 @@
+package verify_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/verify"
+)
+
+// testdata/data is signed by the key in key.asc (data.sig) and by the
+// unrelated key in other.asc (other.sig).
+const (
+	testFingerprint  = "AE3A 94CE 2881 4A2A 20DC  721C 6EDE EF15 BAAC FC9C"
+	otherFingerprint = "0A5A24BD36E283B9EACBDB2E92D36441D382D916"
+)
+
+func testdata(name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	Expect(err).NotTo(HaveOccurred())
+	return data
+}
+
+var _ = Describe("GPG", func() {
+	var gpg verify.GPG
+
+	BeforeEach(func() {
+		if _, err := exec.LookPath("gpgv"); err != nil {
+			Skip("gpgv is not installed")
+		}
+		gpg = verify.GPG{
+			Keys:         []string{"key.asc"},
+			Fingerprints: []string{testFingerprint},
+			KeyFS:        os.DirFS("testdata"),
+		}
+	})
+
+	It("accepts a signature by a pinned key", func() {
+		Expect(gpg.Verify(testdata("data"), testdata("data.sig"))).To(Succeed())
+	})
+
+	It("rejects data that has changed since it was signed", func() {
+		data := append(testdata("data"), "tampered\n"...)
+
+		Expect(gpg.Verify(data, testdata("data.sig"))).To(MatchError(ContainSubstring("gpgv")))
+	})
+
+	It("rejects a signature by a key it was not given", func() {
+		Expect(gpg.Verify(testdata("data"), testdata("other.sig"))).To(HaveOccurred())
+	})
+
+	It("refuses a key whose fingerprint is not pinned", func() {
+		gpg.Keys = append(gpg.Keys, "other.asc")
+
+		err := gpg.Verify(testdata("data"), testdata("other.sig"))
+
+		Expect(err).To(MatchError(ContainSubstring("has fingerprint " + otherFingerprint + ", which is not pinned")))
+	})
+
+	It("fails when a key is not in the build", func() {
+		gpg.Keys = []string{"missing.asc"}
+
+		Expect(gpg.Verify(testdata("data"), testdata("data.sig"))).To(MatchError("signing key missing.asc is not in this build; commit it to internal/pkg/verify/keys"))
+	})
+
+	It("fetches a key that is not in the build from its URL", func() {
+		gpg.Keys = []string{"fetched.asc"}
+		gpg.URLs = map[string]string{"fetched.asc": "https://example.com/key.asc"}
+		gpg.Fetch = func(url string) ([]byte, error) {
+			Expect(url).To(Equal("https://example.com/key.asc"))
+			return testdata("key.asc"), nil
+		}
+
+		Expect(gpg.Verify(testdata("data"), testdata("data.sig"))).To(Succeed())
+	})
+
+	It("refuses a fetched key whose fingerprint is not pinned", func() {
+		gpg.Keys = []string{"fetched.asc"}
+		gpg.URLs = map[string]string{"fetched.asc": "https://example.com/other.asc"}
+		gpg.Fetch = func(string) ([]byte, error) { return testdata("other.asc"), nil }
+
+		err := gpg.Verify(testdata("data"), testdata("other.sig"))
+
+		Expect(err).To(MatchError(ContainSubstring("has fingerprint " + otherFingerprint + ", which is not pinned")))
+	})
+
+	It("fails when a key cannot be fetched", func() {
+		gpg.Keys = []string{"fetched.asc"}
+		gpg.URLs = map[string]string{"fetched.asc": "https://example.com/key.asc"}
+		gpg.Fetch = func(string) ([]byte, error) { return nil, errors.New("not found") }
+
+		Expect(gpg.Verify(testdata("data"), testdata("data.sig"))).To(MatchError("fetching signing key fetched.asc: not found"))
+	})
+})
+
+var _ = Describe("keys", func() {
+	It("pins the fingerprint of every key committed to keys/", func() {
+		files, err := filepath.Glob(filepath.Join("keys", "*.asc"))
+		Expect(err).NotTo(HaveOccurred())
+		pinned := append(verify.HashiCorp.Fingerprints, verify.RVM.Fingerprints...)
+		for _, file := range files {
+			_, fprs, err := verify.ParseKey(testdata(filepath.Join("..", file)))
+			Expect(err).NotTo(HaveOccurred(), file)
+			for _, fpr := range fprs {
+				Expect(pinned).To(ContainElement(fpr), file)
+			}
+		}
+	})
+})
+
+var _ = Describe("Skipped", func() {
+	It("warns loudly that the check was skipped", func() {
+		var out bytes.Buffer
+
+		verify.Skipped(&out, "https://example.com/SHA256SUMS")
+
+		Expect(out.String()).To(Equal("WARNING: NOT verifying the signature of https://example.com/SHA256SUMS (--insecure-skip-verify)\n"))
+	})
+})
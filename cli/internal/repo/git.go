@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Git runs git commands against the repository rooted at Root.
+type Git struct {
+	Root string
+}
+
+// NewGit returns a Git bound to the given repository root.
+func NewGit(root string) Git {
+	return Git{Root: root}
+}
+
+// HasChanges reports whether paths, or the whole working tree when none are
+// given, have any staged, unstaged, or untracked changes.
+func (g Git) HasChanges(paths ...string) (bool, error) {
+	out, err := g.output(append([]string{"status", "--porcelain", "--"}, paths...)...)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(out) != "", nil
+}
+
+// Status streams the human-readable `git status` of paths, or of the whole
+// working tree when none are given, to w.
+func (g Git) Status(w io.Writer, paths ...string) error {
+	return g.run(w, w, append([]string{"status", "--"}, paths...)...)
+}
+
+// Commit stages every change under paths and commits those paths alone with
+// message: anything else in the tree, staged or not, is left out.
+func (g Git) Commit(message string, paths []string, stdout, stderr io.Writer) error {
+	paths = g.known(paths)
+	if len(paths) == 0 {
+		return errors.New("git commit: no paths to commit")
+	}
+	if err := g.run(stdout, stderr, append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return err
+	}
+	return g.run(stdout, stderr, append([]string{"commit", "-m", message, "--"}, paths...)...)
+}
+
+// known drops the paths that neither exist nor are tracked, which git add
+// would reject.
+func (g Git) known(paths []string) []string {
+	var known []string
+	for _, p := range paths {
+		if _, err := os.Lstat(p); err == nil {
+			known = append(known, p)
+		} else if _, err := g.output("ls-files", "--error-unmatch", "--", p); err == nil {
+			known = append(known, p)
+		}
+	}
+	return known
+}
+
+// Head returns the full hash of the checked-out commit.
+func (g Git) Head() (string, error) {
+	out, err := g.output("rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (g Git) run(stdout, stderr io.Writer, args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", g.Root}, args...)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return nil
+}
+
+func (g Git) output(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	if err := g.run(&stdout, &stderr, args...); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
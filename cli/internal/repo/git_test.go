@@ -0,0 +1,74 @@
+package repo_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/repo"
+)
+
+var _ = Describe("Git", func() {
+	var (
+		root string
+		git  repo.Git
+	)
+
+	BeforeEach(func() {
+		if _, err := exec.LookPath("git"); err != nil {
+			Skip("git not on PATH")
+		}
+		root = GinkgoT().TempDir()
+		for _, args := range [][]string{
+			{"init", "-q"},
+			{"config", "user.email", "test@example.com"},
+			{"config", "user.name", "test"},
+		} {
+			Expect(exec.Command("git", append([]string{"-C", root}, args...)...).Run()).To(Succeed())
+		}
+		git = repo.NewGit(root)
+	})
+
+	It("reports no changes on a clean tree", func() {
+		dirty, err := git.HasChanges()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dirty).To(BeFalse())
+	})
+
+	It("reports untracked files as changes, and Commit leaves its paths clean", func() {
+		zshrc := filepath.Join(root, "zshrc")
+		Expect(os.WriteFile(zshrc, []byte("x"), 0o644)).To(Succeed())
+
+		dirty, err := git.HasChanges(zshrc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dirty).To(BeTrue())
+
+		Expect(git.Commit("sync", []string{zshrc}, &bytes.Buffer{}, &bytes.Buffer{})).To(Succeed())
+
+		dirty, err = git.HasChanges()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dirty).To(BeFalse())
+	})
+
+	It("commits only the paths it is given, leaving backups and staged work alone", func() {
+		zshrc := filepath.Join(root, "zshrc")
+		Expect(os.WriteFile(zshrc, []byte("x"), 0o644)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(root, "backups"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "backups", "secret"), []byte("token"), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "wip"), []byte("draft"), 0o644)).To(Succeed())
+		Expect(exec.Command("git", "-C", root, "add", "wip").Run()).To(Succeed())
+
+		Expect(git.Commit("sync", []string{zshrc, filepath.Join(root, "missing")}, &bytes.Buffer{}, &bytes.Buffer{})).To(Succeed())
+
+		out, err := exec.Command("git", "-C", root, "show", "--name-only", "--format=", "HEAD").Output()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("zshrc\n"))
+		status, err := exec.Command("git", "-C", root, "status", "--porcelain").Output()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(status)).To(Equal("A  wip\n?? backups/\n"))
+	})
+})
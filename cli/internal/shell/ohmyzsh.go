@@ -3,25 +3,35 @@
 package shell
 
 import (
+	"fmt"
 	"io"
 	"os"
-	"os/exec"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/script"
+	"github.com/cloudwalk/machine-setup/internal/pkg/verify"
 )
 
-// installerScript is the official oh-my-zsh install line. Piped through sh.
-const installerScript = `sh -c "$(curl -fsSL https://raw.githubusercontent.com/ohmyzsh/ohmyzsh/master/tools/install.sh)"`
+// installerScript is the official oh-my-zsh installer, run with
+// RUNZSH=no KEEP_ZSHRC=yes CHSH=no so it does not overwrite ~/.zshrc (the zsh
+// component does that next) and does not chsh. oh-my-zsh does not sign it,
+// so it is pinned at a commit (see script.Script.Pinned), or runs once
+// approved (see script.Trust) while it is not.
+func installerScript(trust script.Trust) script.Script {
+	return script.Script{
+		Name:  "ohmyzsh",
+		URL:   "https://raw.githubusercontent.com/ohmyzsh/ohmyzsh/master/tools/install.sh",
+		Shell: "sh",
+		Env:   []string{"RUNZSH=no", "KEEP_ZSHRC=yes", "CHSH=no"},
+		Trust: trust,
+	}.Pinned()
+}
 
-// DefaultRunner returns the production runner, which invokes the official
-// installer with RUNZSH=no KEEP_ZSHRC=yes CHSH=no so it does not overwrite
-// ~/.zshrc (the zsh component does that next) and does not chsh.
-func DefaultRunner() func(stdout, stderr io.Writer) error {
-	return func(stdout, stderr io.Writer) error {
-		cmd := exec.Command("sh", "-c", installerScript)
-		cmd.Env = append(os.Environ(), "RUNZSH=no", "KEEP_ZSHRC=yes", "CHSH=no")
-		cmd.Stdout = stdout
-		cmd.Stderr = stderr
-		return cmd.Run()
-	}
+// DefaultRunner returns the production runner, which downloads the official
+// installer with fetch, checks it as trust says, and runs it.
+func DefaultRunner(trust script.Trust, fetch verify.Fetcher) func(stdout, stderr io.Writer) error {
+	s := installerScript(trust)
+	s.Fetch = fetch
+	return s.Run
 }
 
 // OhMyZshInstaller installs oh-my-zsh non-interactively.
@@ -34,6 +44,15 @@ type OhMyZshInstaller struct {
 	Stderr io.Writer
 }
 
+// Plan reports what DefaultRunner does, or that the install is skipped
+// because Dir exists.
+func (i OhMyZshInstaller) Plan() []string {
+	if _, err := os.Stat(i.Dir); err == nil {
+		return []string{fmt.Sprintf("skip: %s already exists", i.Dir)}
+	}
+	return installerScript(script.Trust{}).Plan()
+}
+
 // Install runs the installer if Dir does not exist; otherwise no-ops.
 func (i OhMyZshInstaller) Install() error {
 	if _, err := os.Stat(i.Dir); err == nil {
@@ -60,3 +60,19 @@ var _ = Describe("OhMyZshInstaller.Install", func() {
 		Expect(gotStderr).To(BeIdenticalTo(io.Writer(stderr)))
 	})
 })
+
+var _ = Describe("OhMyZshInstaller.Plan", func() {
+	It("reports a skip when the dir exists and the checked install otherwise", func() {
+		dir := filepath.Join(GinkgoT().TempDir(), ".oh-my-zsh")
+		installer := shell.OhMyZshInstaller{Dir: dir}
+
+		Expect(installer.Plan()).To(Equal([]string{
+			"download https://raw.githubusercontent.com/ohmyzsh/ohmyzsh/master/tools/install.sh",
+			"not pinned: ask before running it, showing any change from the copy approved before",
+			"RUNZSH=no KEEP_ZSHRC=yes CHSH=no sh install.sh",
+		}))
+
+		Expect(os.MkdirAll(dir, 0o755)).To(Succeed())
+		Expect(installer.Plan()).To(Equal([]string{"skip: " + dir + " already exists"}))
+	})
+})
@@ -1,9 +1,11 @@
 package shell
 
 import (
+	"fmt"
 	"io"
 	"os"
-	"os/exec"
+
+	"github.com/cloudwalk/machine-setup/internal/pkg/cache"
 )
 
 // p10kRepo is the canonical Powerlevel10k git remote.
@@ -28,14 +30,21 @@ func (i Powerlevel10kInstaller) Install() error {
 	return i.Runner(i.Stdout, i.Stderr)
 }
 
-// DefaultP10kRunner returns the production Runner: `git clone --depth=1` of
-// the Powerlevel10k repo into the configured Dir. The Dir is closed over from
-// the installer at construction time via the wrapper in cmd/setup.go.
-func DefaultP10kRunner(dir string) func(stdout, stderr io.Writer) error {
+// Plan reports the clone DefaultP10kRunner performs, or that it is skipped
+// because Dir exists.
+func (i Powerlevel10kInstaller) Plan() []string {
+	if _, err := os.Stat(i.Dir); err == nil {
+		return []string{fmt.Sprintf("skip: %s already exists", i.Dir)}
+	}
+	return []string{fmt.Sprintf("git clone --depth=1 %s %s", p10kRepo, i.Dir)}
+}
+
+// DefaultP10kRunner returns the production Runner: a shallow clone of the
+// Powerlevel10k repo into the configured Dir, by way of the download cache c
+// (see cache.Cache.Clone). The Dir is closed over from the installer at
+// construction time via the wrapper in cmd/setup.go.
+func DefaultP10kRunner(dir string, c cache.Cache) func(stdout, stderr io.Writer) error {
 	return func(stdout, stderr io.Writer) error {
-		cmd := exec.Command("git", "clone", "--depth=1", p10kRepo, dir)
-		cmd.Stdout = stdout
-		cmd.Stderr = stderr
-		return cmd.Run()
+		return c.Clone(p10kRepo, dir, stdout, stderr)
 	}
 }
@@ -0,0 +1,242 @@
+// Package textdiff renders line-based unified diffs (the `diff -u` format)
+// without shelling out, so the CLI can preview what a pull would overwrite.
+package textdiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change.
+const context = 3
+
+// Stat counts changed lines between two texts.
+type Stat struct {
+	Added   int
+	Removed int
+}
+
+// IsBinary reports whether b looks like binary content (contains a NUL byte
+// in its first 8 KiB — the same heuristic git uses).
+func IsBinary(b []byte) bool {
+	if len(b) > 8000 {
+		b = b[:8000]
+	}
+	return bytes.IndexByte(b, 0) >= 0
+}
+
+// Unified returns the unified diff turning a (labelled aName) into b
+// (labelled bName), or "" when the texts are identical.
+func Unified(aName, bName string, a, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	if IsBinary(a) || IsBinary(b) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", aName, bName)
+	}
+	al, bl := splitLines(a), splitLines(b)
+	ops := edits(al, bl)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for _, h := range hunks(ops) {
+		writeHunk(&out, ops[h.start:h.end], al, bl)
+	}
+	return out.String()
+}
+
+// Count returns the added/removed line counts between a and b. Binary
+// content counts as a single removed and added line when it differs.
+func Count(a, b []byte) Stat {
+	if bytes.Equal(a, b) {
+		return Stat{}
+	}
+	if IsBinary(a) || IsBinary(b) {
+		return Stat{Added: 1, Removed: 1}
+	}
+	var s Stat
+	for _, o := range edits(splitLines(a), splitLines(b)) {
+		switch o.kind {
+		case '+':
+			s.Added++
+		case '-':
+			s.Removed++
+		}
+	}
+	return s
+}
+
+// noNewline follows a last line that does not end in a newline. Lines never
+// contain "\n", so such a line never matches one that does end in it, and
+// writeHunk prints the marker on its own line, as diff -u does.
+const noNewline = "\n\\ No newline at end of file"
+
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	s, ok := strings.CutSuffix(string(b), "\n")
+	lines := strings.Split(s, "\n")
+	if !ok {
+		lines[len(lines)-1] += noNewline
+	}
+	return lines
+}
+
+// op is one step of an edit script. a and b are the line indices the step
+// reads from (for '-' and ' ') or the insertion point (for '+'), so every op
+// carries both positions and hunk headers fall out directly.
+type op struct {
+	kind byte // ' ', '-', '+'
+	a, b int
+}
+
+// edits computes a shortest edit script with Myers' O(ND) algorithm.
+func edits(a, b []string) []op {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	off := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	var found bool
+	for d := 0; d <= max && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	var ops []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{kind: ' ', a: x, b: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, op{kind: '+', a: x, b: y})
+		} else {
+			x--
+			ops = append(ops, op{kind: '-', a: x, b: y})
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+type span struct{ start, end int }
+
+// hunks groups ops into [start,end) windows around each change, merging
+// windows whose context would overlap.
+func hunks(ops []op) []span {
+	var out []span
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == ' ' {
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i + 1
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*context {
+				end = next
+				continue
+			}
+			break
+		}
+		last := end + context
+		if last > len(ops) {
+			last = len(ops)
+		}
+		if n := len(out); n > 0 && start <= out[n-1].end {
+			out[n-1].end = last
+		} else {
+			out = append(out, span{start: start, end: last})
+		}
+		i = last - 1
+	}
+	return out
+}
+
+func writeHunk(out *strings.Builder, ops []op, a, b []string) {
+	var aLen, bLen int
+	for _, o := range ops {
+		if o.kind != '+' {
+			aLen++
+		}
+		if o.kind != '-' {
+			bLen++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", rangeOf(ops[0].a, aLen), rangeOf(ops[0].b, bLen))
+	for _, o := range ops {
+		line := ""
+		if o.kind == '+' {
+			line = b[o.b]
+		} else {
+			line = a[o.a]
+		}
+		out.WriteByte(o.kind)
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+}
+
+// rangeOf formats a hunk range: 1-based start, with an empty range reported
+// as the line it follows (matching GNU diff).
+func rangeOf(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
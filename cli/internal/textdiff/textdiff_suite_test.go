@@ -0,0 +1,13 @@
+package textdiff_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTextdiffSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "textdiff Suite")
+}
@@ -0,0 +1,61 @@
+package textdiff_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudwalk/machine-setup/internal/textdiff"
+)
+
+var _ = Describe("Unified", func() {
+	It("returns an empty string for identical inputs", func() {
+		Expect(textdiff.Unified("a", "b", []byte("x\ny\n"), []byte("x\ny\n"))).To(BeEmpty())
+	})
+
+	It("renders a single changed line with surrounding context", func() {
+		a := []byte("1\n2\n3\n4\n5\n6\n7\n8\n")
+		b := []byte("1\n2\n3\n4\nFIVE\n6\n7\n8\n")
+
+		Expect(textdiff.Unified("local", "repo", a, b)).To(Equal(
+			"--- local\n+++ repo\n" +
+				"@@ -2,7 +2,7 @@\n" +
+				" 2\n 3\n 4\n-5\n+FIVE\n 6\n 7\n 8\n"))
+	})
+
+	It("splits distant changes into separate hunks", func() {
+		a := []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n")
+		b := []byte("A\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nL\n")
+
+		Expect(textdiff.Unified("x", "y", a, b)).To(Equal(
+			"--- x\n+++ y\n" +
+				"@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n d\n" +
+				"@@ -9,4 +9,4 @@\n i\n j\n k\n-l\n+L\n"))
+	})
+
+	It("diffs against an empty side (a new file)", func() {
+		Expect(textdiff.Unified("/dev/null", "repo", nil, []byte("x\ny\n"))).To(Equal(
+			"--- /dev/null\n+++ repo\n@@ -0,0 +1,2 @@\n+x\n+y\n"))
+	})
+
+	It("marks a last line that has no newline, as diff -u does", func() {
+		Expect(textdiff.Unified("local", "repo", []byte("x\ny\n"), []byte("x\ny"))).To(Equal(
+			"--- local\n+++ repo\n@@ -1,2 +1,2 @@\n x\n-y\n+y\n\\ No newline at end of file\n"))
+	})
+
+	It("reports binary content without printing it", func() {
+		out := textdiff.Unified("a", "b", []byte("\x00\x01"), []byte("\x00\x02"))
+		Expect(out).To(Equal("Binary files a and b differ\n"))
+	})
+})
+
+var _ = Describe("Count", func() {
+	It("counts added and removed lines", func() {
+		s := textdiff.Count([]byte("a\nb\nc\n"), []byte("a\nB\nc\nd\n"))
+		Expect(s).To(Equal(textdiff.Stat{Added: 2, Removed: 1}))
+	})
+
+	It("counts a line that only gained or lost its newline as changed", func() {
+		s := textdiff.Count([]byte("a\nb"), []byte("a\nb\n"))
+		Expect(s).To(Equal(textdiff.Stat{Added: 1, Removed: 1}))
+	})
+})